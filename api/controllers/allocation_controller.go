@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+	"zakat/api/auth"
+	"zakat/api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) GetAllocationRules(c *gin.Context) {
+	errList = map[string]string{}
+
+	ar := models.AllocationRule{}
+	data, err := ar.GetAllocationRules(s.DB)
+	if err != nil {
+		errList["No_data"] = "No data allocation rule"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) SaveAllocationRules(c *gin.Context) {
+	errList = map[string]string{}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	rules := []models.AllocationRule{}
+	err = json.Unmarshal(body, &rules)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	errMsg := models.ValidateAllocationRules(rules)
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := models.SaveAllocationRules(s.DB, rules)
+	if err != nil {
+		errList["Save_failed"] = "Save allocation rules failed"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) CreateAllocationPlan(c *gin.Context) {
+	errList = map[string]string{}
+
+	start, errStart := time.Parse("2006-01-02", c.PostForm("period_start"))
+	end, errEnd := time.Parse("2006-01-02", c.PostForm("period_end"))
+	if errStart != nil || errEnd != nil {
+		errList["Invalid_period"] = "period must be formatted as yyyy-mm-dd"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	ap := models.AllocationPlan{
+		PeriodStart: start,
+		PeriodEnd:   end.Add(24*time.Hour - time.Nanosecond),
+	}
+	errMsg := ap.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := ap.ProposePlan(s.DB, tokenUID)
+	if err != nil {
+		errList["Plan_failed"] = "Create allocation plan failed"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) GetAllocationPlans(c *gin.Context) {
+	errList = map[string]string{}
+
+	ap := models.AllocationPlan{}
+	data, err := ap.GetAllocationPlans(s.DB)
+	if err != nil {
+		errList["No_data"] = "No data allocation plan"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetAllocationPlan(c *gin.Context) {
+	errList = map[string]string{}
+
+	ap := models.AllocationPlan{}
+	data, err := ap.GetAllocationPlan(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data allocation plan"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) AdjustAllocationPlan(c *gin.Context) {
+	errList = map[string]string{}
+
+	ap := models.AllocationPlan{}
+	_, err := ap.GetAllocationPlan(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data allocation plan"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	shares := map[string]float64{}
+	err = json.Unmarshal(body, &shares)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	check := models.AllocationPlan{PeriodStart: ap.PeriodStart, PeriodEnd: ap.PeriodEnd}
+	for asnaf, percentage := range shares {
+		check.Items = append(check.Items, models.AllocationPlanItem{Asnaf: asnaf, Percentage: percentage})
+	}
+	errMsg := check.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := ap.AdjustPlan(s.DB, shares)
+	if err != nil {
+		errList["Adjust_failed"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) LockAllocationPlan(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	ap := models.AllocationPlan{}
+	_, err = ap.GetAllocationPlan(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data allocation plan"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := ap.LockPlan(s.DB, tokenUID)
+	if err != nil {
+		errList["Lock_failed"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
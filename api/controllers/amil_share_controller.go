@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"zakat/api/auth"
+	"zakat/api/models"
+	"zakat/api/utils/formaterror"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) CreateAmilShareRule(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	r := models.AmilShareRule{}
+	err = json.Unmarshal(body, &r)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	r.Prepare(tokenUID)
+	errMsg := r.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := r.SaveAmilShareRule(s.DB)
+	if err != nil {
+		formattedError := formaterror.FormatError(err.Error())
+		errList = formattedError
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) GetAmilShareRules(c *gin.Context) {
+	errList = map[string]string{}
+
+	r := models.AmilShareRule{}
+	data, err := r.GetAmilShareRules(s.DB)
+	if err != nil {
+		errList["No_data"] = "No data amil share rule"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetAmilShares(c *gin.Context) {
+	errList = map[string]string{}
+
+	start, end, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+
+	share := models.AmilShare{}
+	data, err := share.GetAmilShares(s.DB, start, end, c.Query("collection_point"))
+	if err != nil {
+		errList["No_data"] = "No data amil share"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetAmilShareReport(c *gin.Context) {
+	errList = map[string]string{}
+
+	start, end, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := models.GetAmilShareReport(s.DB, start, end)
+	if err != nil {
+		errList["No_data"] = "No data amil share"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
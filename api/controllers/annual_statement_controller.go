@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"zakat/api/auth"
+	"zakat/api/models"
+	"zakat/api/report"
+
+	"github.com/gin-gonic/gin"
+)
+
+// yearQuery reads the tax year, the previous year is the default since statements are asked for in March
+func yearQuery(c *gin.Context) (int, bool) {
+	value := c.Query("year")
+	if value == "" {
+		return time.Now().Year() - 1, true
+	}
+
+	year, err := strconv.Atoi(value)
+	if err != nil || year < 2000 || year > time.Now().Year() {
+		errList["Invalid_year"] = "Invalid year"
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status": http.StatusBadRequest,
+			"error":  errList,
+		})
+		return 0, false
+	}
+
+	return year, true
+}
+
+func (s *Server) respondAnnualStatement(c *gin.Context, muzakkiID string) {
+	year, ok := yearQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := models.GetAnnualStatement(s.DB, muzakkiID, year)
+	if err != nil {
+		errList["No_data"] = "No data annual statement"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	if c.Query("format") != "pdf" {
+		c.JSON(http.StatusOK, gin.H{
+			"status":   http.StatusOK,
+			"response": data,
+		})
+		return
+	}
+
+	pdf, err := report.AnnualStatementPDF(data)
+	if err != nil {
+		errList["Render_failed"] = "Unable to render annual statement"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=rekap-zakat-%d-%s.pdf", year, muzakkiID))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+func (s *Server) GetAnnualStatement(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	s.respondAnnualStatement(c, tokenUID)
+}
+
+func (s *Server) GetMuzakkiAnnualStatement(c *gin.Context) {
+	errList = map[string]string{}
+
+	s.respondAnnualStatement(c, c.Param("uid"))
+}
+
+// GetAnnualStatements batch-generates the statements of every muzakki who paid in the year, format=zip gives the PDFs
+func (s *Server) GetAnnualStatements(c *gin.Context) {
+	errList = map[string]string{}
+
+	year, ok := yearQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := models.GetAnnualStatements(s.DB, year)
+	if err != nil {
+		errList["No_data"] = "No data annual statement"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	if c.Query("format") != "zip" {
+		c.JSON(http.StatusOK, gin.H{
+			"status":   http.StatusOK,
+			"response": data,
+		})
+		return
+	}
+
+	archive, err := report.AnnualStatementsZip(data)
+	if err != nil {
+		errList["Render_failed"] = "Unable to render annual statements"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=rekap-zakat-%d.zip", year))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"time"
+	"zakat/api/gateway"
 	"zakat/api/middleware"
 	"zakat/api/models"
 
@@ -16,8 +17,9 @@ import (
 )
 
 type Server struct {
-	DB     *gorm.DB
-	Router *gin.Engine
+	DB      *gorm.DB
+	Router  *gin.Engine
+	Gateway gateway.Gateway
 }
 
 var errList = make(map[string]string)
@@ -50,19 +52,115 @@ func (s *Server) Initialize(DBDriver, DBUser, DBPort, DBHost, DBName, DBPassword
 	// s.DB.Debug().Migrator().DropTable(
 	// 	&models.User{},
 	// 	&models.Muzakki{},
+	// 	&models.MuzakkiContact{},
 	// 	&models.ZakatFitrah{},
 	// 	&models.ZakatMal{},
 	// 	&models.PriceIdr{},
+	// 	&models.WeightUnit{},
+	// 	&models.PriceProposal{},
+	// 	&models.CommodityPrice{},
+	// 	&models.NisabRuling{},
+	// 	&models.Recalculation{},
+	// 	&models.RecalculationItem{},
+	// 	&models.ZakatMalHistory{},
+	// 	&models.Mustahik{},
+	// 	&models.Distribution{},
+	// 	&models.AllocationRule{},
+	// 	&models.AllocationPlan{},
+	// 	&models.AllocationPlanItem{},
+	// 	&models.KifayahThreshold{},
+	// 	&models.EligibilityAssessment{},
+	// 	&models.MustahikVerification{},
+	// 	&models.MustahikAttachment{},
+	// 	&models.Financing{},
+	// 	&models.FinancingInstallment{},
+	// 	&models.FinancingRepayment{},
+	// 	&models.Warehouse{},
+	// 	&models.StockMovement{},
+	// 	&models.Payment{},
+	// 	&models.Invoice{},
+	// 	&models.Account{},
+	// 	&models.JournalEntry{},
+	// 	&models.JournalLine{},
+	// 	&models.LedgerPeriod{},
+	// 	&models.Organization{},
+	// 	&models.ReceiptSequence{},
+	// 	&models.Receipt{},
+	// 	&models.CollectionPoint{},
+	// 	&models.AmilShareRule{},
+	// 	&models.AmilShare{},
+	// 	&models.PaymentCorrection{},
+	// 	&models.Commitment{},
+	// 	&models.CommitmentReminder{},
+	// 	&models.PayrollImport{},
+	// 	&models.PayrollImportLine{},
 	// )
 
 	s.DB.Debug().AutoMigrate(
 		&models.User{},
 		&models.Muzakki{},
+		&models.MuzakkiContact{},
 		&models.ZakatFitrah{},
 		&models.ZakatMal{},
 		&models.PriceIdr{},
+		&models.WeightUnit{},
+		&models.PriceProposal{},
+		&models.CommodityPrice{},
+		&models.NisabRuling{},
+		&models.Recalculation{},
+		&models.RecalculationItem{},
+		&models.ZakatMalHistory{},
+		&models.Mustahik{},
+		&models.Distribution{},
+		&models.AllocationRule{},
+		&models.AllocationPlan{},
+		&models.AllocationPlanItem{},
+		&models.KifayahThreshold{},
+		&models.EligibilityAssessment{},
+		&models.MustahikVerification{},
+		&models.MustahikAttachment{},
+		&models.Financing{},
+		&models.FinancingInstallment{},
+		&models.FinancingRepayment{},
+		&models.Warehouse{},
+		&models.StockMovement{},
+		&models.Payment{},
+		&models.PaymentAllocation{},
+		&models.Invoice{},
+		&models.Account{},
+		&models.JournalEntry{},
+		&models.JournalLine{},
+		&models.LedgerPeriod{},
+		&models.Organization{},
+		&models.ReceiptSequence{},
+		&models.Receipt{},
+		&models.CollectionPoint{},
+		&models.AmilShareRule{},
+		&models.AmilShare{},
+		&models.PaymentCorrection{},
+		&models.Commitment{},
+		&models.CommitmentReminder{},
+		&models.PayrollImport{},
+		&models.PayrollImportLine{},
 	)
 
+	// a split payment has one receipt per allocation, databases created before that still carry the unique key
+	if s.DB.Migrator().HasConstraint(&models.Receipt{}, "receipts_payment_id_key") {
+		s.DB.Debug().Migrator().DropConstraint(&models.Receipt{}, "receipts_payment_id_key")
+	}
+
+	models.SeedCommodityPrice(s.DB, models.CommodityRice, models.UnitKg, 11800)
+	models.SeedAccounts(s.DB)
+	models.SeedOrganization(s.DB, os.Getenv("ORG_NAME"), os.Getenv("ORG_ADDRESS"))
+
+	// online payment is optional, without PAYMENT_GATEWAY the invoice and webhook routes are left out
+	if os.Getenv("PAYMENT_GATEWAY") != "" {
+		s.Gateway, err = gateway.New(os.Getenv("PAYMENT_GATEWAY"), os.Getenv("PAYMENT_WEBHOOK_SECRET"), os.Getenv("PAYMENT_SIMULATOR") == "true")
+		if err != nil {
+			log.Fatal("This is the error setting up the payment gateway:", err)
+		}
+	}
+
 	//get price
 	var timer = time.NewTimer(15 * time.Second)
 	fmt.Println("get price start")
@@ -75,6 +173,8 @@ func (s *Server) Initialize(DBDriver, DBUser, DBPort, DBHost, DBName, DBPassword
 	s.Router.Use(middleware.CORSMiddleware())
 
 	s.InitializeRoutes()
+
+	go s.ScheduleCommitments(Commitment_schedule_interval)
 }
 
 func (s *Server) Run(addr string) {
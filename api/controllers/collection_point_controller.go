@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"zakat/api/models"
+	"zakat/api/utils/formaterror"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) CreateCollectionPoint(c *gin.Context) {
+	errList = map[string]string{}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	cp := models.CollectionPoint{}
+	err = json.Unmarshal(body, &cp)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	cp.Prepare()
+	errMsg := cp.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := cp.SaveCollectionPoint(s.DB)
+	if err != nil {
+		formattedError := formaterror.FormatError(err.Error())
+		errList = formattedError
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) GetCollectionPoints(c *gin.Context) {
+	errList = map[string]string{}
+
+	cp := models.CollectionPoint{}
+	data, err := cp.GetCollectionPoints(s.DB)
+	if err != nil {
+		errList["No_data"] = "No data collection point"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) UpdateCollectionPoint(c *gin.Context) {
+	errList = map[string]string{}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		errList["Invalid_request"] = "Invalid request"
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status": http.StatusBadRequest,
+			"error":  errList,
+		})
+		return
+	}
+
+	old := models.CollectionPoint{}
+	_, err = old.GetCollectionPoint(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data collection point"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	// the point stays open unless the body says otherwise
+	cp := models.CollectionPoint{IsActive: old.IsActive}
+	err = json.Unmarshal(body, &cp)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	// payments and amil shares are reported under the code
+	cp.ID = uint(id)
+	cp.Code = old.Code
+	cp.Prepare()
+	errMsg := cp.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := cp.UpdateCollectionPoint(s.DB)
+	if err != nil {
+		formattedError := formaterror.FormatError(err.Error())
+		errList = formattedError
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
@@ -0,0 +1,345 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"zakat/api/auth"
+	"zakat/api/models"
+	"zakat/api/utils/formaterror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Commitment_schedule_interval is how often the scheduler looks for periods and reminders that are due
+const Commitment_schedule_interval = time.Hour
+
+// ScheduleCommitments runs the commitment schedule in the background for as long as the server is up
+func (s *Server) ScheduleCommitments(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := models.RunCommitmentSchedule(s.DB, time.Now())
+		if err != nil {
+			log.Println("commitment schedule failed:", err)
+		} else {
+			log.Printf("commitment schedule: %d obligations, %d reminders, %d failed\n", result.Generated, result.Reminders, result.Failed)
+		}
+		<-ticker.C
+	}
+}
+
+func (s *Server) CreateCommitment(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	cm := models.Commitment{}
+	err = json.Unmarshal(body, &cm)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	cm.Status = ""
+	cm.Prepare(tokenUID)
+	errMsg := cm.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := cm.SaveCommitment(s.DB)
+	if err != nil {
+		formattedError := formaterror.FormatError(err.Error())
+		errList = formattedError
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) GetCommitments(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	cm := models.Commitment{}
+	data, err := cm.GetCommitments(s.DB, tokenUID, c.Query("status"))
+	if err != nil {
+		errList["No_data"] = "No data commitment"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetAllCommitments(c *gin.Context) {
+	errList = map[string]string{}
+
+	cm := models.Commitment{}
+	data, err := cm.GetCommitments(s.DB, c.Query("muzakki"), c.Query("status"))
+	if err != nil {
+		errList["No_data"] = "No data commitment"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetCommitment(c *gin.Context) {
+	errList = map[string]string{}
+
+	data, ok := s.readableCommitment(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) UpdateCommitment(c *gin.Context) {
+	errList = map[string]string{}
+
+	old, ok := s.readableCommitment(c)
+	if !ok {
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	cm := models.Commitment{}
+	err = json.Unmarshal(body, &cm)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	// the start date anchors the periods already generated
+	cm.ID = old.ID
+	cm.StartDate = old.StartDate
+	cm.Frequency = old.Frequency
+	if cm.Status == "" {
+		cm.Status = old.Status
+	}
+	cm.Prepare(old.IdMuzakki)
+	errMsg := cm.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := cm.UpdateCommitment(s.DB)
+	if err != nil {
+		formattedError := formaterror.FormatError(err.Error())
+		errList = formattedError
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetCommitmentReminders(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := models.GetCommitmentReminders(s.DB, tokenUID)
+	if err != nil {
+		errList["No_data"] = "No data reminder"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetCommitmentsAtRisk(c *gin.Context) {
+	errList = map[string]string{}
+
+	missed := models.Default_missed
+	if value := c.Query("missed"); value != "" {
+		var err error
+		missed, err = strconv.Atoi(value)
+		if err != nil || missed < 1 {
+			errList["Invalid_missed"] = "Missed must be a positive number"
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status": http.StatusBadRequest,
+				"error":  errList,
+			})
+			return
+		}
+	}
+
+	data, err := models.GetCommitmentsAtRisk(s.DB, missed, time.Now())
+	if err != nil {
+		errList["No_data"] = "No data commitment"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) RunCommitmentSchedule(c *gin.Context) {
+	errList = map[string]string{}
+
+	data, err := models.RunCommitmentSchedule(s.DB, time.Now())
+	if err != nil {
+		errList["Schedule_failed"] = err.Error()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+// readableCommitment loads the commitment of the path, only its muzakki or an admin may see it
+func (s *Server) readableCommitment(c *gin.Context) (*models.Commitment, bool) {
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return nil, false
+	}
+
+	cm := models.Commitment{}
+	data, err := cm.GetCommitment(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data commitment"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return nil, false
+	}
+
+	if data.IdMuzakki != tokenUID {
+		user := models.User{}
+		err = s.DB.Model(&models.User{}).Where("user_id = ?", tokenUID).Take(&user).Error
+		if err != nil || user.Role != "admin" {
+			errList["Unauthorized"] = "Unauthorized"
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status": http.StatusUnauthorized,
+				"error":  errList,
+			})
+			return nil, false
+		}
+	}
+
+	return data, true
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"zakat/api/models"
+	"zakat/api/utils/formaterror"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) GetCommodityPrices(c *gin.Context) {
+	errList = map[string]string{}
+
+	cp := models.CommodityPrice{}
+	data, err := cp.GetCommodityPrices(s.DB, c.Query("commodity"), c.Query("region"))
+	if err != nil {
+		errList["No_data"] = "No data commodity price"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) CreateCommodityPrice(c *gin.Context) {
+	errList = map[string]string{}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	cp := models.CommodityPrice{}
+	err = json.Unmarshal(body, &cp)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	cp.Prepare()
+	errMsg := cp.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := cp.SaveCommodityPrice(s.DB)
+	if err != nil {
+		formattedError := formaterror.FormatError(err.Error())
+		errList = formattedError
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) DeleteCommodityPrice(c *gin.Context) {
+	errList = map[string]string{}
+
+	id := c.Param("id")
+
+	cp := models.CommodityPrice{}
+	_, err := cp.DeleteCommodityPrice(s.DB, id)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": "Commodity price deleted",
+	})
+}
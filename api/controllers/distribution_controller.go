@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"zakat/api/auth"
+	"zakat/api/models"
+	"zakat/api/utils/formaterror"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (s *Server) CreateDistribution(c *gin.Context) {
+	errList = map[string]string{}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	d := models.Distribution{}
+	err = json.Unmarshal(body, &d)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	d.Prepare(tokenUID)
+	errMsg := d.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := d.SaveDistribution(s.DB)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errList["No_mustahik"] = "No data mustahik"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+	if errors.Is(err, models.ErrInsufficientFund) || errors.Is(err, models.ErrAllocationExceeded) {
+		errList["Insufficient_fund"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+	if errors.Is(err, models.ErrMustahikNotApproved) || errors.Is(err, models.ErrInsufficientStock) {
+		errList["Not_approved"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+	if err != nil {
+		formattedError := formaterror.FormatError(err.Error())
+		errList = formattedError
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) GetDistributions(c *gin.Context) {
+	errList = map[string]string{}
+
+	d := models.Distribution{}
+	data, err := d.GetDistributions(s.DB, c.Query("mustahik_id"), c.Query("asnaf"), c.Query("fund"))
+	if err != nil {
+		errList["No_data"] = "No data distribution"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetDistribution(c *gin.Context) {
+	errList = map[string]string{}
+
+	d := models.Distribution{}
+	data, err := d.GetDistribution(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data distribution"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) DeleteDistribution(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	d := models.Distribution{}
+	_, err = d.DeleteDistribution(s.DB, c.Param("id"), tokenUID)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": "Distribution deleted",
+	})
+}
+
+func (s *Server) GetFundBalances(c *gin.Context) {
+	errList = map[string]string{}
+
+	data, err := models.GetFundBalances(s.DB)
+	if err != nil {
+		errList["No_data"] = "No data fund balance"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
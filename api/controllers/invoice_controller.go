@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"zakat/api/auth"
+	"zakat/api/gateway"
+	"zakat/api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (s *Server) CreateInvoice(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	inv := models.Invoice{}
+	err = json.Unmarshal(body, &inv)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	inv.Prepare(tokenUID)
+	errMsg := inv.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := inv.SaveInvoice(s.DB, s.Gateway)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errList["No_obligation"] = "No data obligation"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+	if errors.Is(err, models.ErrNotObligationOwner) {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+	if err != nil {
+		errList["Invoice_failed"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) GetInvoices(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	inv := models.Invoice{}
+	data, err := inv.GetInvoices(s.DB, tokenUID)
+	if err != nil {
+		errList["No_data"] = "No data invoice"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetInvoice(c *gin.Context) {
+	errList = map[string]string{}
+
+	inv, ok := s.ownInvoice(c)
+	if !ok {
+		return
+	}
+
+	data, err := inv.RefreshStatus(s.DB, s.Gateway)
+	if err != nil {
+		errList["Status_failed"] = err.Error()
+		c.JSON(http.StatusBadGateway, gin.H{
+			"status": http.StatusBadGateway,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+// SimulateInvoicePayment pays an invoice at the local simulator and feeds the signed callback to the webhook
+func (s *Server) SimulateInvoicePayment(c *gin.Context) {
+	errList = map[string]string{}
+
+	simulator, ok := s.Gateway.(*gateway.Simulator)
+	if !ok {
+		errList["Not_simulator"] = "Payment gateway is not the simulator"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	inv, ok := s.ownInvoice(c)
+	if !ok {
+		return
+	}
+
+	body, signature, err := simulator.Pay(inv.ExternalID)
+	if err != nil {
+		errList["Simulation_failed"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := s.handleCallback(simulator.Name(), body, signature)
+	if err != nil {
+		errList["Callback_failed"] = err.Error()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+// PaymentWebhook receives provider callbacks, it is authenticated by the signature instead of a token
+func (s *Server) PaymentWebhook(c *gin.Context) {
+	errList = map[string]string{}
+
+	provider := c.Param("provider")
+	if provider != s.Gateway.Name() {
+		errList["Unknown_provider"] = "Unknown payment provider"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := s.handleCallback(provider, body, c.GetHeader(gateway.SignatureHeader))
+	if errors.Is(err, gateway.ErrInvalidSignature) {
+		errList["Invalid_signature"] = err.Error()
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errList["No_data"] = "No data invoice"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+	if errors.Is(err, models.ErrAmountMismatch) {
+		errList["Amount_mismatch"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+	if err != nil {
+		errList["Callback_failed"] = err.Error()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) handleCallback(provider string, body []byte, signature string) (*models.Invoice, error) {
+	cb, err := s.Gateway.ParseCallback(body, signature)
+	if err != nil {
+		return &models.Invoice{}, err
+	}
+
+	return models.SettleInvoice(s.DB, provider, cb)
+}
+
+func (s *Server) ownInvoice(c *gin.Context) (*models.Invoice, bool) {
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return nil, false
+	}
+
+	inv := models.Invoice{}
+	data, err := inv.GetInvoice(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data invoice"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return nil, false
+	}
+
+	if data.IdMuzakki != tokenUID {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return nil, false
+	}
+
+	return data, true
+}
@@ -0,0 +1,288 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+	"zakat/api/auth"
+	"zakat/api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// periodQuery reads start and end (inclusive) dates, the current year is used when they are left out
+func periodQuery(c *gin.Context) (time.Time, time.Time, bool) {
+	now := time.Now()
+	start := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	end := now
+
+	var err error
+	if value := c.Query("start"); value != "" {
+		start, err = time.Parse("2006-01-02", value)
+	}
+	if value := c.Query("end"); value != "" && err == nil {
+		end, err = time.Parse("2006-01-02", value)
+		end = end.Add(24*time.Hour - time.Nanosecond)
+	}
+	if err != nil || end.Before(start) {
+		errList["Invalid_period"] = "Invalid period, use YYYY-MM-DD"
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status": http.StatusBadRequest,
+			"error":  errList,
+		})
+		return start, end, false
+	}
+
+	return start, end, true
+}
+
+func (s *Server) GetAccounts(c *gin.Context) {
+	errList = map[string]string{}
+
+	data, err := models.GetAccounts(s.DB)
+	if err != nil {
+		errList["No_data"] = "No data account"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetJournalEntries(c *gin.Context) {
+	errList = map[string]string{}
+
+	start, end, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := models.GetJournalEntries(s.DB, start, end, c.Query("source"))
+	if err != nil {
+		errList["No_data"] = "No data journal"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) CreateJournalEntry(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	je := models.JournalEntry{}
+	err = json.Unmarshal(body, &je)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	je.Source = models.SourceManual
+	je.SourceID = 0
+	je.ReversalOf = nil
+	je.CreatedBy = tokenUID
+	data, err := je.Post(s.DB)
+	if err != nil {
+		errList["Journal_failed"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) CreateLedgerTransfer(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	fund := c.PostForm("fund")
+	if fund == "" {
+		fund = models.FundZakat
+	}
+	amount, _ := strconv.Atoi(c.PostForm("amount"))
+
+	data, err := models.PostTransfer(s.DB, c.PostForm("from_account"), c.PostForm("to_account"), fund, amount, c.PostForm("memo"), tokenUID)
+	if err != nil {
+		errList["Transfer_failed"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) GetAccountBalances(c *gin.Context) {
+	errList = map[string]string{}
+
+	start, end, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := models.GetAccountBalances(s.DB, start, end, c.Query("fund"))
+	if err != nil {
+		errList["No_data"] = "No data balance"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetTrialBalance(c *gin.Context) {
+	errList = map[string]string{}
+
+	start, end, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := models.GetTrialBalance(s.DB, start, end)
+	if err != nil {
+		errList["No_data"] = "No data trial balance"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) CloseLedgerPeriod(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	start, errStart := time.Parse("2006-01-02", c.PostForm("period_start"))
+	end, errEnd := time.Parse("2006-01-02", c.PostForm("period_end"))
+	if errStart != nil || errEnd != nil {
+		errList["Invalid_period"] = "Invalid period, use YYYY-MM-DD"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+	end = end.Add(24*time.Hour - time.Nanosecond)
+
+	data, err := models.ClosePeriod(s.DB, start, end, tokenUID)
+	if errors.Is(err, models.ErrUnbalancedJournal) {
+		errList["Unbalanced"] = err.Error()
+		c.JSON(http.StatusConflict, gin.H{
+			"status": http.StatusConflict,
+			"error":  errList,
+		})
+		return
+	}
+	if err != nil {
+		errList["Close_failed"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) GetLedgerPeriods(c *gin.Context) {
+	errList = map[string]string{}
+
+	data, err := models.GetClosedPeriods(s.DB)
+	if err != nil {
+		errList["No_data"] = "No data period"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
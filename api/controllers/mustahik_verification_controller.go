@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+	"zakat/api/auth"
+	"zakat/api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func uploadDir() string {
+	dir := os.Getenv("UPLOAD_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+
+	return dir
+}
+
+func (s *Server) SurveyMustahik(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	m := models.Mustahik{}
+	_, err = m.GetMustahik(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data mustahik"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	notes := c.PostForm("notes")
+	if notes == "" {
+		errList["Required_notes"] = "required field visit notes"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	attachments := []models.MustahikAttachment{}
+	form, err := c.MultipartForm()
+	if err == nil {
+		dir := filepath.Join(uploadDir(), "mustahik", fmt.Sprint(m.ID))
+		err = os.MkdirAll(dir, 0755)
+		if err != nil {
+			errList["Upload_failed"] = "Unable to store attachment"
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status": http.StatusInternalServerError,
+				"error":  errList,
+			})
+			return
+		}
+
+		for _, file := range form.File["photos"] {
+			name := filepath.Base(file.Filename)
+			path := filepath.Join(dir, fmt.Sprintf("%d_%s", time.Now().UnixNano(), name))
+			err = c.SaveUploadedFile(file, path)
+			if err != nil {
+				errList["Upload_failed"] = "Unable to store attachment"
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"status": http.StatusInternalServerError,
+					"error":  errList,
+				})
+				return
+			}
+
+			attachments = append(attachments, models.MustahikAttachment{
+				FileName:    name,
+				Path:        path,
+				ContentType: file.Header.Get("Content-Type"),
+			})
+		}
+	}
+
+	s.transitionMustahik(c, &m, models.MustahikSurveyed, tokenUID, notes, attachments)
+}
+
+func (s *Server) ApproveMustahik(c *gin.Context) {
+	s.reviewMustahik(c, models.MustahikApproved)
+}
+
+func (s *Server) RejectMustahik(c *gin.Context) {
+	s.reviewMustahik(c, models.MustahikRejected)
+}
+
+func (s *Server) ReverifyMustahik(c *gin.Context) {
+	s.reviewMustahik(c, models.MustahikReverify)
+}
+
+func (s *Server) reviewMustahik(c *gin.Context, to string) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	m := models.Mustahik{}
+	_, err = m.GetMustahik(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data mustahik"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	s.transitionMustahik(c, &m, to, tokenUID, c.PostForm("notes"), nil)
+}
+
+func (s *Server) transitionMustahik(c *gin.Context, m *models.Mustahik, to, uid, notes string, attachments []models.MustahikAttachment) {
+	data, err := m.Transition(s.DB, to, uid, notes, attachments)
+	if errors.Is(err, models.ErrInvalidTransition) {
+		errList["Invalid_transition"] = "mustahik is " + m.VerificationStatus + " and can not become " + to
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetMustahikVerifications(c *gin.Context) {
+	errList = map[string]string{}
+
+	mv := models.MustahikVerification{}
+	data, err := mv.GetVerifications(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data verification"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetMustahikAttachment(c *gin.Context) {
+	errList = map[string]string{}
+
+	ma := models.MustahikAttachment{}
+	data, err := ma.GetAttachment(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data attachment"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.FileAttachment(data.Path, data.FileName)
+}
+
+func (s *Server) MarkReverificationDue(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	total, err := models.MarkReverificationDue(s.DB, tokenUID)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": gin.H{"marked": total},
+	})
+}
@@ -68,10 +68,15 @@ func (s *Server) CreateMuzakki(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"status": http.StatusCreated,
 		"reponse": gin.H{
-			"muzakki_id": data.MuzakkiId,
-			"name":       data.Name,
-			"mobile":     data.Mobile,
-			"address":    data.Address,
+			"muzakki_id":  data.MuzakkiId,
+			"entity_type": data.EntityType,
+			"name":        data.Name,
+			"legal_name":  data.LegalName,
+			"mobile":      data.Mobile,
+			"address":     data.Address,
+			"npwp":        data.Npwp,
+			"npwz":        data.Npwz,
+			"contacts":    data.Contacts,
 		},
 	})
 }
@@ -194,6 +199,9 @@ func (s *Server) UpdateMuzakki(c *gin.Context) {
 	}
 
 	m.ID = oriMuzakki.ID
+	if m.EntityType == "" {
+		m.EntityType = oriMuzakki.EntityType
+	}
 
 	m.Prepare(mID)
 	errMsg := m.Validate()
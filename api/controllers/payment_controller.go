@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"zakat/api/auth"
+	"zakat/api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (s *Server) CreatePayment(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	p := models.Payment{}
+	err = json.Unmarshal(body, &p)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	p.Prepare(tokenUID)
+	errMsg := p.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := p.SavePayment(s.DB)
+	if errors.Is(err, models.ErrCollectionPointClosed) {
+		errList["Invalid_collectionPoint"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+	if errors.Is(err, models.ErrPaymentWarehouse) {
+		errList["Required_warehouse"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errList["No_obligation"] = "No data obligation"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+	if err != nil {
+		errList["Payment_failed"] = err.Error()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	if data.ObligationType == models.FundInfaqSadaqah {
+		c.JSON(http.StatusCreated, gin.H{
+			"status": http.StatusCreated,
+			"response": gin.H{
+				"payment": data,
+			},
+		})
+		return
+	}
+
+	obligation, err := models.GetObligationPayment(s.DB, data.ObligationType, data.ObligationID)
+	if err != nil {
+		errList["No_obligation"] = "No data obligation"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": http.StatusCreated,
+		"response": gin.H{
+			"payment":        data,
+			"paid":           obligation.Paid,
+			"balance":        obligation.Balance,
+			"payment_status": obligation.Status,
+		},
+	})
+}
+
+func (s *Server) GetPayments(c *gin.Context) {
+	errList = map[string]string{}
+
+	p := models.Payment{}
+	data, err := p.GetPayments(s.DB, c.Query("muzakki_id"), c.Query("obligation_type"), c.Query("method"))
+	if err != nil {
+		errList["No_data"] = "No data payment"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetPayment(c *gin.Context) {
+	errList = map[string]string{}
+
+	p := models.Payment{}
+	data, err := p.GetPayment(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data payment"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetObligationPayment(c *gin.Context) {
+	errList = map[string]string{}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		errList["Invalid_request"] = "Invalid request"
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status": http.StatusBadRequest,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := models.GetObligationPayment(s.DB, c.Param("type"), uint(id))
+	if err != nil {
+		errList["No_data"] = "No data obligation"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
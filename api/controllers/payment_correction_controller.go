@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"zakat/api/auth"
+	"zakat/api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (s *Server) VoidPayment(c *gin.Context) {
+	s.requestCorrection(c, models.CorrectionVoid)
+}
+
+func (s *Server) RefundPayment(c *gin.Context) {
+	s.requestCorrection(c, models.CorrectionRefund)
+}
+
+func (s *Server) requestCorrection(c *gin.Context, action string) {
+	errList = map[string]string{}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		errList["Invalid_request"] = "Invalid request"
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status": http.StatusBadRequest,
+			"error":  errList,
+		})
+		return
+	}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	pc := models.PaymentCorrection{}
+	err = json.Unmarshal(body, &pc)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	pc.PaymentID = uint(id)
+	pc.Action = action
+	pc.Prepare(tokenUID)
+	errMsg := pc.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := pc.RequestCorrection(s.DB)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errList["No_data"] = "No data payment"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+	if err != nil {
+		errList["Correction_failed"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) GetPaymentCorrections(c *gin.Context) {
+	errList = map[string]string{}
+
+	pc := models.PaymentCorrection{}
+	data, err := pc.GetPaymentCorrections(s.DB, c.Query("status"))
+	if err != nil {
+		errList["No_data"] = "No data payment correction"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) ApprovePaymentCorrection(c *gin.Context) {
+	s.reviewCorrection(c, true)
+}
+
+func (s *Server) RejectPaymentCorrection(c *gin.Context) {
+	s.reviewCorrection(c, false)
+}
+
+func (s *Server) reviewCorrection(c *gin.Context, approve bool) {
+	errList = map[string]string{}
+
+	id := c.Param("id")
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	pc := models.PaymentCorrection{}
+	_, err = pc.GetPaymentCorrection(s.DB, id)
+	if err != nil {
+		errList["No_data"] = "No data payment correction"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	var data *models.PaymentCorrection
+	if approve {
+		data, err = pc.Approve(s.DB, tokenUID)
+	} else {
+		data, err = pc.Reject(s.DB, tokenUID)
+	}
+	if err != nil {
+		errList["Review_failed"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"zakat/api/auth"
+	"zakat/api/models"
+	"zakat/api/utils/sheet"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UploadPayrollImport reads the payroll file of an UPZ and keeps it as a draft, nothing is paid until it is posted
+func (s *Server) UploadPayrollImport(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		errList["Required_file"] = "required payroll file"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	point, _ := strconv.Atoi(c.PostForm("collection_point_id"))
+	pi := models.PayrollImport{
+		CollectionPointID: uint(point),
+		Company:           c.PostForm("company"),
+		Period:            c.PostForm("period"),
+		FileName:          filepath.Base(file.Filename),
+		Method:            c.PostForm("method"),
+		Reference:         c.PostForm("reference"),
+	}
+	pi.Prepare(tokenUID)
+	errMsg := pi.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		errList["Invalid_file"] = "Unable to read payroll file"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+	defer f.Close()
+
+	rows, err := sheet.Rows(file.Filename, f)
+	if err != nil {
+		errList["Invalid_file"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	lines, err := models.ParsePayroll(rows)
+	if err != nil {
+		errList["Invalid_file"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := pi.SavePayrollImport(s.DB, lines)
+	if errors.Is(err, models.ErrCollectionPointClosed) {
+		errList["Invalid_collectionPoint"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+	if err != nil {
+		errList["Import_failed"] = err.Error()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+// PostPayrollImport pays the draft as one company payment, every obligated employee gets an obligation and a receipt
+func (s *Server) PostPayrollImport(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		errList["Invalid_request"] = "Invalid request"
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status": http.StatusBadRequest,
+			"error":  errList,
+		})
+		return
+	}
+
+	pi := models.PayrollImport{}
+	pi.ID = uint(id)
+	data, err := pi.PostPayrollImport(s.DB, tokenUID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errList["No_data"] = "No data payroll import"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+	if err != nil {
+		errList["Post_failed"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetPayrollImports(c *gin.Context) {
+	errList = map[string]string{}
+
+	pi := models.PayrollImport{}
+	data, err := pi.GetPayrollImports(s.DB, c.Query("collection_point"), c.Query("period"))
+	if err != nil {
+		errList["No_data"] = "No data payroll import"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetPayrollImport(c *gin.Context) {
+	errList = map[string]string{}
+
+	pi := models.PayrollImport{}
+	data, err := pi.GetPayrollImport(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data payroll import"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
@@ -7,7 +7,9 @@ import (
 	"math"
 	"net/http"
 	"strings"
+	"zakat/api/auth"
 	"zakat/api/models"
+	"zakat/api/utils/formaterror"
 
 	"github.com/gin-gonic/gin"
 )
@@ -50,14 +52,15 @@ func GetPriceIDR(metal string) (*models.PriceIdr, error) {
 				break
 			}
 			ounce = math.Ceil(value*100) / 100
-			idr = ounce / 28.35
+			idr = ounce / models.Troy_ounce_gram
 		}
 	}
 
 	newPrice := models.PriceIdr{
-		Date: price.Date,
-		Type: price.Type,
-		Idr:  math.Ceil(idr*100) / 100,
+		Date:   price.Date,
+		Type:   price.Type,
+		Idr:    math.Ceil(idr*100) / 100,
+		Source: "metals-api.com",
 	}
 
 	return &newPrice, nil
@@ -111,9 +114,10 @@ func (s *Server) UpdatePriceIDR(c *gin.Context) {
 	price, _ := GetPriceIDR(metal)
 
 	err := s.DB.Debug().Model(&models.PriceIdr{}).Where("type = ?", metal).Updates(models.PriceIdr{
-		Date: price.Date,
-		Type: price.Type,
-		Idr:  price.Idr,
+		Date:   price.Date,
+		Type:   price.Type,
+		Idr:    price.Idr,
+		Source: price.Source,
 	}).Error
 	if err != nil {
 		errList["Update_failed"] = "Update IDR price failed"
@@ -133,3 +137,140 @@ func (s *Server) UpdatePriceIDR(c *gin.Context) {
 		},
 	})
 }
+
+func (s *Server) ProposePrice(c *gin.Context) {
+	errList = map[string]string{}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	pp := models.PriceProposal{}
+	err = json.Unmarshal(body, &pp)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	pp.Prepare(tokenUID)
+	errMsg := pp.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := pp.SavePriceProposal(s.DB)
+	if err != nil {
+		formattedError := formaterror.FormatError(err.Error())
+		errList = formattedError
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) GetPriceProposals(c *gin.Context) {
+	errList = map[string]string{}
+
+	pp := models.PriceProposal{}
+	data, err := pp.GetPriceProposals(s.DB, c.Query("status"))
+	if err != nil {
+		errList["No_data"] = "No data price proposal"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) ApprovePrice(c *gin.Context) {
+	s.reviewPrice(c, true)
+}
+
+func (s *Server) RejectPrice(c *gin.Context) {
+	s.reviewPrice(c, false)
+}
+
+func (s *Server) reviewPrice(c *gin.Context, approve bool) {
+	errList = map[string]string{}
+
+	id := c.Param("id")
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	pp := models.PriceProposal{}
+	_, err = pp.GetPriceProposal(s.DB, id)
+	if err != nil {
+		errList["No_data"] = "No data price proposal"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	var data *models.PriceProposal
+	if approve {
+		data, err = pp.Approve(s.DB, tokenUID)
+	} else {
+		data, err = pp.Reject(s.DB, tokenUID)
+	}
+	if err != nil {
+		errList["Review_failed"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
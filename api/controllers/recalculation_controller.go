@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"zakat/api/auth"
+	"zakat/api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) CreateRecalculation(c *gin.Context) {
+	errList = map[string]string{}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	r := models.Recalculation{}
+	err = json.Unmarshal(body, &r)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	r.Prepare(tokenUID)
+	errMsg := r.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := r.Evaluate(s.DB)
+	if err != nil {
+		errList["Recalculation_failed"] = err.Error()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) GetRecalculations(c *gin.Context) {
+	errList = map[string]string{}
+
+	r := models.Recalculation{}
+	data, err := r.GetRecalculations(s.DB)
+	if err != nil {
+		errList["No_data"] = "No data recalculation"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetRecalculation(c *gin.Context) {
+	errList = map[string]string{}
+
+	r := models.Recalculation{}
+	data, err := r.GetRecalculation(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data recalculation"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) ApplyRecalculation(c *gin.Context) {
+	s.confirmRecalculation(c, true)
+}
+
+func (s *Server) CancelRecalculation(c *gin.Context) {
+	s.confirmRecalculation(c, false)
+}
+
+func (s *Server) confirmRecalculation(c *gin.Context, apply bool) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	r := models.Recalculation{}
+	_, err = r.GetRecalculation(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data recalculation"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return
+	}
+
+	var data *models.Recalculation
+	if apply {
+		data, err = r.Apply(s.DB, tokenUID)
+	} else {
+		data, err = r.Cancel(s.DB, tokenUID)
+	}
+	if err != nil {
+		errList["Confirm_failed"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetZakatMalHistory(c *gin.Context) {
+	errList = map[string]string{}
+
+	h := models.ZakatMalHistory{}
+	data, err := h.GetZakatMalHistory(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data zakat mal history"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
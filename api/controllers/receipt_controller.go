@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"zakat/api/auth"
+	"zakat/api/models"
+	"zakat/api/report"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) GetReceipts(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	r := models.Receipt{}
+	data, err := r.GetReceipts(s.DB, tokenUID)
+	if err != nil {
+		errList["No_data"] = "No data receipt"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetReceipt(c *gin.Context) {
+	errList = map[string]string{}
+
+	data, ok := s.readableReceipt(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) DownloadReceipt(c *gin.Context) {
+	errList = map[string]string{}
+
+	data, ok := s.readableReceipt(c)
+	if !ok {
+		return
+	}
+
+	err := models.EnsureReceiptSignature(s.DB, data)
+	if err != nil {
+		errList["Sign_failed"] = "Unable to sign receipt"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	pdf, err := report.ReceiptPDF(data)
+	if err != nil {
+		errList["Render_failed"] = "Unable to render receipt"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename="+receiptFileName(data, "pdf"))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// PrintReceipt returns the raw ESC/POS stream, the counter app forwards it to the thermal printer as is
+func (s *Server) PrintReceipt(c *gin.Context) {
+	errList = map[string]string{}
+
+	data, ok := s.readableReceipt(c)
+	if !ok {
+		return
+	}
+
+	err := models.EnsureReceiptSignature(s.DB, data)
+	if err != nil {
+		errList["Sign_failed"] = "Unable to sign receipt"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename="+receiptFileName(data, "bin"))
+	c.Data(http.StatusOK, "application/octet-stream", report.ReceiptEscPos(data))
+}
+
+func receiptFileName(r *models.Receipt, ext string) string {
+	return "bukti-setor-" + strings.ReplaceAll(r.Number, "/", "-") + "." + ext
+}
+
+// readableReceipt loads the receipt when it belongs to the caller or the caller is an admin
+func (s *Server) readableReceipt(c *gin.Context) (*models.Receipt, bool) {
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return nil, false
+	}
+
+	r := models.Receipt{}
+	data, err := r.GetReceipt(s.DB, c.Param("id"))
+	if err != nil {
+		errList["No_data"] = "No data receipt"
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": http.StatusNotFound,
+			"error":  errList,
+		})
+		return nil, false
+	}
+
+	if data.IdMuzakki != tokenUID {
+		user := models.User{}
+		err = s.DB.Model(&models.User{}).Where("user_id = ?", tokenUID).Take(&user).Error
+		if err != nil || user.Role != "admin" {
+			errList["Unauthorized"] = "Unauthorized"
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status": http.StatusUnauthorized,
+				"error":  errList,
+			})
+			return nil, false
+		}
+	}
+
+	return data, true
+}
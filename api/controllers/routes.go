@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"fmt"
+	"zakat/api/gateway"
 	"zakat/api/middleware"
 
 	"github.com/casbin/casbin/v2"
@@ -28,6 +29,48 @@ func (s *Server) InitializeRoutes() {
 	if hasPolicy := enforcer.HasPolicy("user", "report", "read"); !hasPolicy {
 		enforcer.AddPolicy("user", "report", "read")
 	}
+	if hasPolicy := enforcer.HasPolicy("admin", "mustahik", "read"); !hasPolicy {
+		enforcer.AddPolicy("admin", "mustahik", "read")
+	}
+	if hasPolicy := enforcer.HasPolicy("admin", "mustahik", "write"); !hasPolicy {
+		enforcer.AddPolicy("admin", "mustahik", "write")
+	}
+	if hasPolicy := enforcer.HasPolicy("surveyor", "mustahik", "read"); !hasPolicy {
+		enforcer.AddPolicy("surveyor", "mustahik", "read")
+	}
+	if hasPolicy := enforcer.HasPolicy("surveyor", "mustahik", "survey"); !hasPolicy {
+		enforcer.AddPolicy("surveyor", "mustahik", "survey")
+	}
+	if hasPolicy := enforcer.HasPolicy("committee", "mustahik", "read"); !hasPolicy {
+		enforcer.AddPolicy("committee", "mustahik", "read")
+	}
+	if hasPolicy := enforcer.HasPolicy("committee", "mustahik", "approve"); !hasPolicy {
+		enforcer.AddPolicy("committee", "mustahik", "approve")
+	}
+	if hasPolicy := enforcer.HasPolicy("admin", "distribution", "read"); !hasPolicy {
+		enforcer.AddPolicy("admin", "distribution", "read")
+	}
+	if hasPolicy := enforcer.HasPolicy("admin", "distribution", "write"); !hasPolicy {
+		enforcer.AddPolicy("admin", "distribution", "write")
+	}
+	if hasPolicy := enforcer.HasPolicy("admin", "financing", "read"); !hasPolicy {
+		enforcer.AddPolicy("admin", "financing", "read")
+	}
+	if hasPolicy := enforcer.HasPolicy("admin", "financing", "write"); !hasPolicy {
+		enforcer.AddPolicy("admin", "financing", "write")
+	}
+	if hasPolicy := enforcer.HasPolicy("admin", "payment", "read"); !hasPolicy {
+		enforcer.AddPolicy("admin", "payment", "read")
+	}
+	if hasPolicy := enforcer.HasPolicy("admin", "payment", "write"); !hasPolicy {
+		enforcer.AddPolicy("admin", "payment", "write")
+	}
+	if hasPolicy := enforcer.HasPolicy("admin", "ledger", "read"); !hasPolicy {
+		enforcer.AddPolicy("admin", "ledger", "read")
+	}
+	if hasPolicy := enforcer.HasPolicy("admin", "ledger", "write"); !hasPolicy {
+		enforcer.AddPolicy("admin", "ledger", "write")
+	}
 
 	v1 := s.Router.Group("/api")
 	{
@@ -65,14 +108,241 @@ func (s *Server) InitializeRoutes() {
 	v5 := v1.Group("/zakat-mal", middleware.TokenMiddleware())
 	{
 		v5.POST("/check", s.CheckZakatMal)
-		v5.GET("/update-price/:metal", s.UpdatePriceIDR)
+		v5.GET("/update-price/:metal", middleware.Authorize("report", "write", enforcer), s.UpdatePriceIDR)
 		v5.POST("/", middleware.Authorize("report", "read", enforcer), s.CreateZakatMal)
 		v5.GET("/", middleware.Authorize("report", "write", enforcer), s.GetZakatMals)
 		v5.GET("/:uid", middleware.Authorize("report", "read", enforcer), s.GetZakatMalByID)
 		v5.GET("/:uid/:type", middleware.Authorize("report", "read", enforcer), s.GetZakatMalByType)
+		v5.GET("/:uid/:type/prefill", middleware.Authorize("report", "read", enforcer), s.GetZakatMalPrefill)
 		v5.PUT("/:uid/:type", middleware.Authorize("report", "read", enforcer), s.UpdateZakatMal)
+		v5.PUT("/:uid/:type/:id", middleware.Authorize("report", "read", enforcer), s.UpdateZakatMal)
 		v5.DELETE("/:uid", middleware.Authorize("report", "read", enforcer), s.DeleteZakatMalByID)
 		v5.DELETE("/:uid/:type", middleware.Authorize("report", "read", enforcer), s.DeleteZakatMalByType)
 	}
 
+	v6 := v1.Group("/units", middleware.TokenMiddleware())
+	{
+		v6.GET("/", middleware.Authorize("report", "read", enforcer), s.GetWeightUnits)
+		v6.POST("/", middleware.Authorize("report", "write", enforcer), s.SaveWeightUnit)
+		v6.DELETE("/:id", middleware.Authorize("report", "write", enforcer), s.DeleteWeightUnit)
+	}
+
+	v7 := v1.Group("/prices", middleware.TokenMiddleware())
+	{
+		v7.GET("/proposals", middleware.Authorize("report", "write", enforcer), s.GetPriceProposals)
+		v7.POST("/proposals", middleware.Authorize("report", "write", enforcer), s.ProposePrice)
+		v7.PUT("/proposals/:id/approve", middleware.Authorize("report", "write", enforcer), s.ApprovePrice)
+		v7.PUT("/proposals/:id/reject", middleware.Authorize("report", "write", enforcer), s.RejectPrice)
+		v7.GET("/commodities", middleware.Authorize("report", "read", enforcer), s.GetCommodityPrices)
+		v7.POST("/commodities", middleware.Authorize("report", "write", enforcer), s.CreateCommodityPrice)
+		v7.DELETE("/commodities/:id", middleware.Authorize("report", "write", enforcer), s.DeleteCommodityPrice)
+	}
+
+	v8 := v1.Group("/rulings", middleware.TokenMiddleware())
+	{
+		v8.GET("/", middleware.Authorize("report", "read", enforcer), s.GetRulings)
+		v8.POST("/", middleware.Authorize("report", "write", enforcer), s.CreateRuling)
+	}
+
+	v9 := v1.Group("/recalculations", middleware.TokenMiddleware())
+	{
+		v9.POST("/", middleware.Authorize("report", "write", enforcer), s.CreateRecalculation)
+		v9.GET("/", middleware.Authorize("report", "write", enforcer), s.GetRecalculations)
+		v9.GET("/:id", middleware.Authorize("report", "write", enforcer), s.GetRecalculation)
+		v9.PUT("/:id/apply", middleware.Authorize("report", "write", enforcer), s.ApplyRecalculation)
+		v9.PUT("/:id/cancel", middleware.Authorize("report", "write", enforcer), s.CancelRecalculation)
+	}
+
+	v10 := v1.Group("/zakat-mal-history", middleware.TokenMiddleware())
+	{
+		v10.GET("/:id", middleware.Authorize("report", "write", enforcer), s.GetZakatMalHistory)
+	}
+
+	v11 := v1.Group("/mustahik", middleware.TokenMiddleware())
+	{
+		v11.POST("/", middleware.Authorize("mustahik", "write", enforcer), s.CreateMustahik)
+		v11.GET("/", middleware.Authorize("mustahik", "read", enforcer), s.GetMustahiks)
+		v11.GET("/:id", middleware.Authorize("mustahik", "read", enforcer), s.GetMustahik)
+		v11.PUT("/:id", middleware.Authorize("mustahik", "write", enforcer), s.UpdateMustahik)
+		v11.DELETE("/:id", middleware.Authorize("mustahik", "write", enforcer), s.DeleteMustahik)
+	}
+
+	v12 := v1.Group("/distributions", middleware.TokenMiddleware())
+	{
+		v12.POST("/", middleware.Authorize("distribution", "write", enforcer), s.CreateDistribution)
+		v12.GET("/", middleware.Authorize("distribution", "read", enforcer), s.GetDistributions)
+		v12.GET("/:id", middleware.Authorize("distribution", "read", enforcer), s.GetDistribution)
+		v12.DELETE("/:id", middleware.Authorize("distribution", "write", enforcer), s.DeleteDistribution)
+	}
+
+	v13 := v1.Group("/funds", middleware.TokenMiddleware())
+	{
+		v13.GET("/balances", middleware.Authorize("distribution", "read", enforcer), s.GetFundBalances)
+	}
+
+	v14 := v1.Group("/allocations", middleware.TokenMiddleware())
+	{
+		v14.GET("/rules", middleware.Authorize("distribution", "read", enforcer), s.GetAllocationRules)
+		v14.PUT("/rules", middleware.Authorize("distribution", "write", enforcer), s.SaveAllocationRules)
+		v14.POST("/plans", middleware.Authorize("distribution", "write", enforcer), s.CreateAllocationPlan)
+		v14.GET("/plans", middleware.Authorize("distribution", "read", enforcer), s.GetAllocationPlans)
+		v14.GET("/plans/:id", middleware.Authorize("distribution", "read", enforcer), s.GetAllocationPlan)
+		v14.PUT("/plans/:id", middleware.Authorize("distribution", "write", enforcer), s.AdjustAllocationPlan)
+		v14.PUT("/plans/:id/lock", middleware.Authorize("distribution", "write", enforcer), s.LockAllocationPlan)
+	}
+
+	v15 := v1.Group("/eligibility", middleware.TokenMiddleware())
+	{
+		v15.GET("/thresholds", middleware.Authorize("mustahik", "read", enforcer), s.GetThresholds)
+		v15.PUT("/thresholds", middleware.Authorize("mustahik", "write", enforcer), s.SaveThreshold)
+		v15.POST("/assessments", middleware.Authorize("mustahik", "write", enforcer), s.CreateAssessment)
+		v15.GET("/assessments", middleware.Authorize("mustahik", "read", enforcer), s.GetAssessments)
+		v15.GET("/ranking", middleware.Authorize("mustahik", "read", enforcer), s.GetEligibilityRanking)
+	}
+
+	v16 := v1.Group("/mustahik-verification", middleware.TokenMiddleware())
+	{
+		v16.GET("/:id", middleware.Authorize("mustahik", "read", enforcer), s.GetMustahikVerifications)
+		v16.POST("/:id/survey", middleware.Authorize("mustahik", "survey", enforcer), s.SurveyMustahik)
+		v16.PUT("/:id/approve", middleware.Authorize("mustahik", "approve", enforcer), s.ApproveMustahik)
+		v16.PUT("/:id/reject", middleware.Authorize("mustahik", "approve", enforcer), s.RejectMustahik)
+		v16.PUT("/:id/reverify", middleware.Authorize("mustahik", "approve", enforcer), s.ReverifyMustahik)
+		v16.GET("/attachments/:id", middleware.Authorize("mustahik", "read", enforcer), s.GetMustahikAttachment)
+		v16.POST("/reverify-due", middleware.Authorize("mustahik", "approve", enforcer), s.MarkReverificationDue)
+	}
+
+	v17 := v1.Group("/financings", middleware.TokenMiddleware())
+	{
+		v17.POST("/", middleware.Authorize("financing", "write", enforcer), s.CreateFinancing)
+		v17.GET("/", middleware.Authorize("financing", "read", enforcer), s.GetFinancings)
+		v17.GET("/:id", middleware.Authorize("financing", "read", enforcer), s.GetFinancing)
+		v17.POST("/:id/repayments", middleware.Authorize("financing", "write", enforcer), s.RepayFinancing)
+		v17.PUT("/:id/write-off", middleware.Authorize("financing", "write", enforcer), s.WriteOffFinancing)
+	}
+
+	v18 := v1.Group("/stock", middleware.TokenMiddleware())
+	{
+		v18.GET("/warehouses", middleware.Authorize("distribution", "read", enforcer), s.GetWarehouses)
+		v18.POST("/warehouses", middleware.Authorize("distribution", "write", enforcer), s.CreateWarehouse)
+		v18.GET("/balances", middleware.Authorize("distribution", "read", enforcer), s.GetStockBalances)
+		v18.GET("/movements", middleware.Authorize("distribution", "read", enforcer), s.GetStockMovements)
+		v18.POST("/transfers", middleware.Authorize("distribution", "write", enforcer), s.TransferStock)
+		v18.POST("/purchases", middleware.Authorize("distribution", "write", enforcer), s.PurchaseStock)
+		v18.POST("/adjustments", middleware.Authorize("distribution", "write", enforcer), s.AdjustStock)
+	}
+
+	v19 := v1.Group("/payments", middleware.TokenMiddleware())
+	{
+		v19.POST("/", middleware.Authorize("payment", "write", enforcer), s.CreatePayment)
+		v19.GET("/", middleware.Authorize("payment", "read", enforcer), s.GetPayments)
+		v19.GET("/:id", middleware.Authorize("payment", "read", enforcer), s.GetPayment)
+		v19.GET("/obligations/:type/:id", middleware.Authorize("payment", "read", enforcer), s.GetObligationPayment)
+		v19.POST("/:id/void", middleware.Authorize("payment", "write", enforcer), s.VoidPayment)
+		v19.POST("/:id/refund", middleware.Authorize("payment", "write", enforcer), s.RefundPayment)
+		v19.GET("/corrections", middleware.Authorize("payment", "read", enforcer), s.GetPaymentCorrections)
+		v19.PUT("/corrections/:id/approve", middleware.Authorize("payment", "write", enforcer), s.ApprovePaymentCorrection)
+		v19.PUT("/corrections/:id/reject", middleware.Authorize("payment", "write", enforcer), s.RejectPaymentCorrection)
+	}
+
+	// online payment is only served when a gateway is configured
+	if s.Gateway != nil {
+		v20 := v1.Group("/invoices", middleware.TokenMiddleware())
+		{
+			v20.POST("/", middleware.Authorize("report", "read", enforcer), s.CreateInvoice)
+			v20.GET("/", middleware.Authorize("report", "read", enforcer), s.GetInvoices)
+			v20.GET("/:id", middleware.Authorize("report", "read", enforcer), s.GetInvoice)
+			// the simulator only exists when PAYMENT_SIMULATOR is set for development
+			if s.Gateway.Name() == gateway.SimulatorName {
+				v20.POST("/:id/simulate-payment", middleware.Authorize("report", "read", enforcer), s.SimulateInvoicePayment)
+			}
+		}
+
+		v21 := v1.Group("/webhooks")
+		{
+			v21.POST("/payments/:provider", s.PaymentWebhook)
+		}
+	}
+
+	v22 := v1.Group("/ledger", middleware.TokenMiddleware())
+	{
+		v22.GET("/accounts", middleware.Authorize("ledger", "read", enforcer), s.GetAccounts)
+		v22.GET("/journals", middleware.Authorize("ledger", "read", enforcer), s.GetJournalEntries)
+		v22.POST("/journals", middleware.Authorize("ledger", "write", enforcer), s.CreateJournalEntry)
+		v22.POST("/transfers", middleware.Authorize("ledger", "write", enforcer), s.CreateLedgerTransfer)
+		v22.GET("/balances", middleware.Authorize("ledger", "read", enforcer), s.GetAccountBalances)
+		v22.GET("/trial-balance", middleware.Authorize("ledger", "read", enforcer), s.GetTrialBalance)
+		v22.GET("/periods", middleware.Authorize("ledger", "read", enforcer), s.GetLedgerPeriods)
+		v22.POST("/periods", middleware.Authorize("ledger", "write", enforcer), s.CloseLedgerPeriod)
+	}
+
+	v23 := v1.Group("/statements", middleware.TokenMiddleware())
+	{
+		v23.GET("/financial-position", middleware.Authorize("ledger", "read", enforcer), s.GetFinancialPosition)
+		v23.GET("/fund-changes", middleware.Authorize("ledger", "read", enforcer), s.GetFundChanges)
+		v23.GET("/managed-assets", middleware.Authorize("ledger", "read", enforcer), s.GetManagedAssetChanges)
+	}
+
+	v24 := v1.Group("/receipts", middleware.TokenMiddleware())
+	{
+		v24.GET("/", middleware.Authorize("report", "read", enforcer), s.GetReceipts)
+		v24.GET("/:id", middleware.Authorize("report", "read", enforcer), s.GetReceipt)
+		v24.GET("/:id/pdf", middleware.Authorize("report", "read", enforcer), s.DownloadReceipt)
+		v24.GET("/:id/escpos", middleware.Authorize("report", "read", enforcer), s.PrintReceipt)
+	}
+
+	v25 := v1.Group("/organizations", middleware.TokenMiddleware())
+	{
+		v25.GET("/", middleware.Authorize("report", "write", enforcer), s.GetOrganizations)
+		v25.POST("/", middleware.Authorize("report", "write", enforcer), s.CreateOrganization)
+		v25.PUT("/:id", middleware.Authorize("report", "write", enforcer), s.UpdateOrganization)
+	}
+
+	v26 := v1.Group("/verify")
+	{
+		v26.GET("/receipts", s.VerifyReceipt)
+		v26.GET("/keys", s.GetSigningKeys)
+	}
+
+	v27 := v1.Group("/annual-statements", middleware.TokenMiddleware())
+	{
+		v27.GET("/", middleware.Authorize("report", "read", enforcer), s.GetAnnualStatement)
+		v27.GET("/all", middleware.Authorize("payment", "read", enforcer), s.GetAnnualStatements)
+		v27.GET("/muzakki/:uid", middleware.Authorize("payment", "read", enforcer), s.GetMuzakkiAnnualStatement)
+	}
+
+	v28 := v1.Group("/collection-points", middleware.TokenMiddleware())
+	{
+		v28.GET("/", middleware.Authorize("payment", "read", enforcer), s.GetCollectionPoints)
+		v28.POST("/", middleware.Authorize("payment", "write", enforcer), s.CreateCollectionPoint)
+		v28.PUT("/:id", middleware.Authorize("payment", "write", enforcer), s.UpdateCollectionPoint)
+	}
+
+	v29 := v1.Group("/amil-shares", middleware.TokenMiddleware())
+	{
+		v29.GET("/", middleware.Authorize("ledger", "read", enforcer), s.GetAmilShares)
+		v29.GET("/report", middleware.Authorize("ledger", "read", enforcer), s.GetAmilShareReport)
+		v29.GET("/rules", middleware.Authorize("ledger", "read", enforcer), s.GetAmilShareRules)
+		v29.POST("/rules", middleware.Authorize("ledger", "write", enforcer), s.CreateAmilShareRule)
+	}
+
+	v30 := v1.Group("/commitments", middleware.TokenMiddleware())
+	{
+		v30.POST("/", middleware.Authorize("report", "read", enforcer), s.CreateCommitment)
+		v30.GET("/", middleware.Authorize("report", "read", enforcer), s.GetCommitments)
+		v30.GET("/reminders", middleware.Authorize("report", "read", enforcer), s.GetCommitmentReminders)
+		v30.GET("/all", middleware.Authorize("payment", "read", enforcer), s.GetAllCommitments)
+		v30.GET("/at-risk", middleware.Authorize("payment", "read", enforcer), s.GetCommitmentsAtRisk)
+		v30.POST("/run", middleware.Authorize("payment", "write", enforcer), s.RunCommitmentSchedule)
+		v30.GET("/:id", middleware.Authorize("report", "read", enforcer), s.GetCommitment)
+		v30.PUT("/:id", middleware.Authorize("report", "read", enforcer), s.UpdateCommitment)
+	}
+
+	v31 := v1.Group("/payroll-imports", middleware.TokenMiddleware())
+	{
+		v31.POST("/", middleware.Authorize("payment", "write", enforcer), s.UploadPayrollImport)
+		v31.GET("/", middleware.Authorize("payment", "read", enforcer), s.GetPayrollImports)
+		v31.GET("/:id", middleware.Authorize("payment", "read", enforcer), s.GetPayrollImport)
+		v31.POST("/:id/post", middleware.Authorize("payment", "write", enforcer), s.PostPayrollImport)
+	}
+
 }
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"net/http"
+	"zakat/api/models"
+	"zakat/api/report"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondStatement sends the statement as JSON, or as PDF when format=pdf is asked
+func (s *Server) respondStatement(c *gin.Context, name string, data interface{}, render func() ([]byte, error)) {
+	if c.Query("format") != "pdf" {
+		c.JSON(http.StatusOK, gin.H{
+			"status":   http.StatusOK,
+			"response": data,
+		})
+		return
+	}
+
+	pdf, err := render()
+	if err != nil {
+		errList["Render_failed"] = "Unable to render statement"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", "inline; filename="+name+".pdf")
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+func (s *Server) GetFinancialPosition(c *gin.Context) {
+	errList = map[string]string{}
+
+	start, end, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := models.GetFinancialPosition(s.DB, start, end)
+	if err != nil {
+		errList["No_data"] = "No data financial position"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	s.respondStatement(c, "posisi-keuangan", data, func() ([]byte, error) {
+		return report.FinancialPositionPDF(data)
+	})
+}
+
+func (s *Server) GetFundChanges(c *gin.Context) {
+	errList = map[string]string{}
+
+	start, end, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := models.GetFundChanges(s.DB, start, end)
+	if err != nil {
+		errList["No_data"] = "No data fund changes"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	s.respondStatement(c, "perubahan-dana", data, func() ([]byte, error) {
+		return report.FundChangesPDF(data)
+	})
+}
+
+func (s *Server) GetManagedAssetChanges(c *gin.Context) {
+	errList = map[string]string{}
+
+	start, end, ok := periodQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := models.GetManagedAssetChanges(s.DB, start, end)
+	if err != nil {
+		errList["No_data"] = "No data managed assets"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	s.respondStatement(c, "perubahan-aset-kelolaan", data, func() ([]byte, error) {
+		return report.ManagedAssetChangesPDF(data)
+	})
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"zakat/api/auth"
+	"zakat/api/models"
+	"zakat/api/utils/formaterror"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func (s *Server) CreateWarehouse(c *gin.Context) {
+	errList = map[string]string{}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	w := models.Warehouse{}
+	err = json.Unmarshal(body, &w)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	w.Prepare()
+	errMsg := w.Validate()
+	if len(errMsg) > 0 {
+		errList = errMsg
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := w.SaveWarehouse(s.DB)
+	if err != nil {
+		formattedError := formaterror.FormatError(err.Error())
+		errList = formattedError
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) GetWarehouses(c *gin.Context) {
+	errList = map[string]string{}
+
+	w := models.Warehouse{}
+	data, err := w.GetWarehouses(s.DB)
+	if err != nil {
+		errList["No_data"] = "No data warehouse"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetStockBalances(c *gin.Context) {
+	errList = map[string]string{}
+
+	data, err := models.GetStockBalances(s.DB)
+	if err != nil {
+		errList["No_data"] = "No data stock"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) GetStockMovements(c *gin.Context) {
+	errList = map[string]string{}
+
+	sm := models.StockMovement{}
+	data, err := sm.GetStockMovements(s.DB, c.Query("warehouse_id"), c.Query("type"))
+	if err != nil {
+		errList["No_data"] = "No data stock movement"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) TransferStock(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	from, _ := strconv.Atoi(c.PostForm("from_warehouse_id"))
+	to, _ := strconv.Atoi(c.PostForm("to_warehouse_id"))
+	quantity, _ := strconv.ParseFloat(c.PostForm("quantity"), 64)
+
+	data, err := models.Transfer(s.DB, uint(from), uint(to), quantity, c.PostForm("note"), tokenUID)
+	if err != nil {
+		s.stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) PurchaseStock(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	warehouse, _ := strconv.Atoi(c.PostForm("warehouse_id"))
+	quantity, _ := strconv.ParseFloat(c.PostForm("quantity"), 64)
+	amount, _ := strconv.Atoi(c.PostForm("amount"))
+
+	data, err := models.Purchase(s.DB, uint(warehouse), quantity, amount, c.PostForm("note"), tokenUID)
+	if err != nil {
+		s.stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) AdjustStock(c *gin.Context) {
+	errList = map[string]string{}
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	warehouse, _ := strconv.Atoi(c.PostForm("warehouse_id"))
+	quantity, _ := strconv.ParseFloat(c.PostForm("quantity"), 64)
+
+	data, err := models.Adjust(s.DB, uint(warehouse), quantity, c.PostForm("note"), tokenUID)
+	if err != nil {
+		s.stockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": data,
+	})
+}
+
+func (s *Server) stockError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errList["No_warehouse"] = "No data warehouse"
+	} else {
+		errList["Stock_failed"] = err.Error()
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"status": http.StatusUnprocessableEntity,
+		"error":  errList,
+	})
+}
@@ -52,6 +52,25 @@ func (s *Server) CreateUser(enforcer *casbin.Enforcer) gin.HandlerFunc {
 			return
 		}
 
+		if models.StaffRole(user.Role) {
+			admin := false
+			tokenUID, err := auth.ExtractTokenUID(c.Request)
+			if err == nil && tokenUID != "" {
+				err = enforcer.LoadPolicy()
+				if err == nil {
+					admin, err = enforcer.HasRoleForUser(tokenUID, "admin")
+				}
+			}
+			if err != nil || !admin {
+				errList["Forbidden_role"] = "Only an admin can register a " + user.Role
+				c.JSON(http.StatusForbidden, gin.H{
+					"status": http.StatusForbidden,
+					"error":  errList,
+				})
+				return
+			}
+		}
+
 		data, err := user.SaveUser(s.DB)
 		if err != nil {
 			formattedError := formaterror.FormatError(err.Error())
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"net/http"
+	"zakat/api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyReceipt is public, anyone holding a receipt QR code can check it without logging in
+func (s *Server) VerifyReceipt(c *gin.Context) {
+	errList = map[string]string{}
+
+	number := c.Query("number")
+	if number == "" || c.Query("sig") == "" {
+		errList["Invalid_request"] = "Required number and sig"
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status": http.StatusBadRequest,
+			"error":  errList,
+		})
+		return
+	}
+
+	data, err := models.VerifyReceipt(s.DB, number, c.Query("sig"))
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+// GetSigningKeys publishes the organization public keys so receipts can be verified offline
+func (s *Server) GetSigningKeys(c *gin.Context) {
+	errList = map[string]string{}
+
+	o := models.Organization{}
+	orgs, err := o.GetOrganizations(s.DB)
+	if err != nil {
+		errList["No_data"] = "No data organization"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	keys := []gin.H{}
+	for _, org := range *orgs {
+		keys = append(keys, gin.H{
+			"organization_id": org.ID,
+			"code":            org.Code,
+			"name":            org.Name,
+			"algorithm":       "ed25519",
+			"public_key":      org.PublicKey,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": keys,
+	})
+}
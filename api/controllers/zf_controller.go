@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"math"
 	"net/http"
@@ -14,13 +15,27 @@ import (
 )
 
 func (s *Server) CheckZakatFitrah(c *gin.Context) {
+	errList = map[string]string{}
+
 	total_person, _ := strconv.Atoi(c.PostForm("total_person"))
 
-	total_weight := models.Rice_weight * float64(total_person)
+	ricePrice, err := models.GetRicePrice(s.DB, c.PostForm("region"))
+	if err != nil {
+		errList["Get_fail"] = "failed to get rice price"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	total_weight := math.Ceil(models.Rice_weight*float64(total_person)*100) / 100
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":       http.StatusOK,
-		"total_weight": math.Ceil(total_weight*100) / 100,
+		"total_weight": total_weight,
+		"total_price":  int(math.Ceil(total_weight * ricePrice)),
+		"rice_price":   ricePrice,
 	})
 }
 
@@ -57,7 +72,17 @@ func (s *Server) CreateZakatFitrah(c *gin.Context) {
 		})
 	}
 
-	zf.Prepare(tokenUID)
+	ricePrice, err := models.GetRicePrice(s.DB, zf.Region)
+	if err != nil {
+		errList["Get_fail"] = "failed to get rice price"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	zf.Prepare(tokenUID, ricePrice)
 	errMsg := zf.Validate()
 	if len(errMsg) > 0 {
 		errList = errMsg
@@ -86,6 +111,7 @@ func (s *Server) CreateZakatFitrah(c *gin.Context) {
 			"total_person": data.TotalPerson,
 			"total_weight": data.TotalWeight,
 			"total_price":  data.TotalPrice,
+			"payment_form": data.PaymentForm,
 		},
 	})
 }
@@ -147,10 +173,13 @@ func (s *Server) GetZakatFitrah(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status": http.StatusOK,
 		"response": gin.H{
-			"muzakki_id":   data.IdMuzakki,
-			"total_person": data.TotalPerson,
-			"total_weight": data.TotalWeight,
-			"total_price":  data.TotalPrice,
+			"muzakki_id":     data.IdMuzakki,
+			"total_person":   data.TotalPerson,
+			"total_weight":   data.TotalWeight,
+			"total_price":    data.TotalPrice,
+			"payment_form":   data.PaymentForm,
+			"paid":           data.Paid,
+			"payment_status": data.PaymentStatus,
 		},
 	})
 }
@@ -213,7 +242,17 @@ func (s *Server) UpdateZakatFitrah(c *gin.Context) {
 
 	zf.ID = oriZF.ID
 
-	zf.Prepare(mID)
+	ricePrice, err := models.GetRicePrice(s.DB, zf.Region)
+	if err != nil {
+		errList["Get_fail"] = "failed to get rice price"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+
+	zf.Prepare(mID, ricePrice)
 	errMsg := zf.Validate()
 	if len(errMsg) > 0 {
 		errList = errMsg
@@ -241,6 +280,7 @@ func (s *Server) UpdateZakatFitrah(c *gin.Context) {
 			"total_person": data.TotalPerson,
 			"total_weight": data.TotalWeight,
 			"total_price":  data.TotalPrice,
+			"payment_form": data.PaymentForm,
 		},
 	})
 }
@@ -271,6 +311,22 @@ func (s *Server) DeleteZakatFitrah(c *gin.Context) {
 
 	zf := models.ZakatFitrah{}
 	_, err = zf.DeleteZakatFitrah(s.DB, mID)
+	if errors.Is(err, models.ErrObligationHasPayments) {
+		errList["Has_payments"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+	if errors.Is(err, models.ErrInsufficientStock) {
+		errList["Insufficient_stock"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
 	if err != nil {
 		errList["Other_error"] = "Please try again later"
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -2,10 +2,12 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 	"zakat/api/auth"
 	"zakat/api/models"
 	"zakat/api/utils/formaterror"
@@ -16,55 +18,66 @@ import (
 func (s *Server) CheckZakatMal(c *gin.Context) {
 	errList = map[string]string{}
 
-	var idr models.PriceIdr
-	metal := c.PostForm("metal")
+	metal := strings.ToLower(c.PostForm("metal"))
 	total_harta, _ := strconv.ParseFloat(c.PostForm("assest"), 64)
 	total_wegiht, _ := strconv.ParseFloat(c.PostForm("weight"), 64)
-
-	getIdr, err := idr.GetIDR(metal, s.DB)
+	irrigated, _ := strconv.ParseBool(c.PostForm("irrigated"))
+	current_assets, _ := strconv.Atoi(c.PostForm("current_assets"))
+	current_liabilities, _ := strconv.Atoi(c.PostForm("current_liabilities"))
+	adjustment, _ := strconv.Atoi(c.PostForm("adjustment"))
+	gross_income, _ := strconv.Atoi(c.PostForm("gross_income"))
+	expenses, _ := strconv.Atoi(c.PostForm("expenses"))
+
+	zm := models.ZakatMal{
+		TypeZakat:          metal,
+		TotalWeight:        total_wegiht,
+		TotalAssest:        int(total_harta),
+		Unit:               c.PostForm("unit"),
+		Region:             c.PostForm("region"),
+		Commodity:          strings.ToLower(c.PostForm("commodity")),
+		Irrigated:          irrigated,
+		CurrentAssets:      current_assets,
+		CurrentLiabilities: current_liabilities,
+		Adjustment:         adjustment,
+		GrossIncome:        gross_income,
+		Expenses:           expenses,
+	}
+	if from, err := time.Parse("2006-01-02", c.PostForm("income_from")); err == nil {
+		zm.IncomeFrom = &from
+	}
+	if to, err := time.Parse("2006-01-02", c.PostForm("income_to")); err == nil {
+		zm.IncomeTo = &to
+	}
+
+	err := zm.NormalizeWeight(s.DB)
 	if err != nil {
-		errList["Get_fail"] = "failed to get IDR price"
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": http.StatusInternalServerError,
+		errList["Invalid_unit"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
 			"error":  errList,
 		})
 		return
 	}
 
-	var price_weight float64
-	if metal == "emas" || metal == "perak" {
-		price_weight = total_wegiht * getIdr.IdrPrice
-	}
-
-	var pay_zakat float64
-	if total_harta > getIdr.GetNisab && metal == "dagang" {
-		pay_zakat = (total_harta * 2.5) / 100
-
-		c.JSON(http.StatusOK, map[string]interface{}{
-			"message":         "check zakat dagang success",
-			"total_zakat_mal": pay_zakat,
-		})
-		return
-	} else if price_weight > getIdr.GetNisab && metal == "emas" {
-		pay_zakat = (price_weight * 2.5) / 100
-
-		c.JSON(http.StatusOK, map[string]interface{}{
-			"message":         "check zakat emas success",
-			"total_zakat_mal": pay_zakat,
+	pay_zakat, err := zm.Calculate(s.DB)
+	if errors.Is(err, models.ErrNotObligated) {
+		c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"message": "tidak wajib membayar zakat",
 		})
 		return
-	} else if price_weight > getIdr.GetNisab && metal == "perak" {
-		pay_zakat = (price_weight * 2.5) / 100
-
-		c.JSON(http.StatusOK, map[string]interface{}{
-			"message":         "check zakat perak success",
-			"total_zakat_mal": pay_zakat,
+	}
+	if err != nil {
+		errList["Get_fail"] = "failed to get price"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
 		})
 		return
 	}
 
-	c.JSON(http.StatusInternalServerError, map[string]interface{}{
-		"message": "tidak wajib membayar zakat",
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message":         "check zakat " + metal + " success",
+		"total_zakat_mal": pay_zakat,
 	})
 }
 
@@ -92,36 +105,32 @@ func (s *Server) CreateZakatMal(c *gin.Context) {
 		return
 	}
 
-	idr := models.PriceIdr{}
-	getIdr, err := idr.GetIDR(zm.TypeZakat, s.DB)
+	err = zm.NormalizeWeight(s.DB)
 	if err != nil {
-		errList["Get_fail"] = "failed to get IDR price"
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": http.StatusInternalServerError,
+		errList["Invalid_unit"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
 			"error":  errList,
 		})
 		return
 	}
 
-	var pw float64
-	if strings.ToLower(zm.TypeZakat) == "emas" || strings.ToLower(zm.TypeZakat) == "perak" {
-		pw = zm.TotalWeight * getIdr.IdrPrice
-	}
-
-	var pay float64
-	if float64(zm.TotalAssest) > getIdr.GetNisab && strings.ToLower(zm.TypeZakat) == "dagang" {
-		pay = (float64(zm.TotalAssest) * 2.5) / 100
-	} else if pw > getIdr.GetNisab && strings.ToLower(zm.TypeZakat) == "emas" {
-		pay = (pw * 2.5) / 100
-	} else if pw > getIdr.GetNisab && strings.ToLower(zm.TypeZakat) == "perak" {
-		pay = (pw * 2.5) / 100
-	} else {
+	pay, err := zm.Calculate(s.DB)
+	if errors.Is(err, models.ErrNotObligated) {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  http.StatusInternalServerError,
 			"message": "tidak wajib membayar zakat",
 		})
 		return
 	}
+	if err != nil {
+		errList["Get_fail"] = "failed to get price"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
 
 	tokenUID, err := auth.ExtractTokenUID(c.Request)
 	if err != nil {
@@ -158,10 +167,14 @@ func (s *Server) CreateZakatMal(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"status": http.StatusCreated,
 		"response": gin.H{
+			"id":           data.ID,
 			"id_muzakki":   data.IdMuzakki,
 			"type_zakat":   data.TypeZakat,
 			"total_weight": data.TotalWeight,
+			"unit":         data.Unit,
+			"unit_weight":  data.UnitWeight,
 			"total_assest": data.TotalAssest,
+			"commodity":    data.Commodity,
 			"total_zakat":  data.TotalZakat,
 		},
 	})
@@ -270,13 +283,17 @@ func (s *Server) GetZakatMalByType(c *gin.Context) {
 			"id_muzakki":   data.IdMuzakki,
 			"type_zakat":   data.TypeZakat,
 			"total_weight": data.TotalWeight,
+			"unit":         data.Unit,
+			"unit_weight":  data.UnitWeight,
 			"total_assest": data.TotalAssest,
+			"commodity":    data.Commodity,
 			"total_zakat":  data.TotalZakat,
 		},
 	})
 }
 
-func (s *Server) UpdateZakatMal(c *gin.Context) {
+// GetZakatMalPrefill drafts a repeat annual filing from the assets filed before, only mustaghallat is supported
+func (s *Server) GetZakatMalPrefill(c *gin.Context) {
 	errList = map[string]string{}
 
 	typeZakat := c.Param("type")
@@ -301,8 +318,17 @@ func (s *Server) UpdateZakatMal(c *gin.Context) {
 		return
 	}
 
-	oriZM := models.ZakatMal{}
-	err = s.DB.Debug().Model(&models.ZakatMal{}).Where("id_muzakki = ? AND type_zakat = ?", mID, typeZakat).Take(&oriZM).Error
+	if typeZakat != "mustaghallat" {
+		errList["Invalid_type"] = "prefill is only available for zakat mustaghallat"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+
+	zm := models.ZakatMal{}
+	data, err := zm.GetMustaghallatPrefill(s.DB, mID)
 	if err != nil {
 		errList["No_data"] = "No data zakat mal"
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -312,6 +338,62 @@ func (s *Server) UpdateZakatMal(c *gin.Context) {
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": data,
+	})
+}
+
+func (s *Server) UpdateZakatMal(c *gin.Context) {
+	errList = map[string]string{}
+
+	typeZakat := c.Param("type")
+	mID := c.Param("uid")
+
+	tokenUID, err := auth.ExtractTokenUID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	if mID != tokenUID {
+		errList["Unauthorized"] = "Unauthorized"
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status": http.StatusUnauthorized,
+			"error":  errList,
+		})
+		return
+	}
+
+	// a muzakki may keep several filings of one type, mustaghallat has one per asset and year, so they go by id
+	filings := []models.ZakatMal{}
+	query := s.DB.Debug().Model(&models.ZakatMal{}).Where("id_muzakki = ? AND type_zakat = ?", mID, typeZakat)
+	if id := c.Param("id"); id != "" {
+		query = query.Where("id = ?", id)
+	}
+	err = query.Limit(2).Find(&filings).Error
+	if err != nil || len(filings) == 0 {
+		errList["No_data"] = "No data zakat mal"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
+	if len(filings) > 1 {
+		errList["Required_id"] = "there are several zakat mal of this type, choose one by id"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
+	oriZM := filings[0]
+
 	body, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
 		errList["Invalid_body"] = "Unable to get request"
@@ -333,36 +415,32 @@ func (s *Server) UpdateZakatMal(c *gin.Context) {
 		return
 	}
 
-	idr := models.PriceIdr{}
-	getIdr, err := idr.GetIDR(zm.TypeZakat, s.DB)
+	err = zm.NormalizeWeight(s.DB)
 	if err != nil {
-		errList["Get_fail"] = "failed to get IDR price"
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": http.StatusInternalServerError,
+		errList["Invalid_unit"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
 			"error":  errList,
 		})
 		return
 	}
 
-	var pw float64
-	if strings.ToLower(zm.TypeZakat) == "emas" || strings.ToLower(zm.TypeZakat) == "perak" {
-		pw = zm.TotalWeight * getIdr.IdrPrice
-	}
-
-	var pay float64
-	if float64(zm.TotalAssest) > getIdr.GetNisab && strings.ToLower(zm.TypeZakat) == "dagang" {
-		pay = (float64(zm.TotalAssest) * 2.5) / 100
-	} else if pw > getIdr.GetNisab && strings.ToLower(zm.TypeZakat) == "emas" {
-		pay = (pw * 2.5) / 100
-	} else if pw > getIdr.GetNisab && strings.ToLower(zm.TypeZakat) == "perak" {
-		pay = (pw * 2.5) / 100
-	} else {
+	pay, err := zm.Calculate(s.DB)
+	if errors.Is(err, models.ErrNotObligated) {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  http.StatusInternalServerError,
 			"message": "tidak wajib membayar zakat",
 		})
 		return
 	}
+	if err != nil {
+		errList["Get_fail"] = "failed to get price"
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": http.StatusInternalServerError,
+			"error":  errList,
+		})
+		return
+	}
 
 	zm.ID = oriZM.ID
 	zm.Prepare(mID, pay)
@@ -377,6 +455,14 @@ func (s *Server) UpdateZakatMal(c *gin.Context) {
 	}
 
 	data, err := zm.UpdateZakatMal(s.DB, zm.TypeZakat)
+	if errors.Is(err, models.ErrObligationHasPayments) {
+		errList["Has_payments"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
 	if err != nil {
 		formattedError := formaterror.FormatError(err.Error())
 		errList = formattedError
@@ -393,7 +479,10 @@ func (s *Server) UpdateZakatMal(c *gin.Context) {
 			"id_muzakki":   data.IdMuzakki,
 			"type_zakat":   data.TypeZakat,
 			"total_weight": data.TotalWeight,
+			"unit":         data.Unit,
+			"unit_weight":  data.UnitWeight,
 			"total_assest": data.TotalAssest,
+			"commodity":    data.Commodity,
 			"total_zakat":  data.TotalZakat,
 		},
 	})
@@ -427,6 +516,14 @@ func (s *Server) DeleteZakatMalByID(c *gin.Context) {
 	zm := models.ZakatMal{}
 
 	_, err = zm.DeleteZakatMalByID(mID, s.DB)
+	if errors.Is(err, models.ErrObligationHasPayments) {
+		errList["Has_payments"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
 	if err != nil {
 		errList["Other_error"] = "Please try again later"
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -469,6 +566,14 @@ func (s *Server) DeleteZakatMalByType(c *gin.Context) {
 
 	zm := models.ZakatMal{}
 	_, err = zm.DeleteZakatMalByType(mID, typeZakat, s.DB)
+	if errors.Is(err, models.ErrObligationHasPayments) {
+		errList["Has_payments"] = err.Error()
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status": http.StatusUnprocessableEntity,
+			"error":  errList,
+		})
+		return
+	}
 	if err != nil {
 		errList["Other_error"] = "Please try again later"
 		c.JSON(http.StatusInternalServerError, gin.H{
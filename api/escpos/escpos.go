@@ -0,0 +1,180 @@
+package escpos
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	esc = 0x1B
+	gs  = 0x1D
+	lf  = 0x0A
+
+	AlignLeft   = 0
+	AlignCenter = 1
+	AlignRight  = 2
+
+	// Width58 is the number of font A characters on a 58mm roll
+	Width58 = 32
+)
+
+// Builder collects ESC/POS commands, the output only depends on the calls made so it can be compared byte for byte
+type Builder struct {
+	buf   bytes.Buffer
+	Width int
+}
+
+func New(width int) *Builder {
+	b := &Builder{Width: width}
+	b.buf.Write([]byte{esc, '@'})
+
+	return b
+}
+
+func (b *Builder) Align(align int) *Builder {
+	b.buf.Write([]byte{esc, 'a', byte(align)})
+	return b
+}
+
+func (b *Builder) Bold(on bool) *Builder {
+	b.buf.Write([]byte{esc, 'E', flag(on)})
+	return b
+}
+
+// DoubleSize doubles width and height, a line then holds half the characters
+func (b *Builder) DoubleSize(on bool) *Builder {
+	size := byte(0x00)
+	if on {
+		size = 0x11
+	}
+	b.buf.Write([]byte{gs, '!', size})
+	return b
+}
+
+// Line prints the text wrapped to the paper width
+func (b *Builder) Line(text string) *Builder {
+	for _, line := range Wrap(ascii(text), b.Width) {
+		b.buf.WriteString(line)
+		b.buf.WriteByte(lf)
+	}
+	return b
+}
+
+// Pair prints a label on the left and a value flushed right on one line
+func (b *Builder) Pair(left, right string) *Builder {
+	left, right = ascii(left), ascii(right)
+	gap := b.Width - len(left) - len(right)
+	if gap < 1 {
+		return b.Line(left).Align(AlignRight).Line(right).Align(AlignLeft)
+	}
+	b.buf.WriteString(left + strings.Repeat(" ", gap) + right)
+	b.buf.WriteByte(lf)
+	return b
+}
+
+// Field prints "label : value" with the value wrapped under itself
+func (b *Builder) Field(label string, labelWidth int, value string) *Builder {
+	prefix := padRight(ascii(label), labelWidth) + ": "
+	indent := strings.Repeat(" ", len(prefix))
+	for i, line := range Wrap(ascii(value), b.Width-len(prefix)) {
+		if i == 0 {
+			b.buf.WriteString(prefix + line)
+		} else {
+			b.buf.WriteString(indent + line)
+		}
+		b.buf.WriteByte(lf)
+	}
+	return b
+}
+
+func (b *Builder) Rule() *Builder {
+	b.buf.WriteString(strings.Repeat("-", b.Width))
+	b.buf.WriteByte(lf)
+	return b
+}
+
+func (b *Builder) Feed(lines int) *Builder {
+	b.buf.Write([]byte{esc, 'd', byte(lines)})
+	return b
+}
+
+// QR prints a model 2 QR code with error correction M, module size is 1 to 16 dots
+func (b *Builder) QR(data string, module int) *Builder {
+	n := len(data) + 3
+	b.buf.Write([]byte{gs, '(', 'k', 4, 0, '1', 'A', '2', 0})
+	b.buf.Write([]byte{gs, '(', 'k', 3, 0, '1', 'C', byte(module)})
+	b.buf.Write([]byte{gs, '(', 'k', 3, 0, '1', 'E', '1'})
+	b.buf.Write([]byte{gs, '(', 'k', byte(n % 256), byte(n / 256), '1', 'P', '0'})
+	b.buf.WriteString(data)
+	b.buf.Write([]byte{gs, '(', 'k', 3, 0, '1', 'Q', '0'})
+	b.buf.WriteByte(lf)
+	return b
+}
+
+// Cut feeds the paper past the cutter and makes a partial cut
+func (b *Builder) Cut() *Builder {
+	b.buf.Write([]byte{gs, 'V', 'B', 0})
+	return b
+}
+
+func (b *Builder) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// Wrap breaks text on spaces so no line is longer than width, longer words are split
+func Wrap(text string, width int) []string {
+	lines := []string{}
+	current := ""
+	for _, word := range strings.Fields(text) {
+		for len(word) > width {
+			if current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			lines = append(lines, word[:width])
+			word = word[width:]
+		}
+		switch {
+		case current == "":
+			current = word
+		case len(current)+1+len(word) <= width:
+			current += " " + word
+		default:
+			lines = append(lines, current)
+			current = word
+		}
+	}
+	if current != "" || len(lines) == 0 {
+		lines = append(lines, current)
+	}
+
+	return lines
+}
+
+// ascii replaces what the printer code page can not show
+func ascii(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r >= 0x20 && r < 0x7F {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('?')
+		}
+	}
+
+	return b.String()
+}
+
+func padRight(text string, width int) string {
+	if len(text) >= width {
+		return text
+	}
+	return text + strings.Repeat(" ", width-len(text))
+}
+
+func flag(on bool) byte {
+	if on {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package escpos
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  []string
+	}{
+		{"", 10, []string{""}},
+		{"satu dua tiga", 20, []string{"satu dua tiga"}},
+		{"satu dua tiga", 8, []string{"satu dua", "tiga"}},
+		{"  banyak   spasi  ", 20, []string{"banyak spasi"}},
+		{"abcdefghijkl", 5, []string{"abcde", "fghij", "kl"}},
+		{"ok abcdefghijkl", 5, []string{"ok", "abcde", "fghij", "kl"}},
+	}
+
+	for _, tt := range tests {
+		if got := Wrap(tt.text, tt.width); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Wrap(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	got := New(16).Align(AlignCenter).Bold(true).Line("Zakat").Bold(false).
+		Align(AlignLeft).Pair("TOTAL", "Rp 10").Field("ID", 4, "M-01").Rule().Feed(2).Cut().Bytes()
+
+	want := []byte{esc, '@', esc, 'a', 1, esc, 'E', 1}
+	want = append(want, "Zakat\n"...)
+	want = append(want, esc, 'E', 0, esc, 'a', 0)
+	want = append(want, "TOTAL      Rp 10\n"...)
+	want = append(want, "ID  : M-01\n"...)
+	want = append(want, "----------------\n"...)
+	want = append(want, esc, 'd', 2, gs, 'V', 'B', 0)
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("Bytes() = %q, want %q", got, want)
+	}
+}
+
+func TestPairTooLong(t *testing.T) {
+	got := New(10).Pair("JUMLAH TOTAL", "Rp 1.000").Bytes()
+
+	want := []byte{esc, '@'}
+	want = append(want, "JUMLAH\nTOTAL\n"...)
+	want = append(want, esc, 'a', 2)
+	want = append(want, "Rp 1.000\n"...)
+	want = append(want, esc, 'a', 0)
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("Pair() = %q, want %q", got, want)
+	}
+}
+
+func TestFieldWrapsUnderValue(t *testing.T) {
+	got := New(16).Field("Nama", 4, "Abdullah bin Umar").Bytes()
+
+	want := []byte{esc, '@'}
+	want = append(want, "Nama: Abdullah\n"...)
+	want = append(want, "      bin Umar\n"...)
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("Field() = %q, want %q", got, want)
+	}
+}
+
+func TestASCII(t *testing.T) {
+	if got, want := ascii("Rp 5.000 – café"), "Rp 5.000 ? caf?"; got != want {
+		t.Errorf("ascii() = %q, want %q", got, want)
+	}
+}
+
+func TestQR(t *testing.T) {
+	data := "https://zakat.example/verify?number=1"
+	got := New(Width58).QR(data, 5).Bytes()
+
+	n := len(data) + 3
+	want := []byte{esc, '@'}
+	want = append(want, gs, '(', 'k', 4, 0, '1', 'A', '2', 0)
+	want = append(want, gs, '(', 'k', 3, 0, '1', 'C', 5)
+	want = append(want, gs, '(', 'k', 3, 0, '1', 'E', '1')
+	want = append(want, gs, '(', 'k', byte(n%256), byte(n/256), '1', 'P', '0')
+	want = append(want, data...)
+	want = append(want, gs, '(', 'k', 3, 0, '1', 'Q', '0', lf)
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("QR() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	ChannelQris = "qris"
+	ChannelVA   = "va"
+
+	StatusPending = "pending"
+	StatusPaid    = "paid"
+	StatusExpired = "expired"
+
+	SignatureHeader = "X-Callback-Signature"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid callback signature")
+	ErrUnknownInvoice   = errors.New("unknown invoice")
+	ErrNoGateway        = errors.New("required payment gateway name")
+	ErrNoSecret         = errors.New("required webhook secret")
+	ErrSimulatorOff     = errors.New("the payment simulator is only available in development")
+)
+
+type InvoiceRequest struct {
+	Reference string
+	Customer  string
+	Amount    int
+	Channel   string
+	Bank      string
+	Expiry    time.Duration
+}
+
+type Invoice struct {
+	ExternalID  string
+	Channel     string
+	Amount      int
+	QrisPayload string
+	VaNumber    string
+	Bank        string
+	ExpiresAt   time.Time
+}
+
+// Callback is the body a provider posts to the webhook once an invoice changes state
+type Callback struct {
+	ExternalID string    `json:"external_id"`
+	Status     string    `json:"status"`
+	Amount     int       `json:"amount"`
+	PaidAt     time.Time `json:"paid_at"`
+}
+
+// Gateway is implemented by every online payment provider
+type Gateway interface {
+	Name() string
+	CreateInvoice(req InvoiceRequest) (*Invoice, error)
+	GetStatus(externalID string) (string, error)
+	ParseCallback(body []byte, signature string) (*Callback, error)
+}
+
+// New returns the provider configured by name, callbacks are never accepted without a secret and the simulator,
+// which settles invoices on request, only runs when the development flag allows it
+func New(name, secret string, simulator bool) (Gateway, error) {
+	if name == "" {
+		return nil, ErrNoGateway
+	}
+	if secret == "" {
+		return nil, ErrNoSecret
+	}
+
+	switch name {
+	case SimulatorName:
+		if !simulator {
+			return nil, ErrSimulatorOff
+		}
+		return NewSimulator(secret), nil
+	}
+
+	return nil, errors.New("unknown payment gateway " + name)
+}
+
+// Sign returns the hex HMAC-SHA256 of a callback body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifySignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,52 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		gateway   string
+		secret    string
+		simulator bool
+		err       error
+	}{
+		{"no gateway", "", "secret", true, ErrNoGateway},
+		{"no secret", SimulatorName, "", true, ErrNoSecret},
+		{"simulator outside development", SimulatorName, "secret", false, ErrSimulatorOff},
+		{"simulator in development", SimulatorName, "secret", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(tt.gateway, tt.secret, tt.simulator)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("New() error = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && g.Name() != tt.gateway {
+				t.Errorf("New() name = %s, want %s", g.Name(), tt.gateway)
+			}
+		})
+	}
+
+	if _, err := New("unknown", "secret", true); err == nil {
+		t.Error("New() accepted an unknown gateway")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"external_id":"SIM-1","status":"paid","amount":50000}`)
+	signature := Sign("secret", body)
+
+	if !VerifySignature("secret", body, signature) {
+		t.Error("VerifySignature() rejected its own signature")
+	}
+	if VerifySignature("other", body, signature) {
+		t.Error("VerifySignature() accepted a signature made with another secret")
+	}
+	if VerifySignature("secret", append(body, ' '), signature) {
+		t.Error("VerifySignature() accepted a changed body")
+	}
+}
@@ -0,0 +1,51 @@
+package gateway
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// QrisPayload builds a dynamic QRIS string following the EMVCo merchant presented layout
+func QrisPayload(merchant, city, reference string, amount int) string {
+	payload := tlv("00", "01") +
+		tlv("01", "12") +
+		tlv("26", tlv("00", "ID.CO.QRIS.WWW")+tlv("01", "936000000000000000")+tlv("02", reference)) +
+		tlv("52", "8661") +
+		tlv("53", "360") +
+		tlv("54", strconv.Itoa(amount)) +
+		tlv("58", "ID") +
+		tlv("59", truncate(merchant, 25)) +
+		tlv("60", truncate(city, 15)) +
+		tlv("62", tlv("05", truncate(reference, 25))) +
+		"6304"
+
+	return payload + fmt.Sprintf("%04X", crc16(payload))
+}
+
+func tlv(tag, value string) string {
+	return fmt.Sprintf("%s%02d%s", tag, len(value), value)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// crc16 is CRC-16/CCITT-FALSE as required for the QRIS checksum
+func crc16(s string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
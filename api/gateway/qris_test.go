@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCrc16(t *testing.T) {
+	// the published check value of CRC-16/CCITT-FALSE
+	if got := crc16("123456789"); got != 0x29B1 {
+		t.Errorf("crc16() = %04X, want 29B1", got)
+	}
+	if got := crc16(""); got != 0xFFFF {
+		t.Errorf("crc16() of nothing = %04X, want FFFF", got)
+	}
+}
+
+func TestQrisPayload(t *testing.T) {
+	payload := QrisPayload("KALKULATOR ZAKAT DAN INFAQ NASIONAL", "JAKARTA SELATAN RAYA", "SIM-000001", 150000)
+
+	body, sum := payload[:len(payload)-4], payload[len(payload)-4:]
+	if !strings.HasSuffix(body, "6304") {
+		t.Fatalf("QrisPayload() does not end with the checksum tag: %s", payload)
+	}
+	if want := fmt.Sprintf("%04X", crc16(body)); sum != want {
+		t.Errorf("QrisPayload() checksum = %s, want %s", sum, want)
+	}
+
+	tags := map[string]string{}
+	for rest := body[:len(body)-4]; len(rest) > 0; {
+		if len(rest) < 4 {
+			t.Fatalf("QrisPayload() has a broken field at %q", rest)
+		}
+		n, err := strconv.Atoi(rest[2:4])
+		if err != nil || len(rest) < 4+n {
+			t.Fatalf("QrisPayload() has a broken length at %q", rest)
+		}
+		tags[rest[:2]] = rest[4 : 4+n]
+		rest = rest[4+n:]
+	}
+
+	want := map[string]string{
+		"00": "01",
+		"01": "12",
+		"53": "360",
+		"54": "150000",
+		"58": "ID",
+		"59": "KALKULATOR ZAKAT DAN INFA",
+		"60": "JAKARTA SELATAN",
+		"62": "0510SIM-000001",
+	}
+	for tag, value := range want {
+		if tags[tag] != value {
+			t.Errorf("QrisPayload() tag %s = %q, want %q", tag, tags[tag], value)
+		}
+	}
+}
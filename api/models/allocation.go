@@ -0,0 +1,373 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AllocationRule struct {
+	gorm.Model
+	Asnaf      string  `gorm:"size:50;not null;unique" json:"asnaf"`
+	Percentage float64 `gorm:"not null;default:0" json:"percentage"`
+	Priority   int     `gorm:"not null;default:0" json:"priority"`
+}
+
+type AllocationPlan struct {
+	gorm.Model
+	PeriodStart   time.Time            `gorm:"not null" json:"period_start"`
+	PeriodEnd     time.Time            `gorm:"not null" json:"period_end"`
+	CollectedCash int                  `gorm:"not null;default:0" json:"collected_cash"`
+	CollectedRice float64              `gorm:"not null;default:0" json:"collected_rice"`
+	Status        string               `gorm:"size:20;not null;default:draft" json:"status"`
+	CreatedBy     string               `gorm:"size:255;not null" json:"created_by"`
+	LockedBy      string               `gorm:"size:255" json:"locked_by"`
+	LockedAt      *time.Time           `json:"locked_at"`
+	Items         []AllocationPlanItem `gorm:"foreignKey:AllocationPlanID" json:"items"`
+}
+
+type AllocationPlanItem struct {
+	gorm.Model
+	AllocationPlanID uint    `gorm:"not null" json:"allocation_plan_id"`
+	Asnaf            string  `gorm:"size:50;not null" json:"asnaf"`
+	Percentage       float64 `gorm:"not null" json:"percentage"`
+	Cash             int     `gorm:"not null;default:0" json:"cash"`
+	Rice             float64 `gorm:"not null;default:0" json:"rice"`
+}
+
+const (
+	PlanDraft  = "draft"
+	PlanLocked = "locked"
+
+	// amil may receive at most 1/8 of the collected zakat
+	Amil_cap = 12.5
+)
+
+var ErrAllocationExceeded = errors.New("distribution exceeds the asnaf allocation of the locked plan")
+
+func (ar *AllocationRule) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if !ValidAsnaf(ar.Asnaf) {
+		err = errors.New("asnaf must be fakir, miskin, amil, muallaf, riqab, gharim, fisabilillah, or ibnu sabil")
+		errMsg["Invalid_asnaf"] = err.Error()
+	}
+	if ar.Percentage < 0 || ar.Percentage > 100 {
+		err = errors.New("percentage must be between 0 and 100")
+		errMsg["Invalid_percentage"] = err.Error()
+	}
+	if ar.Asnaf == AsnafAmil && ar.Percentage > Amil_cap {
+		err = errors.New("amil share can not exceed 1/8")
+		errMsg["Invalid_amil"] = err.Error()
+	}
+
+	return errMsg
+}
+
+func ValidateAllocationRules(rules []AllocationRule) map[string]string {
+	var errMsg = make(map[string]string)
+	var total float64
+
+	for i := range rules {
+		rules[i].Asnaf = strings.TrimSpace(strings.ToLower(rules[i].Asnaf))
+		for key, value := range rules[i].Validate() {
+			errMsg[key] = value
+		}
+		total += rules[i].Percentage
+	}
+	if total > 100 {
+		errMsg["Invalid_total"] = "total percentage can not exceed 100"
+	}
+
+	return errMsg
+}
+
+func (ar *AllocationRule) GetAllocationRules(db *gorm.DB) (*[]AllocationRule, error) {
+	rules := []AllocationRule{}
+	err := db.Debug().Model(&AllocationRule{}).Order("priority, asnaf").Find(&rules).Error
+	if err != nil {
+		return &[]AllocationRule{}, err
+	}
+
+	return &rules, nil
+}
+
+// SaveAllocationRules replaces the configured rules as a whole
+func SaveAllocationRules(db *gorm.DB, rules []AllocationRule) (*[]AllocationRule, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Debug().Unscoped().Where("1 = 1").Delete(&AllocationRule{}).Error
+		if err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+
+		return tx.Debug().Create(&rules).Error
+	})
+	if err != nil {
+		return &[]AllocationRule{}, err
+	}
+
+	return &rules, nil
+}
+
+// CollectedInPeriod sums the zakat paid between start and end net of refunds and the amil share, cash in rupiah
+// and rice in kg
+func CollectedInPeriod(db *gorm.DB, start, end time.Time) (int, float64, error) {
+	var cash, rice float64
+	for _, fund := range []string{FundZakatFitrah, FundZakatMal} {
+		paid, err := collected(db, fund, false, start, end)
+		if err != nil {
+			return 0, 0, err
+		}
+		share, err := amilSharesOf(db, fund, false, start, end)
+		if err != nil {
+			return 0, 0, err
+		}
+		cash += paid - share
+	}
+
+	paid, err := collected(db, FundZakatFitrah, true, start, end)
+	if err != nil {
+		return 0, 0, err
+	}
+	share, err := amilSharesOf(db, FundZakatFitrah, true, start, end)
+	if err != nil {
+		return 0, 0, err
+	}
+	rice = paid - share
+
+	return int(cash), rice, nil
+}
+
+// ProposeShares turns the rules into a percentage per asnaf, the remainder goes to the highest priority asnaf
+func ProposeShares(rules []AllocationRule) map[string]float64 {
+	shares := map[string]float64{}
+	if len(rules) == 0 {
+		for _, asnaf := range Asnaf {
+			shares[asnaf] = 100 / float64(len(Asnaf))
+		}
+		return shares
+	}
+
+	var total float64
+	for _, rule := range rules {
+		percentage := rule.Percentage
+		if rule.Asnaf == AsnafAmil && percentage > Amil_cap {
+			percentage = Amil_cap
+		}
+		shares[rule.Asnaf] = percentage
+		total += percentage
+	}
+
+	if total < 100 {
+		sorted := make([]AllocationRule, len(rules))
+		copy(sorted, rules)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].Priority < sorted[j].Priority
+		})
+		for _, rule := range sorted {
+			if rule.Asnaf != AsnafAmil {
+				shares[rule.Asnaf] += 100 - total
+				break
+			}
+		}
+	}
+
+	return shares
+}
+
+func (ap *AllocationPlan) applyShares(shares map[string]float64) {
+	items := []AllocationPlanItem{}
+	for _, asnaf := range Asnaf {
+		percentage, ok := shares[asnaf]
+		if !ok {
+			continue
+		}
+		items = append(items, AllocationPlanItem{
+			Asnaf:      asnaf,
+			Percentage: percentage,
+			Cash:       int(math.Floor(float64(ap.CollectedCash) * percentage / 100)),
+			Rice:       math.Floor(ap.CollectedRice*percentage) / 100,
+		})
+	}
+	ap.Items = items
+}
+
+func (ap *AllocationPlan) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var total float64
+
+	if ap.PeriodStart.IsZero() || ap.PeriodEnd.IsZero() || ap.PeriodEnd.Before(ap.PeriodStart) {
+		errMsg["Invalid_period"] = "period end must be after period start"
+	}
+	for _, item := range ap.Items {
+		if !ValidAsnaf(item.Asnaf) {
+			errMsg["Invalid_asnaf"] = "asnaf must be fakir, miskin, amil, muallaf, riqab, gharim, fisabilillah, or ibnu sabil"
+		}
+		if item.Percentage < 0 {
+			errMsg["Invalid_percentage"] = "percentage can not be negative"
+		}
+		if item.Asnaf == AsnafAmil && item.Percentage > Amil_cap {
+			errMsg["Invalid_amil"] = "amil share can not exceed 1/8"
+		}
+		total += item.Percentage
+	}
+	if total > 100 {
+		errMsg["Invalid_total"] = "total percentage can not exceed 100"
+	}
+
+	return errMsg
+}
+
+func (ap *AllocationPlan) ProposePlan(db *gorm.DB, uid string) (*AllocationPlan, error) {
+	cash, rice, err := CollectedInPeriod(db, ap.PeriodStart, ap.PeriodEnd)
+	if err != nil {
+		return &AllocationPlan{}, err
+	}
+
+	rules := []AllocationRule{}
+	err = db.Debug().Model(&AllocationRule{}).Find(&rules).Error
+	if err != nil {
+		return &AllocationPlan{}, err
+	}
+
+	ap.CollectedCash = cash
+	ap.CollectedRice = rice
+	ap.Status = PlanDraft
+	ap.CreatedBy = uid
+	ap.applyShares(ProposeShares(rules))
+
+	err = db.Debug().Create(&ap).Error
+	if err != nil {
+		return &AllocationPlan{}, err
+	}
+
+	return ap, nil
+}
+
+func (ap *AllocationPlan) GetAllocationPlans(db *gorm.DB) (*[]AllocationPlan, error) {
+	plans := []AllocationPlan{}
+	err := db.Debug().Model(&AllocationPlan{}).Preload("Items").Order("period_start desc").Find(&plans).Error
+	if err != nil {
+		return &[]AllocationPlan{}, err
+	}
+
+	return &plans, nil
+}
+
+func (ap *AllocationPlan) GetAllocationPlan(db *gorm.DB, id string) (*AllocationPlan, error) {
+	err := db.Debug().Model(&AllocationPlan{}).Preload("Items").Where("id = ?", id).Take(&ap).Error
+	if err != nil {
+		return &AllocationPlan{}, err
+	}
+
+	return ap, nil
+}
+
+// AdjustPlan replaces the percentages of a draft plan and recomputes the amounts
+func (ap *AllocationPlan) AdjustPlan(db *gorm.DB, shares map[string]float64) (*AllocationPlan, error) {
+	if ap.Status != PlanDraft {
+		return &AllocationPlan{}, errors.New("allocation plan is already " + ap.Status)
+	}
+
+	ap.applyShares(shares)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Debug().Unscoped().Where("allocation_plan_id = ?", ap.ID).Delete(&AllocationPlanItem{}).Error
+		if err != nil {
+			return err
+		}
+		for i := range ap.Items {
+			ap.Items[i].AllocationPlanID = ap.ID
+		}
+		if len(ap.Items) == 0 {
+			return nil
+		}
+
+		return tx.Debug().Create(&ap.Items).Error
+	})
+	if err != nil {
+		return &AllocationPlan{}, err
+	}
+
+	return ap, nil
+}
+
+func (ap *AllocationPlan) LockPlan(db *gorm.DB, uid string) (*AllocationPlan, error) {
+	if ap.Status != PlanDraft {
+		return &AllocationPlan{}, errors.New("allocation plan is already " + ap.Status)
+	}
+
+	var overlap int64
+	err := db.Debug().Model(&AllocationPlan{}).Where("status = ? AND period_start <= ? AND period_end >= ?", PlanLocked, ap.PeriodEnd, ap.PeriodStart).Count(&overlap).Error
+	if err != nil {
+		return &AllocationPlan{}, err
+	}
+	if overlap > 0 {
+		return &AllocationPlan{}, errors.New("another locked plan already covers this period")
+	}
+
+	now := time.Now()
+	err = db.Debug().Model(&AllocationPlan{}).Where("id = ?", ap.ID).Updates(AllocationPlan{
+		Status:   PlanLocked,
+		LockedBy: uid,
+		LockedAt: &now,
+	}).Error
+	if err != nil {
+		return &AllocationPlan{}, err
+	}
+
+	ap.Status = PlanLocked
+	ap.LockedBy = uid
+	ap.LockedAt = &now
+
+	return ap, nil
+}
+
+// CheckAllocation makes sure a distribution stays within the asnaf share of the locked plan covering its date
+func CheckAllocation(db *gorm.DB, d *Distribution) error {
+	plan := AllocationPlan{}
+	err := db.Debug().Model(&AllocationPlan{}).Preload("Items").Where("status = ? AND period_start <= ? AND period_end >= ?", PlanLocked, d.DistributedAt, d.DistributedAt).Take(&plan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var allocated float64
+	for _, item := range plan.Items {
+		if item.Asnaf != d.Asnaf {
+			continue
+		}
+		allocated = float64(item.Cash)
+		if d.Kind == KindRice {
+			allocated = item.Rice
+		}
+	}
+
+	column := "amount"
+	requested := float64(d.Amount)
+	if d.Kind == KindRice {
+		column = "weight"
+		requested = d.Weight
+	}
+
+	var distributed float64
+	err = db.Debug().Model(&Distribution{}).Where("asnaf = ? AND kind = ? AND distributed_at BETWEEN ? AND ?", d.Asnaf, d.Kind, plan.PeriodStart, plan.PeriodEnd).Select("COALESCE(SUM(" + column + "), 0)").Scan(&distributed).Error
+	if err != nil {
+		return err
+	}
+
+	if distributed+requested > allocated {
+		return ErrAllocationExceeded
+	}
+
+	return nil
+}
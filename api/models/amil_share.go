@@ -0,0 +1,276 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AmilShareRule is the share of each payment the amil are entitled to, the latest rule valid at payment time applies
+type AmilShareRule struct {
+	gorm.Model
+	ZakatPercentage float64   `gorm:"not null;default:0" json:"zakat_percentage"`
+	InfaqPercentage float64   `gorm:"not null;default:0" json:"infaq_percentage"`
+	ValidFrom       time.Time `gorm:"not null;index" json:"valid_from"`
+	Note            string    `gorm:"size:255" json:"note"`
+	CreatedBy       string    `gorm:"size:255;not null" json:"created_by"`
+}
+
+// AmilShare is the amil portion booked for one payment
+type AmilShare struct {
+	gorm.Model
+	PaymentID         uint      `gorm:"not null;index" json:"payment_id"`
+	AmilShareRuleID   uint      `gorm:"not null;default:0" json:"amil_share_rule_id"`
+	Fund              string    `gorm:"size:50;not null" json:"fund"`
+	CollectionPointID *uint     `gorm:"index" json:"collection_point_id"`
+	Base              int       `gorm:"not null" json:"base"`
+	Percentage        float64   `gorm:"not null" json:"percentage"`
+	Amount            int       `gorm:"not null" json:"amount"`
+	BookedAt          time.Time `gorm:"not null;index" json:"booked_at"`
+}
+
+type AmilShareReport struct {
+	PeriodStart time.Time            `json:"period_start"`
+	PeriodEnd   time.Time            `json:"period_end"`
+	Rows        []AmilShareReportRow `json:"rows"`
+	Points      []AmilShareReportRow `json:"collection_points"`
+	Base        int                  `json:"base"`
+	Amount      int                  `json:"amount"`
+}
+
+// AmilShareReportRow sums the shares of one month and collection point, the collection point totals leave Period empty
+type AmilShareReportRow struct {
+	Period            string `json:"period,omitempty"`
+	CollectionPointID *uint  `json:"collection_point_id"`
+	CollectionPoint   string `json:"collection_point"`
+	ZakatBase         int    `json:"zakat_base"`
+	ZakatShare        int    `json:"zakat_share"`
+	InfaqBase         int    `json:"infaq_base"`
+	InfaqShare        int    `json:"infaq_share"`
+	Amount            int    `json:"amount"`
+	Payments          int    `json:"payments"`
+}
+
+// Default_collection_point names the shares of payments that were not booked on a collection point
+const Default_collection_point = "Kantor"
+
+func (r *AmilShareRule) Prepare(uid string) {
+	r.Note = html.EscapeString(strings.TrimSpace(r.Note))
+	if r.ValidFrom.IsZero() {
+		r.ValidFrom = time.Now()
+	}
+	r.CreatedBy = uid
+}
+
+func (r *AmilShareRule) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if r.ZakatPercentage < 0 || r.ZakatPercentage > Amil_cap {
+		err = errors.New("amil share of zakat must be between 0 and 1/8")
+		errMsg["Invalid_zakatPercentage"] = err.Error()
+	}
+	if r.InfaqPercentage < 0 || r.InfaqPercentage > 100 {
+		err = errors.New("amil share of infaq must be between 0 and 100")
+		errMsg["Invalid_infaqPercentage"] = err.Error()
+	}
+
+	return errMsg
+}
+
+func (r *AmilShareRule) SaveAmilShareRule(db *gorm.DB) (*AmilShareRule, error) {
+	err := db.Debug().Create(&r).Error
+	if err != nil {
+		return &AmilShareRule{}, err
+	}
+
+	return r, nil
+}
+
+func (r *AmilShareRule) GetAmilShareRules(db *gorm.DB) (*[]AmilShareRule, error) {
+	rules := []AmilShareRule{}
+	err := db.Debug().Model(&AmilShareRule{}).Order("valid_from DESC").Find(&rules).Error
+	if err != nil {
+		return &[]AmilShareRule{}, err
+	}
+
+	return &rules, nil
+}
+
+// GetAmilShareRule returns the rule valid at the given time, nothing is taken for amil until an admin sets a rule
+func GetAmilShareRule(db *gorm.DB, at time.Time) (*AmilShareRule, error) {
+	rule := AmilShareRule{}
+	err := db.Debug().Model(&AmilShareRule{}).Where("valid_from <= ?", at).Order("valid_from DESC").Take(&rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &AmilShareRule{ValidFrom: at}, nil
+	}
+	if err != nil {
+		return &AmilShareRule{}, err
+	}
+
+	return &rule, nil
+}
+
+// BookAmilShare computes the amil share of a payment and moves it to the amil fund
+func BookAmilShare(tx *gorm.DB, p *Payment) (*AmilShare, error) {
+	rule, err := GetAmilShareRule(tx, p.PaidAt)
+	if err != nil {
+		return &AmilShare{}, err
+	}
+
+	share := AmilShare{
+		PaymentID:         p.ID,
+		AmilShareRuleID:   rule.ID,
+		Fund:              FundZakat,
+		CollectionPointID: p.CollectionPointID,
+		Base:              p.Amount,
+		Percentage:        rule.ZakatPercentage,
+		BookedAt:          p.PaidAt,
+	}
+	if p.ObligationType == FundInfaqSadaqah {
+		share.Fund = FundInfaqSadaqah
+		share.Percentage = rule.InfaqPercentage
+	}
+	share.Amount = int(math.Floor(float64(share.Base) * share.Percentage / 100))
+	if share.Amount <= 0 {
+		return &AmilShare{}, nil
+	}
+
+	err = tx.Debug().Create(&share).Error
+	if err != nil {
+		return &AmilShare{}, err
+	}
+
+	err = PostAmilShareJournal(tx, &share, p.ReceivedBy)
+	if err != nil {
+		return &AmilShare{}, err
+	}
+
+	return &share, nil
+}
+
+func (s *AmilShare) GetAmilShares(db *gorm.DB, start, end time.Time, collectionPoint string) (*[]AmilShare, error) {
+	shares := []AmilShare{}
+
+	query := db.Debug().Model(&AmilShare{}).Where("booked_at BETWEEN ? AND ?", start, end)
+	if collectionPoint != "" {
+		query = query.Where("collection_point_id = ?", collectionPoint)
+	}
+	err := query.Order("booked_at").Find(&shares).Error
+	if err != nil {
+		return &[]AmilShare{}, err
+	}
+
+	return &shares, nil
+}
+
+func (row *AmilShareReportRow) add(share AmilShare) {
+	if share.Fund == FundInfaqSadaqah {
+		row.InfaqBase += share.Base
+		row.InfaqShare += share.Amount
+	} else {
+		row.ZakatBase += share.Base
+		row.ZakatShare += share.Amount
+	}
+	row.Amount += share.Amount
+	// voids and refunds show up as negative shares, they are not payments of their own
+	if share.Amount > 0 {
+		row.Payments++
+	}
+}
+
+// GetAmilShareReport sums the amil shares of a period per month and collection point
+func GetAmilShareReport(db *gorm.DB, start, end time.Time) (*AmilShareReport, error) {
+	shares := AmilShare{}
+	list, err := shares.GetAmilShares(db, start, end, "")
+	if err != nil {
+		return &AmilShareReport{}, err
+	}
+
+	points := []CollectionPoint{}
+	err = db.Debug().Model(&CollectionPoint{}).Find(&points).Error
+	if err != nil {
+		return &AmilShareReport{}, err
+	}
+	names := map[uint]string{}
+	for _, point := range points {
+		names[point.ID] = point.Name
+	}
+
+	report := AmilShareReport{PeriodStart: start, PeriodEnd: end}
+	rows := map[string]*AmilShareReportRow{}
+	totals := map[uint]*AmilShareReportRow{}
+	for _, share := range *list {
+		var pointID uint
+		name := Default_collection_point
+		if share.CollectionPointID != nil {
+			pointID = *share.CollectionPointID
+			name = names[pointID]
+		}
+
+		period := share.BookedAt.Format("2006-01")
+		key := fmt.Sprintf("%s|%d", period, pointID)
+		if rows[key] == nil {
+			rows[key] = &AmilShareReportRow{Period: period, CollectionPointID: share.CollectionPointID, CollectionPoint: name}
+		}
+		rows[key].add(share)
+
+		if totals[pointID] == nil {
+			totals[pointID] = &AmilShareReportRow{CollectionPointID: share.CollectionPointID, CollectionPoint: name}
+		}
+		totals[pointID].add(share)
+
+		report.Base += share.Base
+		report.Amount += share.Amount
+	}
+
+	report.Rows = []AmilShareReportRow{}
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		if report.Rows[i].Period != report.Rows[j].Period {
+			return report.Rows[i].Period < report.Rows[j].Period
+		}
+		return report.Rows[i].CollectionPoint < report.Rows[j].CollectionPoint
+	})
+
+	report.Points = []AmilShareReportRow{}
+	for _, total := range totals {
+		report.Points = append(report.Points, *total)
+	}
+	sort.Slice(report.Points, func(i, j int) bool {
+		return report.Points[i].CollectionPoint < report.Points[j].CollectionPoint
+	})
+
+	return &report, nil
+}
+
+// amilSharesOf sums the amil shares booked on the payments of one obligation type, reversals included, the share
+// of rice paid in kind is turned back into kg at the value the payment was booked at
+func amilSharesOf(db *gorm.DB, obligationType string, inKind bool, start, end time.Time) (float64, error) {
+	var total float64
+	query := db.Debug().Model(&AmilShare{}).Joins("JOIN payments ON payments.id = amil_shares.payment_id").
+		Where("payments.obligation_type = ?", obligationType)
+	if !start.IsZero() {
+		query = query.Where("amil_shares.booked_at >= ?", start)
+	}
+	if !end.IsZero() {
+		query = query.Where("amil_shares.booked_at <= ?", end)
+	}
+	if inKind {
+		query = query.Where("payments.method = ?", MethodInKind).
+			Select("COALESCE(SUM(amil_shares.amount * payments.weight / NULLIF(payments.amount, 0)), 0)")
+	} else {
+		query = query.Where("payments.method <> ?", MethodInKind).Select("COALESCE(SUM(amil_shares.amount), 0)")
+	}
+	err := query.Scan(&total).Error
+
+	return total, err
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"errors"
+	"time"
+	"zakat/api/utils/terbilang"
+
+	"gorm.io/gorm"
+)
+
+type AnnualStatementLine struct {
+	ReceiptNumber string    `json:"receipt_number"`
+	PaidAt        time.Time `json:"paid_at"`
+	ZakatType     string    `json:"zakat_type"`
+	Method        string    `json:"method"`
+	Amount        int       `json:"amount"`
+	Deductible    bool      `json:"deductible"`
+}
+
+type AnnualStatementTotal struct {
+	ObligationType string `json:"obligation_type"`
+	Amount         int    `json:"amount"`
+	Deductible     bool   `json:"deductible"`
+}
+
+// AnnualStatement sums what a muzakki paid in one Gregorian year, only zakat counts for the income tax deduction
+// so infaq and other payments are totalled on their own
+type AnnualStatement struct {
+	Year           int                    `json:"year"`
+	OrgName        string                 `json:"org_name"`
+	OrgAddress     string                 `json:"org_address"`
+	IdMuzakki      string                 `json:"id_muzakki"`
+	MuzakkiName    string                 `json:"muzakki_name"`
+	MuzakkiAddress string                 `json:"muzakki_address"`
+	Npwp           string                 `json:"npwp"`
+	Npwz           string                 `json:"npwz"`
+	Lines          []AnnualStatementLine  `json:"lines"`
+	Totals         []AnnualStatementTotal `json:"totals"`
+	Total          int                    `json:"total"`
+	TotalWords     string                 `json:"total_words"`
+	OtherTotal     int                    `json:"other_total"`
+	GeneratedAt    time.Time              `json:"generated_at"`
+}
+
+// deductible tells whether a payment of the obligation type reduces the gross income, only zakat does
+func deductible(obligationType string) bool {
+	return obligationType == FundZakatFitrah || obligationType == FundZakatMal
+}
+
+func yearRange(year int) (time.Time, time.Time) {
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.Local)
+	return start, start.AddDate(1, 0, 0)
+}
+
+// GetAnnualStatement builds the statement from the issued receipts, voided ones are left out
+func GetAnnualStatement(db *gorm.DB, muzakkiID string, year int) (*AnnualStatement, error) {
+	// a muzakki who never filled in a profile still gets a statement
+	muzakki := Muzakki{MuzakkiId: muzakkiID}
+	err := db.Debug().Model(&Muzakki{}).Where("muzakki_id = ?", muzakkiID).Take(&muzakki).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &AnnualStatement{}, err
+	}
+
+	org, err := GetReceiptOrganization(db, nil)
+	if err != nil {
+		return &AnnualStatement{}, err
+	}
+
+	start, end := yearRange(year)
+	rows := []struct {
+		Receipt
+		ObligationType string
+		RefundedAmount int
+	}{}
+	err = db.Debug().Model(&Receipt{}).
+		Select("receipts.*, payments.obligation_type, payments.refunded_amount").
+		Joins("JOIN payments ON payments.id = receipts.payment_id").
+		Where("receipts.id_muzakki = ? AND receipts.status = ? AND receipts.issued_at >= ? AND receipts.issued_at < ?", muzakkiID, ReceiptIssued, start, end).
+		Order("receipts.issued_at").Scan(&rows).Error
+	if err != nil {
+		return &AnnualStatement{}, err
+	}
+
+	as := AnnualStatement{
+		Year:           year,
+		OrgName:        org.Name,
+		OrgAddress:     org.Address,
+		IdMuzakki:      muzakki.MuzakkiId,
+		MuzakkiName:    muzakki.DisplayName(),
+		MuzakkiAddress: muzakki.Address,
+		Npwp:           muzakki.Npwp,
+		Npwz:           muzakki.Npwz,
+		Lines:          []AnnualStatementLine{},
+		Totals:         []AnnualStatementTotal{},
+		GeneratedAt:    time.Now(),
+	}
+	totals := map[string]int{}
+	order := []string{}
+	for _, row := range rows {
+		// a partial refund keeps the receipt, only what was kept counts
+		row.Amount -= row.RefundedAmount
+		as.Lines = append(as.Lines, AnnualStatementLine{
+			ReceiptNumber: row.Number,
+			PaidAt:        row.IssuedAt,
+			ZakatType:     row.ZakatType,
+			Method:        row.Method,
+			Amount:        row.Amount,
+			Deductible:    deductible(row.ObligationType),
+		})
+		if _, ok := totals[row.ObligationType]; !ok {
+			order = append(order, row.ObligationType)
+		}
+		totals[row.ObligationType] += row.Amount
+		if deductible(row.ObligationType) {
+			as.Total += row.Amount
+		} else {
+			as.OtherTotal += row.Amount
+		}
+	}
+	for _, obligationType := range order {
+		as.Totals = append(as.Totals, AnnualStatementTotal{
+			ObligationType: obligationType,
+			Amount:         totals[obligationType],
+			Deductible:     deductible(obligationType),
+		})
+	}
+	as.TotalWords = terbilang.Rupiah(as.Total)
+
+	return &as, nil
+}
+
+// GetAnnualStatements builds the statement of every muzakki with at least one receipt in the year
+func GetAnnualStatements(db *gorm.DB, year int) ([]AnnualStatement, error) {
+	start, end := yearRange(year)
+
+	ids := []string{}
+	err := db.Debug().Model(&Receipt{}).Distinct("receipts.id_muzakki").
+		Where("receipts.status = ? AND receipts.issued_at >= ? AND receipts.issued_at < ?", ReceiptIssued, start, end).
+		Order("receipts.id_muzakki").Pluck("receipts.id_muzakki", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	statements := []AnnualStatement{}
+	for _, id := range ids {
+		as, err := GetAnnualStatement(db, id, year)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, *as)
+	}
+
+	return statements, nil
+}
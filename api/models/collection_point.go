@@ -0,0 +1,112 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// CollectionPoint is a place where payments are taken in, a mosque counter, an UPZ, or the office itself
+type CollectionPoint struct {
+	gorm.Model
+	Code           string `gorm:"size:20;not null;unique" json:"code"`
+	Name           string `gorm:"size:255;not null" json:"name"`
+	Address        string `gorm:"size:255" json:"address"`
+	OrganizationID *uint  `json:"organization_id"`
+	IsActive       bool   `gorm:"not null;default:true" json:"is_active"`
+}
+
+var ErrCollectionPointClosed = errors.New("collection point does not exist or is closed")
+
+func (cp *CollectionPoint) Prepare() {
+	cp.Code = html.EscapeString(strings.TrimSpace(strings.ToUpper(cp.Code)))
+	cp.Name = html.EscapeString(strings.TrimSpace(cp.Name))
+	cp.Address = html.EscapeString(strings.TrimSpace(cp.Address))
+}
+
+func (cp *CollectionPoint) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if cp.Code == "" {
+		err = errors.New("required code")
+		errMsg["Required_code"] = err.Error()
+	}
+	if cp.Name == "" {
+		err = errors.New("required name")
+		errMsg["Required_name"] = err.Error()
+	}
+
+	return errMsg
+}
+
+func (cp *CollectionPoint) SaveCollectionPoint(db *gorm.DB) (*CollectionPoint, error) {
+	cp.IsActive = true
+	err := db.Debug().Create(&cp).Error
+	if err != nil {
+		return &CollectionPoint{}, err
+	}
+
+	return cp, nil
+}
+
+func (cp *CollectionPoint) UpdateCollectionPoint(db *gorm.DB) (*CollectionPoint, error) {
+	err := db.Debug().Model(&CollectionPoint{}).Where("id = ?", cp.ID).Updates(map[string]interface{}{
+		"name":            cp.Name,
+		"address":         cp.Address,
+		"organization_id": cp.OrganizationID,
+		"is_active":       cp.IsActive,
+	}).Error
+	if err != nil {
+		return &CollectionPoint{}, err
+	}
+
+	err = db.Debug().Model(&CollectionPoint{}).Where("id = ?", cp.ID).Take(&cp).Error
+	if err != nil {
+		return &CollectionPoint{}, err
+	}
+
+	return cp, nil
+}
+
+func (cp *CollectionPoint) GetCollectionPoints(db *gorm.DB) (*[]CollectionPoint, error) {
+	points := []CollectionPoint{}
+	err := db.Debug().Model(&CollectionPoint{}).Order("code").Find(&points).Error
+	if err != nil {
+		return &[]CollectionPoint{}, err
+	}
+
+	return &points, nil
+}
+
+func (cp *CollectionPoint) GetCollectionPoint(db *gorm.DB, id string) (*CollectionPoint, error) {
+	err := db.Debug().Model(&CollectionPoint{}).Where("id = ?", id).Take(&cp).Error
+	if err != nil {
+		return &CollectionPoint{}, err
+	}
+
+	return cp, nil
+}
+
+// activeCollectionPoint rejects payments booked on an unknown or closed collection point
+func activeCollectionPoint(db *gorm.DB, id *uint) error {
+	if id == nil {
+		return nil
+	}
+
+	cp := CollectionPoint{}
+	err := db.Debug().Model(&CollectionPoint{}).Where("id = ?", *id).Take(&cp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrCollectionPointClosed
+	}
+	if err != nil {
+		return err
+	}
+	if !cp.IsActive {
+		return ErrCollectionPointClosed
+	}
+
+	return nil
+}
@@ -0,0 +1,508 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Commitment is a muzakki's promise to pay zakat profesi every period, the scheduler turns it into zakat mal obligations
+type Commitment struct {
+	gorm.Model
+	IdMuzakki       string     `gorm:"column:id_muzakki;size:255;not null;index" json:"id_muzakki"`
+	TypeZakat       string     `gorm:"size:50;not null;default:profesi" json:"type_zakat"`
+	Basis           string     `gorm:"size:20;not null" json:"basis"`
+	Amount          int        `gorm:"not null;default:0" json:"amount"`
+	Income          int        `gorm:"not null;default:0" json:"income"`
+	Frequency       string     `gorm:"size:20;not null;default:monthly" json:"frequency"`
+	StartDate       time.Time  `gorm:"not null" json:"start_date"`
+	EndDate         *time.Time `json:"end_date"`
+	DueDay          int        `gorm:"not null;default:10" json:"due_day"`
+	PreferredMethod string     `gorm:"size:20;not null" json:"preferred_method"`
+	Status          string     `gorm:"size:20;not null;default:active" json:"status"`
+	GeneratedUntil  *time.Time `json:"generated_until"`
+	CreatedBy       string     `gorm:"size:255;not null" json:"created_by"`
+	Obligations     []ZakatMal `gorm:"foreignKey:CommitmentID" json:"obligations,omitempty"`
+}
+
+// CommitmentReminder is kept for the muzakki to read, one per obligation and kind
+type CommitmentReminder struct {
+	gorm.Model
+	CommitmentID uint      `gorm:"not null;index" json:"commitment_id"`
+	ZakatMalID   uint      `gorm:"not null;uniqueIndex:idx_commitment_reminder" json:"zakat_mal_id"`
+	Kind         string    `gorm:"size:20;not null;uniqueIndex:idx_commitment_reminder" json:"kind"`
+	IdMuzakki    string    `gorm:"column:id_muzakki;size:255;not null;index" json:"id_muzakki"`
+	Period       string    `gorm:"size:7;not null" json:"period"`
+	DueDate      time.Time `gorm:"not null" json:"due_date"`
+	Amount       int       `gorm:"not null" json:"amount"`
+	Message      string    `gorm:"size:255;not null" json:"message"`
+}
+
+type ScheduleResult struct {
+	RunAt       time.Time `json:"run_at"`
+	Commitments int       `json:"commitments"`
+	Generated   int       `json:"generated"`
+	NotObliged  int       `json:"not_obliged"`
+	Reminders   int       `json:"reminders"`
+	Ended       int       `json:"ended"`
+	Failed      int       `json:"failed"`
+}
+
+// CommitmentRisk is a commitment with obligations past their due date that are not paid in full
+type CommitmentRisk struct {
+	Commitment  Commitment `json:"commitment"`
+	MuzakkiName string     `json:"muzakki_name"`
+	Mobile      string     `json:"mobile"`
+	Missed      int        `json:"missed"`
+	MissedFrom  string     `json:"missed_from"`
+	Outstanding int        `json:"outstanding"`
+	LastPaidAt  *time.Time `json:"last_paid_at"`
+}
+
+const (
+	BasisAmount = "amount"
+	BasisIncome = "income"
+
+	FrequencyMonthly   = "monthly"
+	FrequencyQuarterly = "quarterly"
+	FrequencyYearly    = "yearly"
+
+	CommitmentActive = "active"
+	CommitmentPaused = "paused"
+	CommitmentEnded  = "ended"
+
+	ReminderDue     = "due"
+	ReminderOverdue = "overdue"
+
+	// a due reminder goes out this many days before the due date
+	Reminder_days = 3
+	// commitments with this many missed periods show up on the at-risk dashboard
+	Default_missed = 2
+)
+
+var frequencyMonths = map[string]int{
+	FrequencyMonthly:   1,
+	FrequencyQuarterly: 3,
+	FrequencyYearly:    12,
+}
+
+func (cm *Commitment) Prepare(uid string) {
+	cm.IdMuzakki = uid
+	cm.TypeZakat = "profesi"
+	cm.Basis = strings.TrimSpace(strings.ToLower(cm.Basis))
+	cm.Frequency = strings.TrimSpace(strings.ToLower(cm.Frequency))
+	if cm.Frequency == "" {
+		cm.Frequency = FrequencyMonthly
+	}
+	cm.PreferredMethod = strings.TrimSpace(strings.ToLower(cm.PreferredMethod))
+	if cm.PreferredMethod == "" {
+		cm.PreferredMethod = MethodTransfer
+	}
+	if cm.StartDate.IsZero() {
+		cm.StartDate = time.Now()
+	}
+	// periods always start on the first of the month
+	cm.StartDate = time.Date(cm.StartDate.Year(), cm.StartDate.Month(), 1, 0, 0, 0, 0, time.Local)
+	if cm.DueDay == 0 {
+		cm.DueDay = 10
+	}
+	if cm.Status == "" {
+		cm.Status = CommitmentActive
+	}
+	cm.Status = strings.TrimSpace(strings.ToLower(cm.Status))
+	cm.CreatedBy = uid
+	cm.GeneratedUntil = nil
+	cm.Obligations = nil
+}
+
+func (cm *Commitment) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	switch cm.Basis {
+	case BasisAmount:
+		if cm.Amount <= 0 {
+			err = errors.New("required amount")
+			errMsg["Required_amount"] = err.Error()
+		}
+	case BasisIncome:
+		if cm.Income <= 0 {
+			err = errors.New("required monthly income")
+			errMsg["Required_income"] = err.Error()
+		}
+	default:
+		err = errors.New("basis must be amount or income")
+		errMsg["Invalid_basis"] = err.Error()
+	}
+	if _, ok := frequencyMonths[cm.Frequency]; !ok {
+		err = errors.New("frequency must be monthly, quarterly, or yearly")
+		errMsg["Invalid_frequency"] = err.Error()
+	}
+	if cm.EndDate != nil && cm.EndDate.Before(cm.StartDate) {
+		err = errors.New("end date must be after start date")
+		errMsg["Invalid_endDate"] = err.Error()
+	}
+	if cm.DueDay < 1 || cm.DueDay > 28 {
+		err = errors.New("due day must be between 1 and 28")
+		errMsg["Invalid_dueDay"] = err.Error()
+	}
+	if cm.PreferredMethod != MethodCash && cm.PreferredMethod != MethodTransfer && cm.PreferredMethod != MethodQris {
+		err = errors.New("preferred method must be cash, transfer, or qris")
+		errMsg["Invalid_method"] = err.Error()
+	}
+	if cm.Status != CommitmentActive && cm.Status != CommitmentPaused && cm.Status != CommitmentEnded {
+		err = errors.New("status must be active, paused, or ended")
+		errMsg["Invalid_status"] = err.Error()
+	}
+
+	return errMsg
+}
+
+func (cm *Commitment) SaveCommitment(db *gorm.DB) (*Commitment, error) {
+	err := db.Debug().Create(&cm).Error
+	if err != nil {
+		return &Commitment{}, err
+	}
+
+	return cm, nil
+}
+
+// UpdateCommitment changes the terms of the periods still to come, obligations already generated stay as they are;
+// a commitment resumed after a pause moves its watermark to the current period so the paused periods are skipped
+func (cm *Commitment) UpdateCommitment(db *gorm.DB) (*Commitment, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		old := Commitment{}
+		err := tx.Debug().Model(&Commitment{}).Where("id = ?", cm.ID).Take(&old).Error
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"basis":            cm.Basis,
+			"amount":           cm.Amount,
+			"income":           cm.Income,
+			"end_date":         cm.EndDate,
+			"due_day":          cm.DueDay,
+			"preferred_method": cm.PreferredMethod,
+			"status":           cm.Status,
+		}
+		if old.Status == CommitmentPaused && cm.Status == CommitmentActive {
+			if periods := old.periods(time.Now()); len(periods) > 0 {
+				updates["generated_until"] = periods[len(periods)-1]
+			}
+		}
+
+		return tx.Debug().Model(&Commitment{}).Where("id = ?", cm.ID).Updates(updates).Error
+	})
+	if err != nil {
+		return &Commitment{}, err
+	}
+
+	return cm.GetCommitment(db, fmt.Sprint(cm.ID))
+}
+
+func (cm *Commitment) GetCommitment(db *gorm.DB, id string) (*Commitment, error) {
+	err := db.Debug().Model(&Commitment{}).Preload("Obligations", func(db *gorm.DB) *gorm.DB {
+		return db.Order("period")
+	}).Where("id = ?", id).Take(&cm).Error
+	if err != nil {
+		return &Commitment{}, err
+	}
+
+	err = LoadZakatMalPayments(db, cm.Obligations)
+	if err != nil {
+		return &Commitment{}, err
+	}
+
+	return cm, nil
+}
+
+func (cm *Commitment) GetCommitments(db *gorm.DB, muzakki, status string) (*[]Commitment, error) {
+	commitments := []Commitment{}
+
+	query := db.Debug().Model(&Commitment{})
+	if muzakki != "" {
+		query = query.Where("id_muzakki = ?", muzakki)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at desc").Find(&commitments).Error
+	if err != nil {
+		return &[]Commitment{}, err
+	}
+
+	return &commitments, nil
+}
+
+func GetCommitmentReminders(db *gorm.DB, muzakki string) (*[]CommitmentReminder, error) {
+	reminders := []CommitmentReminder{}
+	err := db.Debug().Model(&CommitmentReminder{}).Where("id_muzakki = ?", muzakki).Order("created_at desc").Find(&reminders).Error
+	if err != nil {
+		return &[]CommitmentReminder{}, err
+	}
+
+	return &reminders, nil
+}
+
+// periods lists the first day of every period that has started by now
+func (cm *Commitment) periods(now time.Time) []time.Time {
+	step := frequencyMonths[cm.Frequency]
+	if step == 0 {
+		step = 1
+	}
+
+	periods := []time.Time{}
+	for start := cm.StartDate; !start.After(now); start = start.AddDate(0, step, 0) {
+		if cm.EndDate != nil && start.After(*cm.EndDate) {
+			break
+		}
+		periods = append(periods, start)
+	}
+
+	return periods
+}
+
+// due computes the zakat of one period, a fixed amount or the engine applied to the monthly income
+func (cm *Commitment) due(db *gorm.DB) (int, error) {
+	if cm.Basis == BasisAmount {
+		return cm.Amount, nil
+	}
+
+	zm := ZakatMal{TypeZakat: cm.TypeZakat, TotalAssest: cm.Income}
+	pay, err := zm.Calculate(db)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(pay) * frequencyMonths[cm.Frequency], nil
+}
+
+// generate creates the obligations of the periods that have started since the watermark and have none yet, the
+// watermark then moves to the last period looked at so a period is never generated twice or after a pause
+func (cm *Commitment) generate(db *gorm.DB, now time.Time, result *ScheduleResult) error {
+	existing := []string{}
+	err := db.Debug().Model(&ZakatMal{}).Where("commitment_id = ?", cm.ID).Pluck("period", &existing).Error
+	if err != nil {
+		return err
+	}
+	generated := map[string]bool{}
+	for _, period := range existing {
+		generated[period] = true
+	}
+
+	periods := cm.periods(now)
+	for _, start := range periods {
+		period := start.Format("2006-01")
+		if generated[period] || (cm.GeneratedUntil != nil && !start.After(*cm.GeneratedUntil)) {
+			continue
+		}
+
+		amount, err := cm.due(db)
+		if errors.Is(err, ErrNotObligated) {
+			result.NotObliged++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		id := cm.ID
+		dueDate := time.Date(start.Year(), start.Month(), cm.DueDay, 0, 0, 0, 0, time.Local)
+		zm := ZakatMal{
+			IdMuzakki:    cm.IdMuzakki,
+			TypeZakat:    cm.TypeZakat,
+			TotalAssest:  cm.Income * frequencyMonths[cm.Frequency],
+			TotalZakat:   amount,
+			Period:       period,
+			CommitmentID: &id,
+			DueDate:      &dueDate,
+		}
+		err = db.Debug().Create(&zm).Error
+		if err != nil {
+			return err
+		}
+		result.Generated++
+	}
+
+	if len(periods) == 0 {
+		return nil
+	}
+	until := periods[len(periods)-1]
+	cm.GeneratedUntil = &until
+
+	return db.Debug().Model(&Commitment{}).Where("id = ?", cm.ID).Update("generated_until", until).Error
+}
+
+// remind leaves a due reminder shortly before the due date and an overdue one once it has passed
+func (cm *Commitment) remind(db *gorm.DB, now time.Time, result *ScheduleResult) error {
+	obligations := []ZakatMal{}
+	err := db.Debug().Model(&ZakatMal{}).Where("commitment_id = ? AND due_date <= ?", cm.ID, now.AddDate(0, 0, Reminder_days)).Find(&obligations).Error
+	if err != nil {
+		return err
+	}
+	err = LoadZakatMalPayments(db, obligations)
+	if err != nil {
+		return err
+	}
+
+	for _, zm := range obligations {
+		balance := zm.TotalZakat - zm.Paid
+		if balance <= 0 {
+			continue
+		}
+
+		kind := ReminderDue
+		message := fmt.Sprintf("Zakat profesi %s sebesar Rp%d jatuh tempo %s", zm.Period, balance, zm.DueDate.Format("02-01-2006"))
+		if zm.DueDate.Before(now) {
+			kind = ReminderOverdue
+			message = fmt.Sprintf("Zakat profesi %s sebesar Rp%d telah lewat jatuh tempo %s", zm.Period, balance, zm.DueDate.Format("02-01-2006"))
+		}
+
+		var count int64
+		err = db.Debug().Model(&CommitmentReminder{}).Where("zakat_mal_id = ? AND kind = ?", zm.ID, kind).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		reminder := CommitmentReminder{
+			CommitmentID: cm.ID,
+			ZakatMalID:   zm.ID,
+			Kind:         kind,
+			IdMuzakki:    cm.IdMuzakki,
+			Period:       zm.Period,
+			DueDate:      *zm.DueDate,
+			Amount:       balance,
+			Message:      message,
+		}
+		err = db.Debug().Create(&reminder).Error
+		if err != nil {
+			return err
+		}
+		result.Reminders++
+	}
+
+	return nil
+}
+
+// RunCommitmentSchedule generates the obligations and reminders that are due, running it twice does no harm
+func RunCommitmentSchedule(db *gorm.DB, now time.Time) (*ScheduleResult, error) {
+	result := ScheduleResult{RunAt: now}
+
+	commitments := []Commitment{}
+	err := db.Debug().Model(&Commitment{}).Where("status = ?", CommitmentActive).Find(&commitments).Error
+	if err != nil {
+		return &result, err
+	}
+
+	// one broken commitment is logged and counted, it must not hold back the others on every run
+	for i := range commitments {
+		cm := &commitments[i]
+		counts := ScheduleResult{}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			err := cm.generate(tx, now, &counts)
+			if err != nil {
+				return err
+			}
+			err = cm.remind(tx, now, &counts)
+			if err != nil {
+				return err
+			}
+
+			// the last period is generated, from here on only payments are awaited
+			if cm.EndDate != nil && cm.EndDate.Before(now) {
+				counts.Ended++
+				return tx.Debug().Model(&Commitment{}).Where("id = ?", cm.ID).Update("status", CommitmentEnded).Error
+			}
+
+			return nil
+		})
+		if err != nil {
+			log.Printf("commitment %d skipped: %v\n", cm.ID, err)
+			result.Failed++
+			continue
+		}
+		result.Commitments++
+		result.Generated += counts.Generated
+		result.NotObliged += counts.NotObliged
+		result.Reminders += counts.Reminders
+		result.Ended += counts.Ended
+	}
+
+	return &result, nil
+}
+
+// GetCommitmentsAtRisk lists commitments with at least the given number of past-due obligations left unpaid
+func GetCommitmentsAtRisk(db *gorm.DB, missed int, now time.Time) (*[]CommitmentRisk, error) {
+	risks := []CommitmentRisk{}
+
+	// ended and paused commitments still owe what was generated before
+	commitments := []Commitment{}
+	err := db.Debug().Model(&Commitment{}).Find(&commitments).Error
+	if err != nil {
+		return &[]CommitmentRisk{}, err
+	}
+
+	for _, cm := range commitments {
+		obligations := []ZakatMal{}
+		err = db.Debug().Model(&ZakatMal{}).Where("commitment_id = ? AND due_date < ?", cm.ID, now).Order("period").Find(&obligations).Error
+		if err != nil {
+			return &[]CommitmentRisk{}, err
+		}
+		err = LoadZakatMalPayments(db, obligations)
+		if err != nil {
+			return &[]CommitmentRisk{}, err
+		}
+
+		risk := CommitmentRisk{Commitment: cm}
+		ids := []uint{}
+		for _, zm := range obligations {
+			ids = append(ids, zm.ID)
+			if zm.Paid >= zm.TotalZakat {
+				continue
+			}
+			if risk.Missed == 0 {
+				risk.MissedFrom = zm.Period
+			}
+			risk.Missed++
+			risk.Outstanding += zm.TotalZakat - zm.Paid
+		}
+		if risk.Missed < missed {
+			continue
+		}
+
+		if len(ids) > 0 {
+			last := Payment{}
+			err = db.Debug().Model(&Payment{}).Where("obligation_type = ? AND obligation_id IN ? AND status = ?", FundZakatMal, ids, PaymentRecorded).
+				Order("paid_at desc").Limit(1).Find(&last).Error
+			if err != nil {
+				return &[]CommitmentRisk{}, err
+			}
+			if last.ID != 0 {
+				risk.LastPaidAt = &last.PaidAt
+			}
+		}
+
+		muzakki := Muzakki{}
+		if db.Debug().Model(&Muzakki{}).Where("muzakki_id = ?", cm.IdMuzakki).Take(&muzakki).Error == nil {
+			risk.MuzakkiName = muzakki.DisplayName()
+			risk.Mobile = muzakki.Mobile
+		}
+
+		risks = append(risks, risk)
+	}
+
+	sort.SliceStable(risks, func(i, j int) bool {
+		return risks[i].Missed > risks[j].Missed
+	})
+
+	return &risks, nil
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CommodityPrice struct {
+	gorm.Model
+	Commodity  string     `gorm:"size:100;not null" json:"commodity"`
+	Unit       string     `gorm:"size:20;not null" json:"unit"`
+	Region     string     `gorm:"size:100;not null;default:''" json:"region"`
+	Price      float64    `gorm:"not null" json:"price"`
+	ValidFrom  time.Time  `gorm:"not null" json:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until"`
+	Source     string     `gorm:"size:255" json:"source"`
+}
+
+const (
+	UnitKg    = "kg"
+	UnitLiter = "liter"
+
+	CommodityRice = "beras"
+)
+
+var CommodityUnits = []string{UnitKg, UnitLiter, UnitGram}
+
+func (cp *CommodityPrice) Prepare() {
+	cp.Commodity = html.EscapeString(strings.TrimSpace(strings.ToLower(cp.Commodity)))
+	cp.Unit = strings.TrimSpace(strings.ToLower(cp.Unit))
+	cp.Region = html.EscapeString(strings.TrimSpace(strings.ToLower(cp.Region)))
+	cp.Source = html.EscapeString(strings.TrimSpace(cp.Source))
+	if cp.ValidFrom.IsZero() {
+		cp.ValidFrom = time.Now()
+	}
+}
+
+func (cp *CommodityPrice) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if cp.Commodity == "" {
+		err = errors.New("required commodity")
+		errMsg["Required_commodity"] = err.Error()
+	}
+	validUnit := false
+	for _, unit := range CommodityUnits {
+		if cp.Unit == unit {
+			validUnit = true
+		}
+	}
+	if !validUnit {
+		err = errors.New("unit must be kg, liter, or gram")
+		errMsg["Invalid_unit"] = err.Error()
+	}
+	if cp.Price <= 0 {
+		err = errors.New("required price")
+		errMsg["Required_price"] = err.Error()
+	}
+	if cp.ValidUntil != nil && cp.ValidUntil.Before(cp.ValidFrom) {
+		err = errors.New("valid until must be after valid from")
+		errMsg["Invalid_period"] = err.Error()
+	}
+
+	return errMsg
+}
+
+func (cp *CommodityPrice) SaveCommodityPrice(db *gorm.DB) (*CommodityPrice, error) {
+	err := db.Debug().Create(&cp).Error
+	if err != nil {
+		return &CommodityPrice{}, err
+	}
+
+	return cp, nil
+}
+
+func (cp *CommodityPrice) GetCommodityPrices(db *gorm.DB, commodity, region string) (*[]CommodityPrice, error) {
+	prices := []CommodityPrice{}
+
+	query := db.Debug().Model(&CommodityPrice{})
+	if commodity != "" {
+		query = query.Where("commodity = ?", strings.ToLower(commodity))
+	}
+	if region != "" {
+		query = query.Where("region = ?", strings.ToLower(region))
+	}
+	err := query.Order("commodity, region, valid_from desc").Find(&prices).Error
+	if err != nil {
+		return &[]CommodityPrice{}, err
+	}
+
+	return &prices, nil
+}
+
+func (cp *CommodityPrice) DeleteCommodityPrice(db *gorm.DB, id string) (int, error) {
+	db = db.Debug().Model(&CommodityPrice{}).Where("id = ?", id).Take(&CommodityPrice{}).Delete(&CommodityPrice{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return int(db.RowsAffected), nil
+}
+
+// GetActivePrice returns the price valid at the given time, a regional price wins over the general one
+func (cp *CommodityPrice) GetActivePrice(db *gorm.DB, commodity, unit, region string, at time.Time) (*CommodityPrice, error) {
+	commodity = strings.TrimSpace(strings.ToLower(commodity))
+	region = strings.TrimSpace(strings.ToLower(region))
+
+	err := db.Debug().Model(&CommodityPrice{}).
+		Where("commodity = ? AND unit = ? AND region IN ?", commodity, unit, []string{region, ""}).
+		Where("valid_from <= ? AND (valid_until IS NULL OR valid_until >= ?)", at, at).
+		Order("region desc, valid_from desc").
+		First(&cp).Error
+	if err != nil {
+		return &CommodityPrice{}, err
+	}
+
+	return cp, nil
+}
+
+func SeedCommodityPrice(db *gorm.DB, commodity, unit string, price float64) {
+	var count int64
+	db.Debug().Model(&CommodityPrice{}).Where("commodity = ? AND unit = ? AND region = ''", commodity, unit).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	db.Debug().Create(&CommodityPrice{
+		Commodity: commodity,
+		Unit:      unit,
+		Price:     price,
+		ValidFrom: time.Now(),
+		Source:    "default",
+	})
+}
@@ -0,0 +1,352 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Distribution struct {
+	gorm.Model
+	MustahikID    uint      `gorm:"not null" json:"mustahik_id"`
+	Mustahik      Mustahik  `gorm:"foreignKey:MustahikID" json:"mustahik"`
+	Asnaf         string    `gorm:"size:50;not null" json:"asnaf"`
+	Program       string    `gorm:"size:255;not null" json:"program"`
+	SourceFund    string    `gorm:"size:50;not null" json:"source_fund"`
+	Kind          string    `gorm:"size:20;not null" json:"kind"`
+	Amount        int       `gorm:"not null;default:0" json:"amount"`
+	Weight        float64   `gorm:"not null;default:0" json:"weight"`
+	Note          string    `gorm:"size:255" json:"note"`
+	DistributedAt time.Time `gorm:"not null" json:"distributed_at"`
+	DistributedBy string    `gorm:"size:255;not null" json:"distributed_by"`
+	WarehouseID   *uint     `json:"warehouse_id"`
+}
+
+type FundBalance struct {
+	Fund        string  `json:"fund"`
+	Kind        string  `json:"kind"`
+	Collected   float64 `json:"collected"`
+	AmilShare   float64 `json:"amil_share"`
+	Distributed float64 `json:"distributed"`
+	Financed    float64 `json:"financed"`
+	Repaid      float64 `json:"repaid"`
+	Purchased   float64 `json:"purchased"`
+	Adjusted    float64 `json:"adjusted"`
+	Remaining   float64 `json:"remaining"`
+}
+
+const (
+	FundZakatFitrah = "zakat_fitrah"
+	FundZakatMal    = "zakat_mal"
+
+	KindCash = "cash"
+	KindRice = "rice"
+)
+
+var (
+	ErrInsufficientFund    = errors.New("distribution exceeds the remaining fund balance")
+	ErrMustahikNotApproved = errors.New("mustahik has not been approved")
+)
+
+func (d *Distribution) Prepare(uid string) {
+	d.Asnaf = html.EscapeString(strings.TrimSpace(strings.ToLower(d.Asnaf)))
+	d.Program = html.EscapeString(strings.TrimSpace(d.Program))
+	d.SourceFund = strings.TrimSpace(strings.ToLower(d.SourceFund))
+	d.Kind = strings.TrimSpace(strings.ToLower(d.Kind))
+	d.Note = html.EscapeString(strings.TrimSpace(d.Note))
+	d.DistributedBy = uid
+	if d.DistributedAt.IsZero() {
+		d.DistributedAt = time.Now()
+	}
+	if d.Kind == KindCash {
+		d.Weight = 0
+	}
+	if d.Kind == KindRice {
+		d.Amount = 0
+	}
+}
+
+func (d *Distribution) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if d.MustahikID == 0 {
+		err = errors.New("required mustahik")
+		errMsg["Required_mustahik"] = err.Error()
+	}
+	if d.Asnaf != "" && !ValidAsnaf(d.Asnaf) {
+		err = errors.New("asnaf must be fakir, miskin, amil, muallaf, riqab, gharim, fisabilillah, or ibnu sabil")
+		errMsg["Invalid_asnaf"] = err.Error()
+	}
+	if d.Program == "" {
+		err = errors.New("required program")
+		errMsg["Required_program"] = err.Error()
+	}
+	if d.SourceFund != FundZakatFitrah && d.SourceFund != FundZakatMal {
+		err = errors.New("source fund must be zakat_fitrah or zakat_mal")
+		errMsg["Invalid_fund"] = err.Error()
+	}
+	if d.Kind != KindCash && d.Kind != KindRice {
+		err = errors.New("kind must be cash or rice")
+		errMsg["Invalid_kind"] = err.Error()
+	}
+	if d.Kind == KindRice && d.SourceFund != FundZakatFitrah {
+		err = errors.New("rice can only be distributed from zakat_fitrah")
+		errMsg["Invalid_kind"] = err.Error()
+	}
+	if d.Kind == KindCash && d.Amount <= 0 {
+		err = errors.New("required amount")
+		errMsg["Required_amount"] = err.Error()
+	}
+	if d.Kind == KindRice && d.WarehouseID == nil {
+		err = errors.New("required warehouse for rice distribution")
+		errMsg["Required_warehouse"] = err.Error()
+	}
+	if d.Kind == KindRice && d.Weight <= 0 {
+		err = errors.New("required weight")
+		errMsg["Required_weight"] = err.Error()
+	}
+
+	return errMsg
+}
+
+// GetFundBalances sums what was collected per fund and what has been distributed from it
+func GetFundBalances(db *gorm.DB) ([]FundBalance, error) {
+	var distFitrahCash, distFitrahRice, distMalCash float64
+
+	// collected counts what was actually paid, an obligation on its own brings nothing into the fund
+	fitrahCash, err := collected(db, FundZakatFitrah, false, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	fitrahRice, err := collected(db, FundZakatFitrah, true, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	malCash, err := collected(db, FundZakatMal, false, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	fitrahCashShare, err := amilSharesOf(db, FundZakatFitrah, false, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	fitrahRiceShare, err := amilSharesOf(db, FundZakatFitrah, true, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	malShare, err := amilSharesOf(db, FundZakatMal, false, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Debug().Model(&Distribution{}).Where("source_fund = ? AND kind = ?", FundZakatFitrah, KindCash).Select("COALESCE(SUM(amount), 0)").Scan(&distFitrahCash).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Debug().Model(&Distribution{}).Where("source_fund = ? AND kind = ?", FundZakatFitrah, KindRice).Select("COALESCE(SUM(weight), 0)").Scan(&distFitrahRice).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Debug().Model(&Distribution{}).Where("source_fund = ? AND kind = ?", FundZakatMal, KindCash).Select("COALESCE(SUM(amount), 0)").Scan(&distMalCash).Error
+	if err != nil {
+		return nil, err
+	}
+
+	fitrahFinanced, fitrahRepaid, err := financingFlow(db, FundZakatFitrah)
+	if err != nil {
+		return nil, err
+	}
+	malFinanced, malRepaid, err := financingFlow(db, FundZakatMal)
+	if err != nil {
+		return nil, err
+	}
+
+	purchasedKg, purchasedIdr, adjusted, err := stockFlow(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// rice bought with cash fitrah leaves the cash fund and enters the rice fund
+	balances := []FundBalance{
+		{Fund: FundZakatFitrah, Kind: KindCash, Collected: fitrahCash, AmilShare: fitrahCashShare, Distributed: distFitrahCash, Financed: fitrahFinanced, Repaid: fitrahRepaid, Purchased: -purchasedIdr},
+		{Fund: FundZakatFitrah, Kind: KindRice, Collected: fitrahRice, AmilShare: fitrahRiceShare, Distributed: distFitrahRice, Purchased: purchasedKg, Adjusted: adjusted},
+		{Fund: FundZakatMal, Kind: KindCash, Collected: malCash, AmilShare: malShare, Distributed: distMalCash, Financed: malFinanced, Repaid: malRepaid},
+	}
+	for i := range balances {
+		balances[i].Remaining = balances[i].Collected - balances[i].AmilShare - balances[i].Distributed - balances[i].Financed + balances[i].Repaid + balances[i].Purchased + balances[i].Adjusted
+	}
+
+	return balances, nil
+}
+
+// financingFlow returns the financing disbursed from a fund and the repayments flowing back into it
+func financingFlow(db *gorm.DB, fund string) (float64, float64, error) {
+	var financed, repaid float64
+
+	err := db.Debug().Model(&Financing{}).Where("source_fund = ?", fund).Select("COALESCE(SUM(principal), 0)").Scan(&financed).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	err = db.Debug().Model(&FinancingRepayment{}).Joins("JOIN financings ON financings.id = financing_repayments.financing_id").Where("financings.source_fund = ? AND financings.deleted_at IS NULL", fund).Select("COALESCE(SUM(financing_repayments.amount), 0)").Scan(&repaid).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return financed, repaid, nil
+}
+
+func GetFundBalance(db *gorm.DB, fund, kind string) (*FundBalance, error) {
+	balances, err := GetFundBalances(db)
+	if err != nil {
+		return &FundBalance{}, err
+	}
+
+	for _, balance := range balances {
+		if balance.Fund == fund && balance.Kind == kind {
+			return &balance, nil
+		}
+	}
+
+	return &FundBalance{}, errors.New("unknown fund " + fund + " " + kind)
+}
+
+// lockFund holds a transaction-scoped advisory lock on the fund, so writers that check its balance before
+// spending from it run one after the other
+func lockFund(tx *gorm.DB, fund string) error {
+	return tx.Debug().Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "fund:"+fund).Error
+}
+
+// SaveDistribution checks the mustahik and the remaining fund balance before recording the disbursement
+func (d *Distribution) SaveDistribution(db *gorm.DB) (*Distribution, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		mustahik := Mustahik{}
+		err := tx.Debug().Model(&Mustahik{}).Where("id = ?", d.MustahikID).Take(&mustahik).Error
+		if err != nil {
+			return err
+		}
+		if mustahik.VerificationStatus != MustahikApproved {
+			return ErrMustahikNotApproved
+		}
+		if d.Asnaf == "" {
+			d.Asnaf = mustahik.Asnaf
+		}
+
+		err = lockFund(tx, d.SourceFund)
+		if err != nil {
+			return err
+		}
+		balance, err := GetFundBalance(tx, d.SourceFund, d.Kind)
+		if err != nil {
+			return err
+		}
+		requested := float64(d.Amount)
+		if d.Kind == KindRice {
+			requested = d.Weight
+		}
+		if requested > balance.Remaining {
+			return ErrInsufficientFund
+		}
+
+		err = CheckAllocation(tx, d)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Debug().Omit("Mustahik").Create(&d).Error
+		if err != nil {
+			return err
+		}
+		if d.Kind != KindRice {
+			return PostDistributionJournal(tx, d, 0)
+		}
+
+		cost, err := IssueCost(tx, d.Weight)
+		if err != nil {
+			return err
+		}
+		err = PostDistributionJournal(tx, d, cost)
+		if err != nil {
+			return err
+		}
+
+		issue := StockMovement{
+			WarehouseID: *d.WarehouseID,
+			Type:        StockIssue,
+			Quantity:    -d.Weight,
+			Amount:      -cost,
+			Reference:   DistributionReference(d.ID),
+			MovedAt:     d.DistributedAt,
+			CreatedBy:   d.DistributedBy,
+		}
+		_, err = issue.Move(tx)
+		return err
+	})
+	if err != nil {
+		return &Distribution{}, err
+	}
+
+	return d, nil
+}
+
+func (d *Distribution) GetDistributions(db *gorm.DB, mustahikID, asnaf, fund string) (*[]Distribution, error) {
+	distributions := []Distribution{}
+
+	query := db.Debug().Model(&Distribution{}).Preload("Mustahik")
+	if mustahikID != "" {
+		query = query.Where("mustahik_id = ?", mustahikID)
+	}
+	if asnaf != "" {
+		query = query.Where("asnaf = ?", strings.ToLower(asnaf))
+	}
+	if fund != "" {
+		query = query.Where("source_fund = ?", strings.ToLower(fund))
+	}
+	err := query.Order("distributed_at desc").Find(&distributions).Error
+	if err != nil {
+		return &[]Distribution{}, err
+	}
+
+	return &distributions, nil
+}
+
+func (d *Distribution) GetDistribution(db *gorm.DB, id string) (*Distribution, error) {
+	err := db.Debug().Model(&Distribution{}).Preload("Mustahik").Where("id = ?", id).Take(&d).Error
+	if err != nil {
+		return &Distribution{}, err
+	}
+
+	return d, nil
+}
+
+// DeleteDistribution reverses the journal and puts issued rice back into the warehouse, both booked on the deleting user
+func (d *Distribution) DeleteDistribution(db *gorm.DB, id, uid string) (int, error) {
+	old := Distribution{}
+	err := db.Debug().Model(&Distribution{}).Where("id = ?", id).Take(&old).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var affected int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Debug().Model(&Distribution{}).Where("id = ?", old.ID).Delete(&Distribution{})
+		if res.Error != nil {
+			return res.Error
+		}
+		affected = res.RowsAffected
+
+		err := ReverseJournals(tx, SourceDistribution, old.ID, "distribution deleted", uid)
+		if err != nil {
+			return err
+		}
+
+		return ReverseDistributionIssue(tx, old.ID, uid)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(affected), nil
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type KifayahThreshold struct {
+	gorm.Model
+	Region        string  `gorm:"size:100;not null;unique" json:"region"`
+	HouseholdBase float64 `gorm:"not null" json:"household_base"`
+	PerPerson     float64 `gorm:"not null" json:"per_person"`
+	Reference     string  `gorm:"size:255" json:"reference"`
+}
+
+type EligibilityAssessment struct {
+	gorm.Model
+	MustahikID uint      `gorm:"not null" json:"mustahik_id"`
+	Mustahik   Mustahik  `gorm:"foreignKey:MustahikID" json:"mustahik"`
+	Income     *float64  `gorm:"not null;default:0" json:"income"`
+	Dependents *int      `gorm:"not null;default:0" json:"dependents"`
+	Assets     float64   `gorm:"not null;default:0" json:"assets"`
+	Expenses   float64   `gorm:"not null;default:0" json:"expenses"`
+	Region     string    `gorm:"size:100" json:"region"`
+	Kifayah    float64   `gorm:"not null" json:"kifayah"`
+	Gap        float64   `gorm:"not null" json:"gap"`
+	Score      float64   `gorm:"not null" json:"score"`
+	Eligible   bool      `gorm:"not null" json:"eligible"`
+	AssessedBy string    `gorm:"size:255;not null" json:"assessed_by"`
+	AssessedAt time.Time `gorm:"not null" json:"assessed_at"`
+}
+
+func (kt *KifayahThreshold) Prepare() {
+	kt.Region = html.EscapeString(strings.TrimSpace(strings.ToLower(kt.Region)))
+	kt.Reference = html.EscapeString(strings.TrimSpace(kt.Reference))
+}
+
+func (kt *KifayahThreshold) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if kt.HouseholdBase < 0 {
+		err = errors.New("household base can not be negative")
+		errMsg["Invalid_base"] = err.Error()
+	}
+	if kt.PerPerson <= 0 {
+		err = errors.New("required amount per person")
+		errMsg["Required_perPerson"] = err.Error()
+	}
+
+	return errMsg
+}
+
+func (kt *KifayahThreshold) SaveThreshold(db *gorm.DB) (*KifayahThreshold, error) {
+	old := KifayahThreshold{}
+	err := db.Debug().Model(&KifayahThreshold{}).Where("region = ?", kt.Region).Take(&old).Error
+	if err == nil {
+		err = db.Debug().Model(&KifayahThreshold{}).Where("id = ?", old.ID).Updates(map[string]interface{}{
+			"household_base": kt.HouseholdBase,
+			"per_person":     kt.PerPerson,
+			"reference":      kt.Reference,
+		}).Error
+		if err != nil {
+			return &KifayahThreshold{}, err
+		}
+		kt.ID = old.ID
+
+		return kt, nil
+	}
+
+	err = db.Debug().Create(&kt).Error
+	if err != nil {
+		return &KifayahThreshold{}, err
+	}
+
+	return kt, nil
+}
+
+func (kt *KifayahThreshold) GetThresholds(db *gorm.DB) (*[]KifayahThreshold, error) {
+	thresholds := []KifayahThreshold{}
+	err := db.Debug().Model(&KifayahThreshold{}).Order("region").Find(&thresholds).Error
+	if err != nil {
+		return &[]KifayahThreshold{}, err
+	}
+
+	return &thresholds, nil
+}
+
+// GetThreshold returns the threshold of the region, an empty region holds the national default
+func GetThreshold(db *gorm.DB, region string) (*KifayahThreshold, error) {
+	region = strings.TrimSpace(strings.ToLower(region))
+
+	kt := KifayahThreshold{}
+	err := db.Debug().Model(&KifayahThreshold{}).Where("region IN ?", []string{region, ""}).Order("region desc").First(&kt).Error
+	if err != nil {
+		return &KifayahThreshold{}, err
+	}
+
+	return &kt, nil
+}
+
+// Assess scores the household, 0 means the monthly needs are covered and 100 means nothing is covered
+func (ea *EligibilityAssessment) Assess(db *gorm.DB, uid string) (*EligibilityAssessment, error) {
+	mustahik := Mustahik{}
+	err := db.Debug().Model(&Mustahik{}).Where("id = ?", ea.MustahikID).Take(&mustahik).Error
+	if err != nil {
+		return &EligibilityAssessment{}, err
+	}
+
+	// only what the surveyor left out comes from the registry, an income of 0 is the fakir case and must stay 0
+	if ea.Income == nil {
+		income := float64(mustahik.MonthlyIncome)
+		ea.Income = &income
+	}
+	if ea.Dependents == nil {
+		dependents := mustahik.HouseholdSize - 1
+		ea.Dependents = &dependents
+	}
+	if ea.Region == "" {
+		ea.Region = mustahik.City
+	}
+	ea.Region = strings.TrimSpace(strings.ToLower(ea.Region))
+
+	threshold, err := GetThreshold(db, ea.Region)
+	if err != nil {
+		return &EligibilityAssessment{}, err
+	}
+
+	// assets are spread over a year to get their monthly value
+	resources := *ea.Income + ea.Assets/12 - ea.Expenses
+	ea.Kifayah = threshold.HouseholdBase + threshold.PerPerson*float64(1+*ea.Dependents)
+	ea.Gap = math.Round((ea.Kifayah-resources)*100) / 100
+	ea.Score = math.Round(math.Max(0, math.Min(100, ea.Gap/ea.Kifayah*100))*100) / 100
+	ea.Eligible = ea.Gap > 0
+	ea.AssessedBy = uid
+	ea.AssessedAt = time.Now()
+
+	err = db.Debug().Omit("Mustahik").Create(&ea).Error
+	if err != nil {
+		return &EligibilityAssessment{}, err
+	}
+	ea.Mustahik = mustahik
+
+	return ea, nil
+}
+
+func (ea *EligibilityAssessment) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if ea.MustahikID == 0 {
+		err = errors.New("required mustahik")
+		errMsg["Required_mustahik"] = err.Error()
+	}
+	if (ea.Income != nil && *ea.Income < 0) || ea.Assets < 0 || ea.Expenses < 0 || (ea.Dependents != nil && *ea.Dependents < 0) {
+		err = errors.New("income, dependents, assets, and expenses can not be negative")
+		errMsg["Invalid_value"] = err.Error()
+	}
+
+	return errMsg
+}
+
+func (ea *EligibilityAssessment) GetAssessments(db *gorm.DB, mustahikID string) (*[]EligibilityAssessment, error) {
+	assessments := []EligibilityAssessment{}
+
+	query := db.Debug().Model(&EligibilityAssessment{})
+	if mustahikID != "" {
+		query = query.Where("mustahik_id = ?", mustahikID)
+	}
+	err := query.Order("assessed_at desc").Find(&assessments).Error
+	if err != nil {
+		return &[]EligibilityAssessment{}, err
+	}
+
+	return &assessments, nil
+}
+
+// GetRanking orders mustahik by the score of their latest assessment
+func (ea *EligibilityAssessment) GetRanking(db *gorm.DB) (*[]EligibilityAssessment, error) {
+	assessments := []EligibilityAssessment{}
+
+	latest := db.Model(&EligibilityAssessment{}).Select("MAX(id)").Group("mustahik_id")
+	err := db.Debug().Model(&EligibilityAssessment{}).Preload("Mustahik").Where("id IN (?)", latest).Order("score desc, assessed_at").Find(&assessments).Error
+	if err != nil {
+		return &[]EligibilityAssessment{}, err
+	}
+
+	return &assessments, nil
+}
@@ -0,0 +1,343 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Financing struct {
+	gorm.Model
+	MustahikID     uint                   `gorm:"not null" json:"mustahik_id"`
+	Mustahik       Mustahik               `gorm:"foreignKey:MustahikID" json:"mustahik"`
+	Type           string                 `gorm:"size:50;not null" json:"type"`
+	Program        string                 `gorm:"size:255;not null" json:"program"`
+	SourceFund     string                 `gorm:"size:50;not null" json:"source_fund"`
+	Principal      int                    `gorm:"not null" json:"principal"`
+	Installments   int                    `gorm:"not null" json:"installments"`
+	StartDate      time.Time              `gorm:"not null" json:"start_date"`
+	AgreementNo    string                 `gorm:"size:100;not null;unique" json:"agreement_no"`
+	CreatedBy      string                 `gorm:"size:255;not null" json:"created_by"`
+	WrittenOff     int                    `gorm:"not null;default:0" json:"written_off"`
+	WrittenOffAt   *time.Time             `json:"written_off_at"`
+	WrittenOffBy   string                 `gorm:"size:255" json:"written_off_by"`
+	WriteOffReason string                 `gorm:"size:255" json:"write_off_reason"`
+	Schedule       []FinancingInstallment `gorm:"foreignKey:FinancingID" json:"schedule"`
+	Repayments     []FinancingRepayment   `gorm:"foreignKey:FinancingID" json:"repayments"`
+	Status         string                 `gorm:"-" json:"status"`
+	Outstanding    int                    `gorm:"-" json:"outstanding"`
+}
+
+type FinancingInstallment struct {
+	gorm.Model
+	FinancingID uint      `gorm:"not null" json:"financing_id"`
+	Number      int       `gorm:"not null" json:"number"`
+	DueDate     time.Time `gorm:"not null" json:"due_date"`
+	Amount      int       `gorm:"not null" json:"amount"`
+	PaidAmount  int       `gorm:"not null;default:0" json:"paid_amount"`
+}
+
+type FinancingRepayment struct {
+	gorm.Model
+	FinancingID uint      `gorm:"not null" json:"financing_id"`
+	Amount      int       `gorm:"not null" json:"amount"`
+	PaidAt      time.Time `gorm:"not null" json:"paid_at"`
+	ReceivedBy  string    `gorm:"size:255;not null" json:"received_by"`
+}
+
+const (
+	FinancingQardhulHasan = "qardhul_hasan"
+	FinancingModalUsaha   = "modal_usaha"
+
+	FinancingActive     = "active"
+	FinancingArrears    = "arrears"
+	FinancingPaidOff    = "paid_off"
+	FinancingWrittenOff = "written_off"
+)
+
+var ErrRepaymentExceeded = errors.New("repayment exceeds the outstanding amount")
+
+func (f *Financing) Prepare(uid string) {
+	f.Type = strings.TrimSpace(strings.ToLower(f.Type))
+	f.Program = html.EscapeString(strings.TrimSpace(f.Program))
+	f.SourceFund = strings.TrimSpace(strings.ToLower(f.SourceFund))
+	f.AgreementNo = html.EscapeString(strings.TrimSpace(f.AgreementNo))
+	f.CreatedBy = uid
+	f.WrittenOff = 0
+	f.WrittenOffAt = nil
+	if f.StartDate.IsZero() {
+		f.StartDate = time.Now()
+	}
+}
+
+func (f *Financing) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if f.MustahikID == 0 {
+		err = errors.New("required mustahik")
+		errMsg["Required_mustahik"] = err.Error()
+	}
+	if f.Type != FinancingQardhulHasan && f.Type != FinancingModalUsaha {
+		err = errors.New("type must be qardhul_hasan or modal_usaha")
+		errMsg["Invalid_type"] = err.Error()
+	}
+	if f.Program == "" {
+		err = errors.New("required program")
+		errMsg["Required_program"] = err.Error()
+	}
+	if f.SourceFund != FundZakatFitrah && f.SourceFund != FundZakatMal {
+		err = errors.New("source fund must be zakat_fitrah or zakat_mal")
+		errMsg["Invalid_fund"] = err.Error()
+	}
+	if f.AgreementNo == "" {
+		err = errors.New("required agreement number")
+		errMsg["Required_agreement"] = err.Error()
+	}
+	if f.Principal <= 0 {
+		err = errors.New("required principal")
+		errMsg["Required_principal"] = err.Error()
+	}
+	if f.Installments < 1 {
+		err = errors.New("installments must be at least 1")
+		errMsg["Invalid_installments"] = err.Error()
+	}
+
+	return errMsg
+}
+
+// buildSchedule splits the principal into monthly installments, the last one takes the rounding rest
+func (f *Financing) buildSchedule() {
+	schedule := []FinancingInstallment{}
+	amount := f.Principal / f.Installments
+	for i := 1; i <= f.Installments; i++ {
+		installment := amount
+		if i == f.Installments {
+			installment = f.Principal - amount*(f.Installments-1)
+		}
+		schedule = append(schedule, FinancingInstallment{
+			Number:  i,
+			DueDate: f.StartDate.AddDate(0, i, 0),
+			Amount:  installment,
+		})
+	}
+	f.Schedule = schedule
+}
+
+// computeStatus fills the status and outstanding amount, both are derived and never stored
+func (f *Financing) computeStatus() {
+	repaid := 0
+	for _, repayment := range f.Repayments {
+		repaid += repayment.Amount
+	}
+	f.Outstanding = f.Principal - repaid - f.WrittenOff
+
+	switch {
+	case f.WrittenOffAt != nil:
+		f.Status = FinancingWrittenOff
+	case f.Outstanding <= 0:
+		f.Status = FinancingPaidOff
+	default:
+		f.Status = FinancingActive
+		for _, installment := range f.Schedule {
+			if installment.DueDate.Before(time.Now()) && installment.PaidAmount < installment.Amount {
+				f.Status = FinancingArrears
+				break
+			}
+		}
+	}
+}
+
+// SaveFinancing disburses the financing from the source fund, the mustahik must be approved
+func (f *Financing) SaveFinancing(db *gorm.DB) (*Financing, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		mustahik := Mustahik{}
+		err := tx.Debug().Model(&Mustahik{}).Where("id = ?", f.MustahikID).Take(&mustahik).Error
+		if err != nil {
+			return err
+		}
+		if mustahik.VerificationStatus != MustahikApproved {
+			return ErrMustahikNotApproved
+		}
+
+		err = lockFund(tx, f.SourceFund)
+		if err != nil {
+			return err
+		}
+		balance, err := GetFundBalance(tx, f.SourceFund, KindCash)
+		if err != nil {
+			return err
+		}
+		if float64(f.Principal) > balance.Remaining {
+			return ErrInsufficientFund
+		}
+
+		f.buildSchedule()
+		err = tx.Debug().Omit("Mustahik").Create(&f).Error
+		if err != nil {
+			return err
+		}
+
+		return postSimpleJournal(tx, SourceFinancing, f.ID, f.StartDate, f.AgreementNo, f.CreatedBy, AccQardhReceivable, AccCash, f.Principal)
+	})
+	if err != nil {
+		return &Financing{}, err
+	}
+	f.computeStatus()
+
+	return f, nil
+}
+
+func (f *Financing) GetFinancings(db *gorm.DB, mustahikID, status string) (*[]Financing, error) {
+	financings := []Financing{}
+
+	query := db.Debug().Model(&Financing{}).Preload("Mustahik").Preload("Schedule").Preload("Repayments")
+	if mustahikID != "" {
+		query = query.Where("mustahik_id = ?", mustahikID)
+	}
+	err := query.Order("start_date desc").Find(&financings).Error
+	if err != nil {
+		return &[]Financing{}, err
+	}
+
+	result := []Financing{}
+	for i := range financings {
+		financings[i].computeStatus()
+		if status == "" || financings[i].Status == status {
+			result = append(result, financings[i])
+		}
+	}
+
+	return &result, nil
+}
+
+func (f *Financing) GetFinancing(db *gorm.DB, id string) (*Financing, error) {
+	err := db.Debug().Model(&Financing{}).Preload("Mustahik").Preload("Schedule", func(db *gorm.DB) *gorm.DB {
+		return db.Order("number")
+	}).Preload("Repayments").Where("id = ?", id).Take(&f).Error
+	if err != nil {
+		return &Financing{}, err
+	}
+	f.computeStatus()
+
+	return f, nil
+}
+
+// lockFinancing takes the financing row for update and reads its schedule and repayments again under the lock,
+// so a concurrent repayment or write off is seen before the outstanding amount is checked
+func lockFinancing(tx *gorm.DB, id uint) (*Financing, error) {
+	f := Financing{}
+	err := tx.Debug().Clauses(clause.Locking{Strength: "UPDATE"}).Model(&Financing{}).Where("id = ?", id).Take(&f).Error
+	if err != nil {
+		return &Financing{}, err
+	}
+	err = tx.Debug().Model(&FinancingInstallment{}).Where("financing_id = ?", id).Order("number").Find(&f.Schedule).Error
+	if err != nil {
+		return &Financing{}, err
+	}
+	err = tx.Debug().Model(&FinancingRepayment{}).Where("financing_id = ?", id).Find(&f.Repayments).Error
+	if err != nil {
+		return &Financing{}, err
+	}
+	f.computeStatus()
+
+	return &f, nil
+}
+
+// Repay records a repayment and settles the oldest open installments first
+func (f *Financing) Repay(db *gorm.DB, amount int, uid string) (*Financing, error) {
+	if amount <= 0 {
+		return &Financing{}, errors.New("required repayment amount")
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		locked, err := lockFinancing(tx, f.ID)
+		if err != nil {
+			return err
+		}
+		if locked.Status == FinancingWrittenOff || locked.Status == FinancingPaidOff {
+			return errors.New("financing is already " + locked.Status)
+		}
+		if amount > locked.Outstanding {
+			return ErrRepaymentExceeded
+		}
+
+		rest := amount
+		for _, installment := range locked.Schedule {
+			open := installment.Amount - installment.PaidAmount
+			if open <= 0 || rest == 0 {
+				continue
+			}
+			pay := open
+			if rest < open {
+				pay = rest
+			}
+			err := tx.Debug().Model(&FinancingInstallment{}).Where("id = ?", installment.ID).Update("paid_amount", installment.PaidAmount+pay).Error
+			if err != nil {
+				return err
+			}
+			rest -= pay
+		}
+
+		repayment := FinancingRepayment{
+			FinancingID: locked.ID,
+			Amount:      amount,
+			PaidAt:      time.Now(),
+			ReceivedBy:  uid,
+		}
+		err = tx.Debug().Create(&repayment).Error
+		if err != nil {
+			return err
+		}
+
+		return postSimpleJournal(tx, SourceRepayment, repayment.ID, repayment.PaidAt, locked.AgreementNo, uid, AccCash, AccQardhReceivable, amount)
+	})
+	if err != nil {
+		return &Financing{}, err
+	}
+
+	fresh := Financing{}
+	return fresh.GetFinancing(db, fmt.Sprint(f.ID))
+}
+
+func (f *Financing) WriteOff(db *gorm.DB, reason, uid string) (*Financing, error) {
+	reason = html.EscapeString(strings.TrimSpace(reason))
+	if reason == "" {
+		return &Financing{}, errors.New("required write off reason")
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		locked, err := lockFinancing(tx, f.ID)
+		if err != nil {
+			return err
+		}
+		if locked.Status == FinancingWrittenOff || locked.Status == FinancingPaidOff {
+			return errors.New("financing is already " + locked.Status)
+		}
+
+		err = tx.Debug().Model(&Financing{}).Where("id = ?", locked.ID).Updates(Financing{
+			WrittenOff:     locked.Outstanding,
+			WrittenOffAt:   &now,
+			WrittenOffBy:   uid,
+			WriteOffReason: reason,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		// the forgiven receivable becomes a distribution of the zakat fund
+		return postSimpleJournal(tx, SourceWriteOff, locked.ID, now, reason, uid, AccZakatSpending, AccQardhReceivable, locked.Outstanding)
+	})
+	if err != nil {
+		return &Financing{}, err
+	}
+
+	fresh := Financing{}
+	return fresh.GetFinancing(db, fmt.Sprint(f.ID))
+}
@@ -0,0 +1,223 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"zakat/api/gateway"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Invoice struct {
+	gorm.Model
+	ObligationType string     `gorm:"size:50;not null" json:"obligation_type"`
+	ObligationID   uint       `gorm:"not null" json:"obligation_id"`
+	IdMuzakki      string     `gorm:"column:id_muzakki;size:255;not null" json:"id_muzakki"`
+	Provider       string     `gorm:"size:50;not null" json:"provider"`
+	ExternalID     string     `gorm:"size:100;not null;unique" json:"external_id"`
+	Channel        string     `gorm:"size:20;not null" json:"channel"`
+	Bank           string     `gorm:"size:50" json:"bank"`
+	Amount         int        `gorm:"not null" json:"amount"`
+	QrisPayload    string     `gorm:"size:512" json:"qris_payload"`
+	VaNumber       string     `gorm:"size:50" json:"va_number"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	Status         string     `gorm:"size:20;not null;default:pending" json:"status"`
+	PaidAt         *time.Time `json:"paid_at"`
+	PaymentID      *uint      `json:"payment_id"`
+}
+
+var (
+	ErrNotObligationOwner = errors.New("obligation belongs to another muzakki")
+	ErrAlreadyPaid        = errors.New("obligation is already paid")
+	ErrAmountMismatch     = errors.New("callback amount differs from the invoice")
+)
+
+func (inv *Invoice) Prepare(uid string) {
+	inv.ObligationType = strings.TrimSpace(strings.ToLower(inv.ObligationType))
+	inv.Channel = strings.TrimSpace(strings.ToLower(inv.Channel))
+	inv.Bank = strings.TrimSpace(strings.ToLower(inv.Bank))
+	inv.IdMuzakki = uid
+}
+
+func (inv *Invoice) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if inv.ObligationType != FundZakatFitrah && inv.ObligationType != FundZakatMal {
+		err = errors.New("obligation type must be zakat_fitrah or zakat_mal")
+		errMsg["Invalid_obligationType"] = err.Error()
+	}
+	if inv.ObligationID == 0 {
+		err = errors.New("required obligation")
+		errMsg["Required_obligation"] = err.Error()
+	}
+	if inv.Channel != gateway.ChannelQris && inv.Channel != gateway.ChannelVA {
+		err = errors.New("channel must be qris or va")
+		errMsg["Invalid_channel"] = err.Error()
+	}
+	if inv.Channel == gateway.ChannelVA && inv.Bank == "" {
+		err = errors.New("required bank for virtual account")
+		errMsg["Required_bank"] = err.Error()
+	}
+
+	return errMsg
+}
+
+// SaveInvoice opens an invoice at the provider for the unpaid balance of the obligation
+func (inv *Invoice) SaveInvoice(db *gorm.DB, gw gateway.Gateway) (*Invoice, error) {
+	op, err := GetObligationPayment(db, inv.ObligationType, inv.ObligationID)
+	if err != nil {
+		return &Invoice{}, err
+	}
+	if op.IdMuzakki != inv.IdMuzakki {
+		return &Invoice{}, ErrNotObligationOwner
+	}
+	if op.Balance <= 0 {
+		return &Invoice{}, ErrAlreadyPaid
+	}
+
+	created, err := gw.CreateInvoice(gateway.InvoiceRequest{
+		Reference: inv.ObligationType,
+		Customer:  inv.IdMuzakki,
+		Amount:    op.Balance,
+		Channel:   inv.Channel,
+		Bank:      inv.Bank,
+	})
+	if err != nil {
+		return &Invoice{}, err
+	}
+
+	inv.Provider = gw.Name()
+	inv.ExternalID = created.ExternalID
+	inv.Amount = created.Amount
+	inv.QrisPayload = created.QrisPayload
+	inv.VaNumber = created.VaNumber
+	inv.ExpiresAt = created.ExpiresAt
+	inv.Status = gateway.StatusPending
+
+	err = db.Debug().Create(&inv).Error
+	if err != nil {
+		return &Invoice{}, err
+	}
+
+	return inv, nil
+}
+
+func (inv *Invoice) GetInvoice(db *gorm.DB, id string) (*Invoice, error) {
+	err := db.Debug().Model(&Invoice{}).Where("id = ?", id).Take(&inv).Error
+	if err != nil {
+		return &Invoice{}, err
+	}
+
+	return inv, nil
+}
+
+func (inv *Invoice) GetInvoices(db *gorm.DB, muzakki string) (*[]Invoice, error) {
+	invoices := []Invoice{}
+	err := db.Debug().Model(&Invoice{}).Where("id_muzakki = ?", muzakki).Order("created_at DESC").Find(&invoices).Error
+	if err != nil {
+		return &[]Invoice{}, err
+	}
+
+	return &invoices, nil
+}
+
+// RefreshStatus asks the provider about a pending invoice in case a callback went missing
+func (inv *Invoice) RefreshStatus(db *gorm.DB, gw gateway.Gateway) (*Invoice, error) {
+	if inv.Status != gateway.StatusPending || inv.Provider != gw.Name() {
+		return inv, nil
+	}
+
+	status, err := gw.GetStatus(inv.ExternalID)
+	if errors.Is(err, gateway.ErrUnknownInvoice) && time.Now().After(inv.ExpiresAt) {
+		status, err = gateway.StatusExpired, nil
+	}
+	if err != nil {
+		return inv, err
+	}
+
+	switch status {
+	case gateway.StatusPaid:
+		return SettleInvoice(db, inv.Provider, &gateway.Callback{
+			ExternalID: inv.ExternalID,
+			Status:     gateway.StatusPaid,
+			Amount:     inv.Amount,
+			PaidAt:     time.Now(),
+		})
+	case gateway.StatusExpired:
+		return SettleInvoice(db, inv.Provider, &gateway.Callback{
+			ExternalID: inv.ExternalID,
+			Status:     gateway.StatusExpired,
+		})
+	}
+
+	return inv, nil
+}
+
+// SettleInvoice applies a provider callback, the invoice row stays locked until the payment is recorded so a
+// callback delivered twice at once still records exactly one payment
+func SettleInvoice(db *gorm.DB, provider string, cb *gateway.Callback) (*Invoice, error) {
+	inv := Invoice{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Debug().Clauses(clause.Locking{Strength: "UPDATE"}).Model(&Invoice{}).
+			Where("provider = ? AND external_id = ?", provider, cb.ExternalID).Take(&inv).Error
+		if err != nil {
+			return err
+		}
+		if inv.Status != gateway.StatusPending {
+			return nil
+		}
+
+		if cb.Status == gateway.StatusExpired {
+			inv.Status = gateway.StatusExpired
+			return tx.Debug().Model(&Invoice{}).Where("id = ?", inv.ID).Update("status", inv.Status).Error
+		}
+		if cb.Status != gateway.StatusPaid {
+			return nil
+		}
+		if cb.Amount != inv.Amount {
+			return ErrAmountMismatch
+		}
+
+		method := MethodQris
+		if inv.Channel == gateway.ChannelVA {
+			method = MethodTransfer
+		}
+		paidAt := cb.PaidAt
+		if paidAt.IsZero() {
+			paidAt = time.Now()
+		}
+
+		p := Payment{
+			ObligationType: inv.ObligationType,
+			ObligationID:   inv.ObligationID,
+			Method:         method,
+			Amount:         inv.Amount,
+			Reference:      inv.ExternalID,
+			Note:           "online payment via " + provider,
+			PaidAt:         paidAt,
+			ReceivedBy:     provider,
+		}
+		payment, err := p.SavePayment(tx)
+		if err != nil {
+			return err
+		}
+
+		inv.Status = gateway.StatusPaid
+		inv.PaidAt = &paidAt
+		inv.PaymentID = &payment.ID
+
+		return tx.Debug().Model(&Invoice{}).Where("id = ?", inv.ID).Updates(map[string]interface{}{
+			"status":     inv.Status,
+			"paid_at":    inv.PaidAt,
+			"payment_id": inv.PaymentID,
+		}).Error
+	})
+	if err != nil {
+		return &Invoice{}, err
+	}
+
+	return &inv, nil
+}
@@ -0,0 +1,578 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Account is an entry of the chart of accounts, laid out after PSAK 109
+type Account struct {
+	gorm.Model
+	Code   string `gorm:"size:20;not null;unique" json:"code"`
+	Name   string `gorm:"size:255;not null" json:"name"`
+	Type   string `gorm:"size:20;not null" json:"type"`
+	Fund   string `gorm:"size:50" json:"fund"`
+	Normal string `gorm:"size:10;not null" json:"normal"`
+}
+
+type JournalEntry struct {
+	gorm.Model
+	Date       time.Time     `gorm:"not null;index" json:"date"`
+	Source     string        `gorm:"size:50;not null" json:"source"`
+	SourceID   uint          `gorm:"not null;default:0" json:"source_id"`
+	Memo       string        `gorm:"size:255" json:"memo"`
+	ReversalOf *uint         `json:"reversal_of"`
+	CreatedBy  string        `gorm:"size:255;not null" json:"created_by"`
+	Lines      []JournalLine `gorm:"foreignKey:JournalEntryID" json:"lines"`
+}
+
+type JournalLine struct {
+	gorm.Model
+	JournalEntryID uint   `gorm:"not null;index" json:"journal_entry_id"`
+	AccountCode    string `gorm:"size:20;not null;index" json:"account_code"`
+	Fund           string `gorm:"size:50;not null" json:"fund"`
+	Debit          int    `gorm:"not null;default:0" json:"debit"`
+	Credit         int    `gorm:"not null;default:0" json:"credit"`
+}
+
+// LedgerPeriod marks a closed book period, no journal may be dated inside it
+type LedgerPeriod struct {
+	gorm.Model
+	PeriodStart time.Time `gorm:"not null" json:"period_start"`
+	PeriodEnd   time.Time `gorm:"not null" json:"period_end"`
+	ClosedBy    string    `gorm:"size:255;not null" json:"closed_by"`
+}
+
+type AccountBalance struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Fund    string `json:"fund"`
+	Opening int    `json:"opening"`
+	Debit   int    `json:"debit"`
+	Credit  int    `json:"credit"`
+	Closing int    `json:"closing"`
+}
+
+type TrialBalanceRow struct {
+	Code   string `json:"code"`
+	Name   string `json:"name"`
+	Fund   string `json:"fund"`
+	Debit  int    `json:"debit"`
+	Credit int    `json:"credit"`
+}
+
+type TrialBalance struct {
+	PeriodStart time.Time         `json:"period_start"`
+	PeriodEnd   time.Time         `json:"period_end"`
+	Rows        []TrialBalanceRow `json:"rows"`
+	TotalDebit  int               `json:"total_debit"`
+	TotalCredit int               `json:"total_credit"`
+	Balanced    bool              `json:"balanced"`
+}
+
+const (
+	FundZakat        = "zakat"
+	FundInfaqSadaqah = "infaq_sadaqah"
+	FundAmil         = "amil"
+	FundNonHalal     = "non_halal"
+
+	AccountAsset   = "asset"
+	AccountFund    = "fund"
+	AccountRevenue = "revenue"
+	AccountExpense = "expense"
+
+	NormalDebit  = "debit"
+	NormalCredit = "credit"
+
+	AccCash            = "1101"
+	AccBank            = "1102"
+	AccRiceInventory   = "1201"
+	AccQardhReceivable = "1301"
+	AccZakatFund       = "3101"
+	AccInfaqFund       = "3201"
+	AccAmilFund        = "3301"
+	AccNonHalalFund    = "3401"
+	AccZakatReceipt    = "4101"
+	AccInfaqReceipt    = "4201"
+	AccAmilReceipt     = "4301"
+	AccNonHalalReceipt = "4401"
+	AccZakatSpending   = "5101"
+	AccZakatAmilShare  = "5102"
+	AccInfaqSpending   = "5201"
+	AccInfaqAmilShare  = "5202"
+	AccAmilExpense     = "5301"
+	AccNonHalalSpend   = "5401"
+
+	SourcePayment      = "payment"
+	SourceDistribution = "distribution"
+	SourceFinancing    = "financing"
+	SourceRepayment    = "repayment"
+	SourceWriteOff     = "write_off"
+	SourcePurchase     = "stock_purchase"
+	SourceAdjustment   = "stock_adjustment"
+	SourceAmilShare    = "amil_share"
+	SourceTransfer     = "transfer"
+	SourceRefund       = "refund"
+	SourceManual       = "manual"
+)
+
+var ChartOfAccounts = []Account{
+	{Code: AccCash, Name: "Kas", Type: AccountAsset, Normal: NormalDebit},
+	{Code: AccBank, Name: "Bank", Type: AccountAsset, Normal: NormalDebit},
+	{Code: AccRiceInventory, Name: "Persediaan beras", Type: AccountAsset, Normal: NormalDebit},
+	{Code: AccQardhReceivable, Name: "Piutang qardhul hasan", Type: AccountAsset, Normal: NormalDebit},
+	{Code: AccZakatFund, Name: "Dana zakat", Type: AccountFund, Fund: FundZakat, Normal: NormalCredit},
+	{Code: AccInfaqFund, Name: "Dana infaq/sedekah", Type: AccountFund, Fund: FundInfaqSadaqah, Normal: NormalCredit},
+	{Code: AccAmilFund, Name: "Dana amil", Type: AccountFund, Fund: FundAmil, Normal: NormalCredit},
+	{Code: AccNonHalalFund, Name: "Dana non-halal", Type: AccountFund, Fund: FundNonHalal, Normal: NormalCredit},
+	{Code: AccZakatReceipt, Name: "Penerimaan zakat", Type: AccountRevenue, Fund: FundZakat, Normal: NormalCredit},
+	{Code: AccInfaqReceipt, Name: "Penerimaan infaq/sedekah", Type: AccountRevenue, Fund: FundInfaqSadaqah, Normal: NormalCredit},
+	{Code: AccAmilReceipt, Name: "Penerimaan bagian amil", Type: AccountRevenue, Fund: FundAmil, Normal: NormalCredit},
+	{Code: AccNonHalalReceipt, Name: "Penerimaan non-halal", Type: AccountRevenue, Fund: FundNonHalal, Normal: NormalCredit},
+	{Code: AccZakatSpending, Name: "Penyaluran zakat", Type: AccountExpense, Fund: FundZakat, Normal: NormalDebit},
+	{Code: AccZakatAmilShare, Name: "Bagian amil dari dana zakat", Type: AccountExpense, Fund: FundZakat, Normal: NormalDebit},
+	{Code: AccInfaqSpending, Name: "Penyaluran infaq/sedekah", Type: AccountExpense, Fund: FundInfaqSadaqah, Normal: NormalDebit},
+	{Code: AccInfaqAmilShare, Name: "Bagian amil dari dana infaq/sedekah", Type: AccountExpense, Fund: FundInfaqSadaqah, Normal: NormalDebit},
+	{Code: AccAmilExpense, Name: "Beban amil", Type: AccountExpense, Fund: FundAmil, Normal: NormalDebit},
+	{Code: AccNonHalalSpend, Name: "Penyaluran dana non-halal", Type: AccountExpense, Fund: FundNonHalal, Normal: NormalDebit},
+}
+
+var (
+	ErrUnbalancedJournal = errors.New("journal debit and credit are not balanced")
+	ErrPeriodClosed      = errors.New("journal date falls inside a closed period")
+)
+
+func ValidLedgerFund(fund string) bool {
+	switch fund {
+	case FundZakat, FundInfaqSadaqah, FundAmil, FundNonHalal:
+		return true
+	}
+	return false
+}
+
+func SeedAccounts(db *gorm.DB) {
+	for _, account := range ChartOfAccounts {
+		var count int64
+		db.Debug().Model(&Account{}).Where("code = ?", account.Code).Count(&count)
+		if count > 0 {
+			continue
+		}
+		acc := account
+		db.Debug().Create(&acc)
+	}
+}
+
+func GetAccounts(db *gorm.DB) (*[]Account, error) {
+	accounts := []Account{}
+	err := db.Debug().Model(&Account{}).Order("code").Find(&accounts).Error
+	if err != nil {
+		return &[]Account{}, err
+	}
+
+	return &accounts, nil
+}
+
+// cashAccount is the asset account a payment method settles into
+func cashAccount(method string) string {
+	switch method {
+	case MethodTransfer, MethodQris:
+		return AccBank
+	case MethodInKind:
+		return AccRiceInventory
+	}
+	return AccCash
+}
+
+// Post validates and stores a journal entry, every posting goes through here
+func (je *JournalEntry) Post(db *gorm.DB) (*JournalEntry, error) {
+	if je.Date.IsZero() {
+		je.Date = time.Now()
+	}
+	je.Memo = html.EscapeString(strings.TrimSpace(je.Memo))
+
+	var debit, credit int
+	lines := []JournalLine{}
+	for _, line := range je.Lines {
+		if line.Debit < 0 || line.Credit < 0 {
+			return &JournalEntry{}, errors.New("journal amounts can not be negative")
+		}
+		if line.Debit == 0 && line.Credit == 0 {
+			continue
+		}
+		if !ValidLedgerFund(line.Fund) {
+			return &JournalEntry{}, errors.New("fund must be zakat, infaq_sadaqah, amil, or non_halal")
+		}
+		debit += line.Debit
+		credit += line.Credit
+		lines = append(lines, line)
+	}
+	if len(lines) < 2 || debit != credit {
+		return &JournalEntry{}, ErrUnbalancedJournal
+	}
+	je.Lines = lines
+
+	var closed int64
+	err := db.Debug().Model(&LedgerPeriod{}).Where("period_start <= ? AND period_end >= ?", je.Date, je.Date).Count(&closed).Error
+	if err != nil {
+		return &JournalEntry{}, err
+	}
+	if closed > 0 {
+		return &JournalEntry{}, ErrPeriodClosed
+	}
+
+	for _, line := range je.Lines {
+		var count int64
+		err = db.Debug().Model(&Account{}).Where("code = ?", line.AccountCode).Count(&count).Error
+		if err != nil {
+			return &JournalEntry{}, err
+		}
+		if count == 0 {
+			return &JournalEntry{}, errors.New("unknown account " + line.AccountCode)
+		}
+	}
+
+	err = db.Debug().Create(&je).Error
+	if err != nil {
+		return &JournalEntry{}, err
+	}
+
+	return je, nil
+}
+
+// transferLines moves an amount from one account to another inside a fund
+func transferLines(debitAcc, creditAcc, fund string, amount int) []JournalLine {
+	return []JournalLine{
+		{AccountCode: debitAcc, Fund: fund, Debit: amount},
+		{AccountCode: creditAcc, Fund: fund, Credit: amount},
+	}
+}
+
+// ReverseJournals posts the mirror image of every journal recorded for a source document
+func ReverseJournals(db *gorm.DB, source string, sourceID uint, memo, uid string) error {
+	entries := []JournalEntry{}
+	err := db.Debug().Model(&JournalEntry{}).Preload("Lines").
+		Where("source = ? AND source_id = ? AND reversal_of IS NULL", source, sourceID).Find(&entries).Error
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		var reversed int64
+		err = db.Debug().Model(&JournalEntry{}).Where("reversal_of = ?", entry.ID).Count(&reversed).Error
+		if err != nil {
+			return err
+		}
+		if reversed > 0 {
+			continue
+		}
+
+		id := entry.ID
+		reversal := JournalEntry{
+			Source:     source,
+			SourceID:   sourceID,
+			Memo:       memo,
+			ReversalOf: &id,
+			CreatedBy:  uid,
+		}
+		for _, line := range entry.Lines {
+			reversal.Lines = append(reversal.Lines, JournalLine{
+				AccountCode: line.AccountCode,
+				Fund:        line.Fund,
+				Debit:       line.Credit,
+				Credit:      line.Debit,
+			})
+		}
+		_, err = reversal.Post(db)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func PostPaymentJournal(db *gorm.DB, p *Payment) error {
+	credit, fund := AccZakatReceipt, FundZakat
+	if p.ObligationType == FundInfaqSadaqah {
+		credit, fund = AccInfaqReceipt, FundInfaqSadaqah
+	}
+
+	je := JournalEntry{
+		Date:      p.PaidAt,
+		Source:    SourcePayment,
+		SourceID:  p.ID,
+		Memo:      fmt.Sprintf("%s %s #%d", p.ObligationType, p.IdMuzakki, p.InstallmentNo),
+		CreatedBy: p.ReceivedBy,
+		Lines:     transferLines(cashAccount(p.Method), credit, fund, p.Amount),
+	}
+	_, err := je.Post(db)
+
+	return err
+}
+
+// PostDistributionJournal books rice at the cost it leaves the warehouse with, amil spending is charged to the amil fund
+func PostDistributionJournal(db *gorm.DB, d *Distribution, riceCost int) error {
+	amount := d.Amount
+	credit := AccCash
+	if d.Kind == KindRice {
+		amount = riceCost
+		credit = AccRiceInventory
+	}
+	// rice that came in without a value leaves without one
+	if amount == 0 {
+		return nil
+	}
+
+	debit, fund := AccZakatSpending, FundZakat
+	if d.Asnaf == AsnafAmil {
+		debit, fund = AccAmilExpense, FundAmil
+	}
+
+	je := JournalEntry{
+		Date:      d.DistributedAt,
+		Source:    SourceDistribution,
+		SourceID:  d.ID,
+		Memo:      fmt.Sprintf("%s %s", d.Asnaf, d.Program),
+		CreatedBy: d.DistributedBy,
+		Lines:     transferLines(debit, credit, fund, amount),
+	}
+	_, err := je.Post(db)
+
+	return err
+}
+
+// PostAmilShareJournal moves the amil share of one payment from its fund to the amil fund
+func PostAmilShareJournal(db *gorm.DB, share *AmilShare, uid string) error {
+	debit := AccZakatAmilShare
+	if share.Fund == FundInfaqSadaqah {
+		debit = AccInfaqAmilShare
+	}
+
+	je := JournalEntry{
+		Date:      share.BookedAt,
+		Source:    SourceAmilShare,
+		SourceID:  share.PaymentID,
+		Memo:      fmt.Sprintf("bagian amil %s%% dari %s", strconv.FormatFloat(share.Percentage, 'f', -1, 64), share.Fund),
+		CreatedBy: uid,
+		Lines: []JournalLine{
+			{AccountCode: debit, Fund: share.Fund, Debit: share.Amount},
+			{AccountCode: AccAmilReceipt, Fund: FundAmil, Credit: share.Amount},
+		},
+	}
+	_, err := je.Post(db)
+
+	return err
+}
+
+func postSimpleJournal(db *gorm.DB, source string, sourceID uint, date time.Time, memo, uid, debit, credit string, amount int) error {
+	je := JournalEntry{
+		Date:      date,
+		Source:    source,
+		SourceID:  sourceID,
+		Memo:      memo,
+		CreatedBy: uid,
+		Lines:     transferLines(debit, credit, FundZakat, amount),
+	}
+	_, err := je.Post(db)
+
+	return err
+}
+
+// Transfer between asset accounts of one fund, e.g. depositing cash to the bank
+func PostTransfer(db *gorm.DB, from, to, fund string, amount int, memo, uid string) (*JournalEntry, error) {
+	if amount <= 0 {
+		return &JournalEntry{}, errors.New("required amount")
+	}
+	for _, code := range []string{from, to} {
+		account := Account{}
+		err := db.Debug().Model(&Account{}).Where("code = ?", code).Take(&account).Error
+		if err != nil {
+			return &JournalEntry{}, errors.New("unknown account " + code)
+		}
+		if account.Type != AccountAsset {
+			return &JournalEntry{}, errors.New("transfers are only allowed between asset accounts")
+		}
+	}
+	if from == to {
+		return &JournalEntry{}, errors.New("transfer accounts must differ")
+	}
+
+	je := JournalEntry{
+		Source:    SourceTransfer,
+		Memo:      memo,
+		CreatedBy: uid,
+		Lines:     transferLines(to, from, fund, amount),
+	}
+
+	return je.Post(db)
+}
+
+func GetJournalEntries(db *gorm.DB, start, end time.Time, source string) (*[]JournalEntry, error) {
+	entries := []JournalEntry{}
+
+	query := db.Debug().Model(&JournalEntry{}).Preload("Lines").Where("date >= ? AND date <= ?", start, end)
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+	err := query.Order("date, id").Find(&entries).Error
+	if err != nil {
+		return &[]JournalEntry{}, err
+	}
+
+	return &entries, nil
+}
+
+type lineSum struct {
+	AccountCode string
+	Debit       int
+	Credit      int
+}
+
+func sumLines(db *gorm.DB, fund string, where string, args ...interface{}) (map[string]lineSum, error) {
+	rows := []lineSum{}
+	query := db.Debug().Model(&JournalLine{}).
+		Select("journal_lines.account_code, COALESCE(SUM(journal_lines.debit), 0) AS debit, COALESCE(SUM(journal_lines.credit), 0) AS credit").
+		Joins("JOIN journal_entries ON journal_entries.id = journal_lines.journal_entry_id AND journal_entries.deleted_at IS NULL").
+		Where(where, args...)
+	if fund != "" {
+		query = query.Where("journal_lines.fund = ?", fund)
+	}
+	err := query.Group("journal_lines.account_code").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sums := map[string]lineSum{}
+	for _, row := range rows {
+		sums[row.AccountCode] = row
+	}
+
+	return sums, nil
+}
+
+// GetAccountBalances returns opening, movement and closing balance per account, signed by the normal side
+func GetAccountBalances(db *gorm.DB, start, end time.Time, fund string) ([]AccountBalance, error) {
+	accounts, err := GetAccounts(db)
+	if err != nil {
+		return nil, err
+	}
+	opening, err := sumLines(db, fund, "journal_entries.date < ?", start)
+	if err != nil {
+		return nil, err
+	}
+	movement, err := sumLines(db, fund, "journal_entries.date >= ? AND journal_entries.date <= ?", start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := []AccountBalance{}
+	for _, account := range *accounts {
+		before, during := opening[account.Code], movement[account.Code]
+		ab := AccountBalance{
+			Code:   account.Code,
+			Name:   account.Name,
+			Type:   account.Type,
+			Fund:   account.Fund,
+			Debit:  during.Debit,
+			Credit: during.Credit,
+		}
+		if account.Normal == NormalDebit {
+			ab.Opening = before.Debit - before.Credit
+			ab.Closing = ab.Opening + during.Debit - during.Credit
+		} else {
+			ab.Opening = before.Credit - before.Debit
+			ab.Closing = ab.Opening + during.Credit - during.Debit
+		}
+		balances = append(balances, ab)
+	}
+
+	return balances, nil
+}
+
+// GetTrialBalance lists every account's closing balance at the end of the period on its debit or credit side
+func GetTrialBalance(db *gorm.DB, start, end time.Time) (*TrialBalance, error) {
+	balances, err := GetAccountBalances(db, start, end, "")
+	if err != nil {
+		return &TrialBalance{}, err
+	}
+	accounts, err := GetAccounts(db)
+	if err != nil {
+		return &TrialBalance{}, err
+	}
+	normal := map[string]string{}
+	for _, account := range *accounts {
+		normal[account.Code] = account.Normal
+	}
+
+	tb := TrialBalance{PeriodStart: start, PeriodEnd: end, Rows: []TrialBalanceRow{}}
+	for _, ab := range balances {
+		if ab.Closing == 0 {
+			continue
+		}
+		row := TrialBalanceRow{Code: ab.Code, Name: ab.Name, Fund: ab.Fund}
+		side := ab.Closing
+		if normal[ab.Code] == NormalCredit {
+			side = -side
+		}
+		if side >= 0 {
+			row.Debit = side
+		} else {
+			row.Credit = -side
+		}
+		tb.TotalDebit += row.Debit
+		tb.TotalCredit += row.Credit
+		tb.Rows = append(tb.Rows, row)
+	}
+	tb.Balanced = tb.TotalDebit == tb.TotalCredit
+
+	return &tb, nil
+}
+
+// ClosePeriod locks a period after checking its trial balance
+func ClosePeriod(db *gorm.DB, start, end time.Time, uid string) (*LedgerPeriod, error) {
+	if !end.After(start) {
+		return &LedgerPeriod{}, errors.New("period end must be after period start")
+	}
+
+	tb, err := GetTrialBalance(db, start, end)
+	if err != nil {
+		return &LedgerPeriod{}, err
+	}
+	if !tb.Balanced {
+		return &LedgerPeriod{}, ErrUnbalancedJournal
+	}
+
+	var overlap int64
+	err = db.Debug().Model(&LedgerPeriod{}).Where("period_start <= ? AND period_end >= ?", end, start).Count(&overlap).Error
+	if err != nil {
+		return &LedgerPeriod{}, err
+	}
+	if overlap > 0 {
+		return &LedgerPeriod{}, errors.New("period overlaps a closed period")
+	}
+
+	lp := LedgerPeriod{PeriodStart: start, PeriodEnd: end, ClosedBy: uid}
+	err = db.Debug().Create(&lp).Error
+	if err != nil {
+		return &LedgerPeriod{}, err
+	}
+
+	return &lp, nil
+}
+
+func GetClosedPeriods(db *gorm.DB) (*[]LedgerPeriod, error) {
+	periods := []LedgerPeriod{}
+	err := db.Debug().Model(&LedgerPeriod{}).Order("period_start").Find(&periods).Error
+	if err != nil {
+		return &[]LedgerPeriod{}, err
+	}
+
+	return &periods, nil
+}
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Mustahik struct {
+	gorm.Model
+	Asnaf              string     `gorm:"size:50;not null" json:"asnaf"`
+	Name               string     `gorm:"size:255;not null" json:"name"`
+	Nik                string     `gorm:"size:16;not null;unique" json:"nik"`
+	Mobile             string     `gorm:"size:255" json:"mobile"`
+	HouseholdSize      int        `gorm:"not null;default:1" json:"household_size"`
+	MonthlyIncome      int        `gorm:"not null;default:0" json:"monthly_income"`
+	Address            string     `gorm:"size:255;not null" json:"address"`
+	District           string     `gorm:"size:100" json:"district"`
+	City               string     `gorm:"size:100;not null" json:"city"`
+	Province           string     `gorm:"size:100" json:"province"`
+	VerificationStatus string     `gorm:"size:20;not null;default:registered" json:"verification_status"`
+	VerifiedUntil      *time.Time `json:"verified_until"`
+}
+
+const (
+	AsnafFakir        = "fakir"
+	AsnafMiskin       = "miskin"
+	AsnafAmil         = "amil"
+	AsnafMuallaf      = "muallaf"
+	AsnafRiqab        = "riqab"
+	AsnafGharim       = "gharim"
+	AsnafFisabilillah = "fisabilillah"
+	AsnafIbnuSabil    = "ibnu sabil"
+
+	MustahikRegistered = "registered"
+	MustahikSurveyed   = "surveyed"
+	MustahikApproved   = "approved"
+	MustahikRejected   = "rejected"
+	MustahikReverify   = "reverify"
+)
+
+var Asnaf = []string{AsnafFakir, AsnafMiskin, AsnafAmil, AsnafMuallaf, AsnafRiqab, AsnafGharim, AsnafFisabilillah, AsnafIbnuSabil}
+
+func ValidAsnaf(asnaf string) bool {
+	for _, a := range Asnaf {
+		if a == asnaf {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Mustahik) Prepare() {
+	m.Asnaf = html.EscapeString(strings.TrimSpace(strings.ToLower(m.Asnaf)))
+	m.Name = html.EscapeString(strings.TrimSpace(m.Name))
+	m.Nik = html.EscapeString(strings.TrimSpace(m.Nik))
+	m.Mobile = html.EscapeString(strings.TrimSpace(m.Mobile))
+	m.Address = html.EscapeString(strings.TrimSpace(m.Address))
+	m.District = html.EscapeString(strings.TrimSpace(m.District))
+	m.City = html.EscapeString(strings.TrimSpace(m.City))
+	m.Province = html.EscapeString(strings.TrimSpace(m.Province))
+	m.VerificationStatus = MustahikRegistered
+	m.VerifiedUntil = nil
+}
+
+func (m *Mustahik) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if !ValidAsnaf(m.Asnaf) {
+		err = errors.New("asnaf must be fakir, miskin, amil, muallaf, riqab, gharim, fisabilillah, or ibnu sabil")
+		errMsg["Invalid_asnaf"] = err.Error()
+	}
+	if m.Name == "" {
+		err = errors.New("required name")
+		errMsg["Required_name"] = err.Error()
+	}
+	if len(m.Nik) != 16 {
+		err = errors.New("nik must be 16 digits")
+		errMsg["Invalid_nik"] = err.Error()
+	}
+	if m.HouseholdSize < 1 {
+		err = errors.New("household size must be at least 1")
+		errMsg["Invalid_household"] = err.Error()
+	}
+	if m.MonthlyIncome < 0 {
+		err = errors.New("monthly income can not be negative")
+		errMsg["Invalid_income"] = err.Error()
+	}
+	if m.Address == "" {
+		err = errors.New("required address")
+		errMsg["Required_address"] = err.Error()
+	}
+	if m.City == "" {
+		err = errors.New("required city")
+		errMsg["Required_city"] = err.Error()
+	}
+
+	return errMsg
+}
+
+func (m *Mustahik) SaveMustahik(db *gorm.DB) (*Mustahik, error) {
+	err := db.Debug().Create(&m).Error
+	if err != nil {
+		return &Mustahik{}, err
+	}
+
+	return m, nil
+}
+
+func (m *Mustahik) GetMustahiks(db *gorm.DB, asnaf, city, status string) (*[]Mustahik, error) {
+	mustahik := []Mustahik{}
+
+	query := db.Debug().Model(&Mustahik{})
+	if asnaf != "" {
+		query = query.Where("asnaf = ?", strings.ToLower(asnaf))
+	}
+	if city != "" {
+		query = query.Where("city = ?", city)
+	}
+	if status != "" {
+		query = query.Where("verification_status = ?", strings.ToLower(status))
+	}
+	err := query.Find(&mustahik).Error
+	if err != nil {
+		return &[]Mustahik{}, err
+	}
+
+	return &mustahik, nil
+}
+
+func (m *Mustahik) GetMustahik(db *gorm.DB, id string) (*Mustahik, error) {
+	err := db.Debug().Model(&Mustahik{}).Where("id = ?", id).Take(&m).Error
+	if err != nil {
+		return &Mustahik{}, err
+	}
+
+	return m, nil
+}
+
+func (m *Mustahik) UpdateMustahik(db *gorm.DB) (*Mustahik, error) {
+	err := db.Debug().Model(&Mustahik{}).Where("id = ?", m.ID).Updates(
+		map[string]interface{}{
+			"asnaf":          m.Asnaf,
+			"name":           m.Name,
+			"nik":            m.Nik,
+			"mobile":         m.Mobile,
+			"household_size": m.HouseholdSize,
+			"monthly_income": m.MonthlyIncome,
+			"address":        m.Address,
+			"district":       m.District,
+			"city":           m.City,
+			"province":       m.Province,
+		},
+	).Error
+	if err != nil {
+		return &Mustahik{}, err
+	}
+
+	err = db.Debug().Model(&Mustahik{}).Where("id = ?", m.ID).Take(&m).Error
+	if err != nil {
+		return &Mustahik{}, err
+	}
+
+	return m, nil
+}
+
+func (m *Mustahik) DeleteMustahik(db *gorm.DB, id string) (int, error) {
+	db = db.Debug().Model(&Mustahik{}).Where("id = ?", id).Take(&Mustahik{}).Delete(&Mustahik{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return int(db.RowsAffected), nil
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type MustahikVerification struct {
+	gorm.Model
+	MustahikID  uint                 `gorm:"not null" json:"mustahik_id"`
+	FromStatus  string               `gorm:"size:20;not null" json:"from_status"`
+	ToStatus    string               `gorm:"size:20;not null" json:"to_status"`
+	Notes       string               `gorm:"type:text" json:"notes"`
+	ActorID     string               `gorm:"size:255;not null" json:"actor_id"`
+	Attachments []MustahikAttachment `gorm:"foreignKey:VerificationID" json:"attachments"`
+}
+
+type MustahikAttachment struct {
+	gorm.Model
+	MustahikID     uint   `gorm:"not null" json:"mustahik_id"`
+	VerificationID uint   `gorm:"not null" json:"verification_id"`
+	FileName       string `gorm:"size:255;not null" json:"file_name"`
+	Path           string `gorm:"size:255;not null" json:"-"`
+	ContentType    string `gorm:"size:100" json:"content_type"`
+}
+
+const (
+	// approved mustahik have to be surveyed again after this many months
+	Reverify_months = 12
+)
+
+// allowed moves of the verification workflow, keyed by target status
+var mustahikTransitions = map[string][]string{
+	MustahikSurveyed: {MustahikRegistered, MustahikReverify},
+	MustahikApproved: {MustahikSurveyed},
+	MustahikRejected: {MustahikSurveyed},
+	MustahikReverify: {MustahikApproved},
+}
+
+var ErrInvalidTransition = errors.New("mustahik can not move to this verification status")
+
+// Transition moves the mustahik to the next status and keeps a log row with the notes and attachments
+func (m *Mustahik) Transition(db *gorm.DB, to, uid, notes string, attachments []MustahikAttachment) (*MustahikVerification, error) {
+	allowed := false
+	for _, from := range mustahikTransitions[to] {
+		if m.VerificationStatus == from {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return &MustahikVerification{}, ErrInvalidTransition
+	}
+
+	updates := map[string]interface{}{
+		"verification_status": to,
+	}
+	if to == MustahikApproved {
+		until := time.Now().AddDate(0, Reverify_months, 0)
+		updates["verified_until"] = &until
+	}
+
+	mv := MustahikVerification{
+		MustahikID:  m.ID,
+		FromStatus:  m.VerificationStatus,
+		ToStatus:    to,
+		Notes:       html.EscapeString(strings.TrimSpace(notes)),
+		ActorID:     uid,
+		Attachments: attachments,
+	}
+	for i := range mv.Attachments {
+		mv.Attachments[i].MustahikID = m.ID
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Debug().Model(&Mustahik{}).Where("id = ? AND verification_status = ?", m.ID, m.VerificationStatus).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidTransition
+		}
+
+		return tx.Debug().Create(&mv).Error
+	})
+	if err != nil {
+		return &MustahikVerification{}, err
+	}
+
+	m.VerificationStatus = to
+
+	return &mv, nil
+}
+
+// MarkReverificationDue sends approved mustahik whose approval expired back into the workflow
+func MarkReverificationDue(db *gorm.DB, uid string) (int, error) {
+	due := []Mustahik{}
+	err := db.Debug().Model(&Mustahik{}).Where("verification_status = ? AND verified_until < ?", MustahikApproved, time.Now()).Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range due {
+		_, err = due[i].Transition(db, MustahikReverify, uid, "periodic re-verification", nil)
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(due), nil
+}
+
+func (mv *MustahikVerification) GetVerifications(db *gorm.DB, mustahikID string) (*[]MustahikVerification, error) {
+	verifications := []MustahikVerification{}
+	err := db.Debug().Model(&MustahikVerification{}).Preload("Attachments").Where("mustahik_id = ?", mustahikID).Order("created_at").Find(&verifications).Error
+	if err != nil {
+		return &[]MustahikVerification{}, err
+	}
+
+	return &verifications, nil
+}
+
+func (ma *MustahikAttachment) GetAttachment(db *gorm.DB, id string) (*MustahikAttachment, error) {
+	err := db.Debug().Model(&MustahikAttachment{}).Where("id = ?", id).Take(&ma).Error
+	if err != nil {
+		return &MustahikAttachment{}, err
+	}
+
+	return ma, nil
+}
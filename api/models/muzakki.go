@@ -10,19 +10,60 @@ import (
 
 type Muzakki struct {
 	gorm.Model
-	MuzakkiId    string      `gorm:"not null;unique"`
-	Name         string      `gorm:"size:255;not null" json:"name"`
-	Mobile       string      `gorm:"size:255;not null" json:"mobile"`
-	Address      string      `gorm:"size:255;not null" json:"address"`
-	ZakatFitrahs ZakatFitrah `gorm:"foreignKey:IdMuzakki;references:MuzakkiId"`
-	ZakatMals    []ZakatMal  `gorm:"foreignKey:IdMuzakki;references:MuzakkiId"`
+	MuzakkiId    string           `gorm:"not null;unique"`
+	EntityType   string           `gorm:"size:20;not null;default:individual" json:"entity_type"`
+	Name         string           `gorm:"size:255;not null" json:"name"`
+	LegalName    string           `gorm:"size:255" json:"legal_name"`
+	Mobile       string           `gorm:"size:255;not null" json:"mobile"`
+	Address      string           `gorm:"size:255;not null" json:"address"`
+	Npwp         string           `gorm:"size:20" json:"npwp"`
+	Npwz         string           `gorm:"size:50" json:"npwz"`
+	Contacts     []MuzakkiContact `gorm:"foreignKey:IdMuzakki;references:MuzakkiId" json:"contacts"`
+	ZakatFitrahs ZakatFitrah      `gorm:"foreignKey:IdMuzakki;references:MuzakkiId"`
+	ZakatMals    []ZakatMal       `gorm:"foreignKey:IdMuzakki;references:MuzakkiId"`
 }
 
+// MuzakkiContact is a person in charge (PIC) who files and pays zakat on behalf of a company
+type MuzakkiContact struct {
+	gorm.Model
+	IdMuzakki string `gorm:"column:id_muzakki;size:255;not null;index" json:"id_muzakki"`
+	Name      string `gorm:"size:255;not null" json:"name"`
+	Position  string `gorm:"size:100" json:"position"`
+	Mobile    string `gorm:"size:255;not null" json:"mobile"`
+	Email     string `gorm:"size:100" json:"email"`
+	IsPrimary bool   `gorm:"not null;default:false" json:"is_primary"`
+}
+
+const (
+	EntityIndividual = "individual"
+	EntityCompany    = "company"
+)
+
 func (m *Muzakki) Prepare(uid string) {
 	m.MuzakkiId = uid
+	m.EntityType = strings.TrimSpace(strings.ToLower(m.EntityType))
+	if m.EntityType == "" {
+		m.EntityType = EntityIndividual
+	}
 	m.Name = html.EscapeString(strings.TrimSpace(m.Name))
+	m.LegalName = html.EscapeString(strings.TrimSpace(m.LegalName))
 	m.Address = html.EscapeString(strings.TrimSpace(m.Address))
 	m.Mobile = html.EscapeString(strings.TrimSpace(m.Mobile))
+	m.Npwp = NormalizeNpwp(m.Npwp)
+	m.Npwz = html.EscapeString(strings.TrimSpace(strings.ToUpper(m.Npwz)))
+	// only a company acts through a PIC
+	if m.EntityType != EntityCompany {
+		m.LegalName = ""
+		m.Contacts = nil
+	}
+	for i := range m.Contacts {
+		m.Contacts[i].ID = 0
+		m.Contacts[i].IdMuzakki = uid
+		m.Contacts[i].Name = html.EscapeString(strings.TrimSpace(m.Contacts[i].Name))
+		m.Contacts[i].Position = html.EscapeString(strings.TrimSpace(m.Contacts[i].Position))
+		m.Contacts[i].Mobile = html.EscapeString(strings.TrimSpace(m.Contacts[i].Mobile))
+		m.Contacts[i].Email = html.EscapeString(strings.TrimSpace(strings.ToLower(m.Contacts[i].Email)))
+	}
 	m.ZakatFitrahs = ZakatFitrah{}
 	m.ZakatMals = []ZakatMal{}
 }
@@ -43,6 +84,34 @@ func (m *Muzakki) Validate() map[string]string {
 		err = errors.New("required mobile")
 		errMsg["Required_mobile"] = err.Error()
 	}
+	if m.Npwp != "" && len(m.Npwp) != 15 && len(m.Npwp) != 16 {
+		err = errors.New("npwp must be 15 or 16 digits")
+		errMsg["Invalid_npwp"] = err.Error()
+	}
+	if m.EntityType != EntityIndividual && m.EntityType != EntityCompany {
+		err = errors.New("entity type must be individual or company")
+		errMsg["Invalid_entityType"] = err.Error()
+	}
+	if m.EntityType == EntityCompany {
+		if m.LegalName == "" {
+			err = errors.New("required legal name for a company")
+			errMsg["Required_legalName"] = err.Error()
+		}
+		if m.Npwp == "" {
+			err = errors.New("required npwp for a company")
+			errMsg["Required_npwp"] = err.Error()
+		}
+		if len(m.Contacts) == 0 {
+			err = errors.New("required at least one pic contact for a company")
+			errMsg["Required_contacts"] = err.Error()
+		}
+		for _, contact := range m.Contacts {
+			if contact.Name == "" || contact.Mobile == "" {
+				err = errors.New("every pic contact needs a name and a mobile")
+				errMsg["Invalid_contacts"] = err.Error()
+			}
+		}
+	}
 
 	return errMsg
 }
@@ -58,7 +127,7 @@ func (m *Muzakki) SaveMuzakki(db *gorm.DB) (*Muzakki, error) {
 
 func (m *Muzakki) GetMuzakkis(db *gorm.DB) (*[]Muzakki, error) {
 	muzakki := []Muzakki{}
-	err := db.Debug().Preload("ZakatFitrahs").Preload("ZakatMals").Find(&muzakki).Error
+	err := db.Debug().Preload("Contacts").Preload("ZakatFitrahs").Preload("ZakatMals").Find(&muzakki).Error
 	if err != nil {
 		return &[]Muzakki{}, err
 	}
@@ -67,25 +136,45 @@ func (m *Muzakki) GetMuzakkis(db *gorm.DB) (*[]Muzakki, error) {
 }
 
 func (m *Muzakki) GetMuzakki(db *gorm.DB, mID string) (*Muzakki, error) {
-	err := db.Debug().Preload("ZakatFitrahs").Preload("ZakatMals").Where("muzakki_id = ?", mID).Find(&m).Error
+	err := db.Debug().Preload("Contacts").Preload("ZakatFitrahs").Preload("ZakatMals").Where("muzakki_id = ?", mID).Find(&m).Error
 	errors.Is(err, gorm.ErrRecordNotFound)
 
 	return m, err
 }
 
 func (m *Muzakki) UpdateMuzakki(db *gorm.DB) (*Muzakki, error) {
-	db = db.Debug().Model(&Muzakki{}).Where("id = ?", m.ID).Take(&Muzakki{}).UpdateColumns(
-		map[string]interface{}{
-			"name":    m.Name,
-			"mobile":  m.Mobile,
-			"address": m.Address,
-		},
-	)
-	if db.Error != nil {
-		return &Muzakki{}, db.Error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Debug().Model(&Muzakki{}).Where("id = ?", m.ID).Take(&Muzakki{}).UpdateColumns(
+			map[string]interface{}{
+				"entity_type": m.EntityType,
+				"name":        m.Name,
+				"legal_name":  m.LegalName,
+				"mobile":      m.Mobile,
+				"address":     m.Address,
+				"npwp":        m.Npwp,
+				"npwz":        m.Npwz,
+			},
+		).Error
+		if err != nil {
+			return err
+		}
+
+		// the contacts sent replace the ones on file
+		err = tx.Debug().Where("id_muzakki = ?", m.MuzakkiId).Delete(&MuzakkiContact{}).Error
+		if err != nil {
+			return err
+		}
+		if len(m.Contacts) > 0 {
+			return tx.Debug().Create(&m.Contacts).Error
+		}
+
+		return nil
+	})
+	if err != nil {
+		return &Muzakki{}, err
 	}
 
-	err := db.Debug().Preload("ZakatFitrahs").Preload("ZakatMals").Where("id = ?", m.ID).Find(&m).Error
+	err = db.Debug().Preload("Contacts").Preload("ZakatFitrahs").Preload("ZakatMals").Where("id = ?", m.ID).Find(&m).Error
 	if err != nil {
 		return &Muzakki{}, err
 	}
@@ -93,6 +182,15 @@ func (m *Muzakki) UpdateMuzakki(db *gorm.DB) (*Muzakki, error) {
 	return m, nil
 }
 
+// DisplayName is the name printed on receipts and statements, a company is always named by its legal name
+func (m *Muzakki) DisplayName() string {
+	if m.EntityType == EntityCompany && m.LegalName != "" {
+		return m.LegalName
+	}
+
+	return m.Name
+}
+
 func (m *Muzakki) DeleteMuzakki(db *gorm.DB, uID string) (int, error) {
 	db = db.Debug().Model(&Muzakki{}).Where("id = ?", uID).Take(&Muzakki{}).Delete(&Muzakki{})
 	if db.Error != nil {
@@ -100,3 +198,15 @@ func (m *Muzakki) DeleteMuzakki(db *gorm.DB, uID string) (int, error) {
 	}
 	return int(db.RowsAffected), nil
 }
+
+// NormalizeNpwp keeps only the digits, "01.234.567.8-901.000" is stored as "012345678901000"
+func NormalizeNpwp(npwp string) string {
+	var b strings.Builder
+	for _, r := range npwp {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Organization struct {
+	gorm.Model
+	Code          string `gorm:"size:20;not null;unique" json:"code"`
+	Name          string `gorm:"size:255;not null" json:"name"`
+	Address       string `gorm:"size:255" json:"address"`
+	Phone         string `gorm:"size:50" json:"phone"`
+	Email         string `gorm:"size:255" json:"email"`
+	ReceiptPrefix string `gorm:"size:50;not null" json:"receipt_prefix"`
+	ReceiptFormat string `gorm:"size:100;not null;default:'{prefix}/{year}/{seq}'" json:"receipt_format"`
+	ReceiptDigits int    `gorm:"not null;default:6" json:"receipt_digits"`
+	IsDefault     bool   `gorm:"not null;default:false" json:"is_default"`
+	PublicKey     string `gorm:"size:100" json:"public_key"`
+	SigningSeed   string `gorm:"size:100" json:"-"`
+}
+
+// ReceiptSequence holds the last receipt number given out per organization and year
+type ReceiptSequence struct {
+	gorm.Model
+	OrganizationID uint `gorm:"not null;uniqueIndex:idx_receipt_sequence" json:"organization_id"`
+	Year           int  `gorm:"not null;uniqueIndex:idx_receipt_sequence" json:"year"`
+	LastNumber     int  `gorm:"not null;default:0" json:"last_number"`
+}
+
+const Default_receipt_format = "{prefix}/{year}/{seq}"
+
+func (o *Organization) Prepare() {
+	o.Code = html.EscapeString(strings.TrimSpace(strings.ToUpper(o.Code)))
+	o.Name = html.EscapeString(strings.TrimSpace(o.Name))
+	o.Address = html.EscapeString(strings.TrimSpace(o.Address))
+	o.Phone = html.EscapeString(strings.TrimSpace(o.Phone))
+	o.Email = html.EscapeString(strings.TrimSpace(o.Email))
+	o.ReceiptPrefix = strings.TrimSpace(strings.ToUpper(o.ReceiptPrefix))
+	if o.ReceiptPrefix == "" {
+		o.ReceiptPrefix = o.Code + "/BSZ"
+	}
+	o.ReceiptFormat = strings.TrimSpace(o.ReceiptFormat)
+	if o.ReceiptFormat == "" {
+		o.ReceiptFormat = Default_receipt_format
+	}
+	if o.ReceiptDigits == 0 {
+		o.ReceiptDigits = 6
+	}
+}
+
+func (o *Organization) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if o.Code == "" {
+		err = errors.New("required code")
+		errMsg["Required_code"] = err.Error()
+	}
+	if o.Name == "" {
+		err = errors.New("required name")
+		errMsg["Required_name"] = err.Error()
+	}
+	// the sequence starts again every year, without the year the first receipt of a new year repeats a number
+	if !strings.Contains(o.ReceiptFormat, "{seq}") || !strings.Contains(o.ReceiptFormat, "{year}") {
+		err = errors.New("receipt format must contain {year} and {seq}")
+		errMsg["Invalid_receiptFormat"] = err.Error()
+	}
+	if o.ReceiptDigits < 1 || o.ReceiptDigits > 10 {
+		err = errors.New("receipt digits must be between 1 and 10")
+		errMsg["Invalid_receiptDigits"] = err.Error()
+	}
+
+	return errMsg
+}
+
+// SeedOrganization creates the default organization on a fresh database
+func SeedOrganization(db *gorm.DB, name, address string) {
+	var count int64
+	db.Debug().Model(&Organization{}).Count(&count)
+	if count > 0 {
+		return
+	}
+	if name == "" {
+		name = "Kalkulator Zakat"
+	}
+
+	org := Organization{Code: "ORG", Name: name, Address: address, IsDefault: true}
+	org.Prepare()
+	if org.GenerateSigningKey() != nil {
+		return
+	}
+	db.Debug().Create(&org)
+}
+
+func (o *Organization) SaveOrganization(db *gorm.DB) (*Organization, error) {
+	err := o.GenerateSigningKey()
+	if err != nil {
+		return &Organization{}, err
+	}
+
+	err = db.Debug().Create(&o).Error
+	if err != nil {
+		return &Organization{}, err
+	}
+
+	return o, nil
+}
+
+func (o *Organization) UpdateOrganization(db *gorm.DB) (*Organization, error) {
+	err := db.Debug().Model(&Organization{}).Where("id = ?", o.ID).Updates(map[string]interface{}{
+		"name":           o.Name,
+		"address":        o.Address,
+		"phone":          o.Phone,
+		"email":          o.Email,
+		"receipt_prefix": o.ReceiptPrefix,
+		"receipt_format": o.ReceiptFormat,
+		"receipt_digits": o.ReceiptDigits,
+	}).Error
+	if err != nil {
+		return &Organization{}, err
+	}
+
+	err = db.Debug().Model(&Organization{}).Where("id = ?", o.ID).Take(&o).Error
+	if err != nil {
+		return &Organization{}, err
+	}
+
+	return o, nil
+}
+
+func (o *Organization) GetOrganizations(db *gorm.DB) (*[]Organization, error) {
+	orgs := []Organization{}
+	err := db.Debug().Model(&Organization{}).Order("code").Find(&orgs).Error
+	if err != nil {
+		return &[]Organization{}, err
+	}
+
+	return &orgs, nil
+}
+
+func (o *Organization) GetOrganization(db *gorm.DB, id string) (*Organization, error) {
+	err := db.Debug().Model(&Organization{}).Where("id = ?", id).Take(&o).Error
+	if err != nil {
+		return &Organization{}, err
+	}
+
+	return o, nil
+}
+
+// GetReceiptOrganization returns the given organization, or the default one when none is given
+func GetReceiptOrganization(db *gorm.DB, id *uint) (*Organization, error) {
+	org := Organization{}
+	query := db.Debug().Model(&Organization{})
+	if id != nil {
+		query = query.Where("id = ?", *id)
+	} else {
+		query = query.Order("is_default DESC, id")
+	}
+	err := query.Take(&org).Error
+	if err != nil {
+		return &Organization{}, err
+	}
+
+	return &org, nil
+}
+
+// NextReceiptNumber locks the sequence row so numbers stay gap-free, it must run inside the issuing transaction
+func NextReceiptNumber(tx *gorm.DB, org *Organization, year int) (int, string, error) {
+	seq := ReceiptSequence{}
+	err := tx.Debug().Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("organization_id = ? AND year = ?", org.ID, year).Take(&seq).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// two first receipts of the year may both get here, the second insert waits and does nothing
+		err = tx.Debug().Clauses(clause.OnConflict{DoNothing: true}).Create(&ReceiptSequence{OrganizationID: org.ID, Year: year}).Error
+		if err != nil {
+			return 0, "", err
+		}
+		err = tx.Debug().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("organization_id = ? AND year = ?", org.ID, year).Take(&seq).Error
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	seq.LastNumber++
+	err = tx.Debug().Model(&ReceiptSequence{}).Where("id = ?", seq.ID).Update("last_number", seq.LastNumber).Error
+	if err != nil {
+		return 0, "", err
+	}
+
+	return seq.LastNumber, org.FormatReceiptNumber(year, seq.LastNumber), nil
+}
+
+func (o *Organization) FormatReceiptNumber(year, seq int) string {
+	return strings.NewReplacer(
+		"{prefix}", o.ReceiptPrefix,
+		"{code}", o.Code,
+		"{year}", strconv.Itoa(year),
+		"{seq}", fmt.Sprintf("%0*d", o.ReceiptDigits, seq),
+	).Replace(o.ReceiptFormat)
+}
@@ -0,0 +1,479 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Payment struct {
+	gorm.Model
+	ObligationType    string              `gorm:"size:50;not null;index:idx_payment_obligation" json:"obligation_type"`
+	ObligationID      uint                `gorm:"not null;index:idx_payment_obligation" json:"obligation_id"`
+	IdMuzakki         string              `gorm:"column:id_muzakki;size:255;not null" json:"id_muzakki"`
+	Method            string              `gorm:"size:20;not null" json:"method"`
+	Amount            int                 `gorm:"not null" json:"amount"`
+	Weight            float64             `gorm:"not null;default:0" json:"weight"`
+	WarehouseID       *uint               `json:"warehouse_id"`
+	InstallmentNo     int                 `gorm:"not null" json:"installment_no"`
+	Reference         string              `gorm:"size:255" json:"reference"`
+	Note              string              `gorm:"size:255" json:"note"`
+	PaidAt            time.Time           `gorm:"not null" json:"paid_at"`
+	ReceivedBy        string              `gorm:"size:255;not null" json:"received_by"`
+	OrganizationID    *uint               `json:"organization_id"`
+	CollectionPointID *uint               `gorm:"index" json:"collection_point_id"`
+	Receipt           *Receipt            `gorm:"foreignKey:PaymentID" json:"receipt,omitempty"`
+	AmilShares        []AmilShare         `gorm:"foreignKey:PaymentID" json:"amil_shares,omitempty"`
+	Allocations       []PaymentAllocation `gorm:"foreignKey:PaymentID" json:"allocations,omitempty"`
+	Status            string              `gorm:"size:20;not null;default:recorded" json:"status"`
+	RefundedAmount    int                 `gorm:"not null;default:0" json:"refunded_amount"`
+}
+
+// PaymentAllocation is the part of one payment that settles one obligation, a company paying the zakat of its
+// employees in one transfer splits the payment this way and every employee gets a receipt for their part
+type PaymentAllocation struct {
+	gorm.Model
+	PaymentID      uint     `gorm:"not null;index" json:"payment_id"`
+	ObligationType string   `gorm:"size:50;not null;index:idx_allocation_obligation" json:"obligation_type"`
+	ObligationID   uint     `gorm:"not null;index:idx_allocation_obligation" json:"obligation_id"`
+	IdMuzakki      string   `gorm:"column:id_muzakki;size:255;not null" json:"id_muzakki"`
+	Amount         int      `gorm:"not null" json:"amount"`
+	Receipt        *Receipt `gorm:"foreignKey:AllocationID" json:"receipt,omitempty"`
+}
+
+// ObligationPayment is the payment state of one zakat obligation, always computed from its payments and the parts
+// of split payments allocated to it
+type ObligationPayment struct {
+	ObligationType string              `json:"obligation_type"`
+	ObligationID   uint                `json:"obligation_id"`
+	IdMuzakki      string              `json:"id_muzakki"`
+	Due            int                 `json:"due"`
+	Paid           int                 `json:"paid"`
+	Balance        int                 `json:"balance"`
+	Status         string              `json:"status"`
+	Payments       []Payment           `json:"payments"`
+	Allocations    []PaymentAllocation `json:"allocations"`
+}
+
+const (
+	MethodCash     = "cash"
+	MethodTransfer = "transfer"
+	MethodQris     = "qris"
+	MethodInKind   = "in_kind"
+
+	PaymentUnpaid   = "unpaid"
+	PaymentPartial  = "partial"
+	PaymentPaid     = "paid"
+	PaymentOverpaid = "overpaid"
+
+	// a payment is recorded until it is voided or refunded in full
+	PaymentRecorded = "recorded"
+	PaymentVoid     = "void"
+	PaymentRefunded = "refunded"
+)
+
+var (
+	ErrObligationHasPayments = errors.New("obligation already has payments")
+	ErrPaymentWarehouse      = errors.New("required warehouse for rice paid in kind")
+	ErrAllocationTotal       = errors.New("allocations must add up to the payment amount")
+)
+
+func (p *Payment) Prepare(uid string) {
+	p.ObligationType = strings.TrimSpace(strings.ToLower(p.ObligationType))
+	p.Method = strings.TrimSpace(strings.ToLower(p.Method))
+	p.Reference = html.EscapeString(strings.TrimSpace(p.Reference))
+	p.Note = html.EscapeString(strings.TrimSpace(p.Note))
+	if p.PaidAt.IsZero() {
+		p.PaidAt = time.Now()
+	}
+	p.ReceivedBy = uid
+	// only the payroll import splits a payment, a request can not
+	p.Allocations = nil
+}
+
+func (p *Payment) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if p.ObligationType != FundZakatFitrah && p.ObligationType != FundZakatMal && p.ObligationType != FundInfaqSadaqah {
+		err = errors.New("obligation type must be zakat_fitrah, zakat_mal, or infaq_sadaqah")
+		errMsg["Invalid_obligationType"] = err.Error()
+	}
+	// infaq is given freely and is not booked against an obligation
+	if p.ObligationType == FundInfaqSadaqah {
+		p.ObligationID = 0
+	} else if p.ObligationID == 0 {
+		err = errors.New("required obligation")
+		errMsg["Required_obligation"] = err.Error()
+	}
+	switch p.Method {
+	case MethodCash, MethodTransfer, MethodQris:
+		if p.Amount <= 0 {
+			err = errors.New("required amount")
+			errMsg["Required_amount"] = err.Error()
+		}
+	case MethodInKind:
+		if p.ObligationType != FundZakatFitrah {
+			err = errors.New("in-kind payment is only accepted for zakat fitrah")
+			errMsg["Invalid_method"] = err.Error()
+		}
+		if p.Weight <= 0 {
+			err = errors.New("required weight")
+			errMsg["Required_weight"] = err.Error()
+		}
+	default:
+		err = errors.New("method must be cash, transfer, qris, or in_kind")
+		errMsg["Invalid_method"] = err.Error()
+	}
+
+	return errMsg
+}
+
+// PaymentStatus compares what was paid against what is due
+func PaymentStatus(due, paid int) string {
+	switch {
+	case paid <= 0:
+		return PaymentUnpaid
+	case paid < due:
+		return PaymentPartial
+	case paid == due:
+		return PaymentPaid
+	default:
+		return PaymentOverpaid
+	}
+}
+
+// obligationDue looks up the muzakki and the amount due of an obligation
+func obligationDue(db *gorm.DB, obligationType string, id uint) (string, int, float64, error) {
+	switch obligationType {
+	case FundZakatFitrah:
+		zf := ZakatFitrah{}
+		err := db.Debug().Model(&ZakatFitrah{}).Where("id = ?", id).Take(&zf).Error
+		if err != nil {
+			return "", 0, 0, err
+		}
+		return zf.IdMuzakki, zf.TotalPrice, zf.RicePrice, nil
+	case FundZakatMal:
+		zm := ZakatMal{}
+		err := db.Debug().Model(&ZakatMal{}).Where("id = ?", id).Take(&zm).Error
+		if err != nil {
+			return "", 0, 0, err
+		}
+		return zm.IdMuzakki, zm.TotalZakat, 0, nil
+	}
+
+	return "", 0, 0, errors.New("unknown obligation type " + obligationType)
+}
+
+// SavePayment records one payment or installment against an obligation
+func (p *Payment) SavePayment(db *gorm.DB) (*Payment, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := activeCollectionPoint(tx, p.CollectionPointID)
+		if err != nil {
+			return err
+		}
+
+		p.Status = PaymentRecorded
+		p.RefundedAmount = 0
+		p.InstallmentNo = 1
+		if len(p.Allocations) > 0 {
+			// a split payment belongs to no single obligation, every allocation names its own
+			total := 0
+			for _, a := range p.Allocations {
+				total += a.Amount
+			}
+			if total != p.Amount || p.Method == MethodInKind {
+				return ErrAllocationTotal
+			}
+			p.ObligationID = 0
+		} else if p.ObligationType != FundInfaqSadaqah {
+			muzakki, _, ricePrice, err := obligationDue(tx, p.ObligationType, p.ObligationID)
+			if err != nil {
+				return err
+			}
+			p.IdMuzakki = muzakki
+
+			// rice is valued at the price the obligation was calculated with
+			if p.Method == MethodInKind && p.Amount <= 0 {
+				p.Amount = int(math.Ceil(p.Weight * ricePrice))
+			}
+			if p.Method == MethodInKind && p.WarehouseID == nil {
+				zf := ZakatFitrah{}
+				err = tx.Debug().Model(&ZakatFitrah{}).Where("id = ?", p.ObligationID).Take(&zf).Error
+				if err != nil {
+					return err
+				}
+				p.WarehouseID = zf.WarehouseID
+			}
+			if p.Method == MethodInKind && p.WarehouseID == nil {
+				return ErrPaymentWarehouse
+			}
+
+			var count int64
+			err = tx.Debug().Model(&Payment{}).Where("obligation_type = ? AND obligation_id = ?", p.ObligationType, p.ObligationID).Count(&count).Error
+			if err != nil {
+				return err
+			}
+			p.InstallmentNo = int(count) + 1
+		}
+
+		err = tx.Debug().Omit("Allocations").Create(&p).Error
+		if err != nil {
+			return err
+		}
+		for i := range p.Allocations {
+			p.Allocations[i].PaymentID = p.ID
+			p.Allocations[i].ObligationType = p.ObligationType
+			err = tx.Debug().Omit("Receipt").Create(&p.Allocations[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		err = PostPaymentJournal(tx, p)
+		if err != nil {
+			return err
+		}
+
+		// rice paid in kind enters the warehouse with the payment, not with the obligation
+		if p.Method == MethodInKind {
+			err = ReceivePayment(tx, p)
+			if err != nil {
+				return err
+			}
+		}
+
+		share, err := BookAmilShare(tx, p)
+		if err != nil {
+			return err
+		}
+		if share.ID != 0 {
+			p.AmilShares = []AmilShare{*share}
+		}
+
+		if len(p.Allocations) == 0 {
+			p.Receipt, err = IssueReceipt(tx, p)
+			return err
+		}
+		for i := range p.Allocations {
+			p.Allocations[i].Receipt, err = IssueAllocationReceipt(tx, p, &p.Allocations[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return &Payment{}, err
+	}
+
+	return p, nil
+}
+
+func (p *Payment) GetPayments(db *gorm.DB, muzakki, obligationType, method string) (*[]Payment, error) {
+	payments := []Payment{}
+
+	query := db.Debug().Model(&Payment{})
+	if muzakki != "" {
+		query = query.Where("id_muzakki = ?", muzakki)
+	}
+	if obligationType != "" {
+		query = query.Where("obligation_type = ?", obligationType)
+	}
+	if method != "" {
+		query = query.Where("method = ?", method)
+	}
+	err := query.Order("paid_at DESC").Find(&payments).Error
+	if err != nil {
+		return &[]Payment{}, err
+	}
+
+	return &payments, nil
+}
+
+func (p *Payment) GetPayment(db *gorm.DB, id string) (*Payment, error) {
+	err := db.Debug().Model(&Payment{}).Preload("Receipt", "allocation_id IS NULL").Preload("AmilShares").Preload("Allocations.Receipt").
+		Where("id = ?", id).Take(&p).Error
+	if err != nil {
+		return &Payment{}, err
+	}
+
+	return p, nil
+}
+
+// GetObligationPayment returns the payments of an obligation together with its computed status
+func GetObligationPayment(db *gorm.DB, obligationType string, id uint) (*ObligationPayment, error) {
+	muzakki, due, _, err := obligationDue(db, obligationType, id)
+	if err != nil {
+		return &ObligationPayment{}, err
+	}
+
+	payments := []Payment{}
+	err = db.Debug().Model(&Payment{}).Where("obligation_type = ? AND obligation_id = ?", obligationType, id).Order("installment_no").Find(&payments).Error
+	if err != nil {
+		return &ObligationPayment{}, err
+	}
+
+	allocations := []PaymentAllocation{}
+	err = db.Debug().Model(&PaymentAllocation{}).Preload("Receipt").Where("obligation_type = ? AND obligation_id = ?", obligationType, id).Find(&allocations).Error
+	if err != nil {
+		return &ObligationPayment{}, err
+	}
+	paid, err := paidAmounts(db, obligationType, []uint{id})
+	if err != nil {
+		return &ObligationPayment{}, err
+	}
+
+	op := ObligationPayment{
+		ObligationType: obligationType,
+		ObligationID:   id,
+		IdMuzakki:      muzakki,
+		Due:            due,
+		Paid:           paid[id],
+		Payments:       payments,
+		Allocations:    allocations,
+	}
+	op.Balance = op.Due - op.Paid
+	op.Status = PaymentStatus(op.Due, op.Paid)
+
+	return &op, nil
+}
+
+// NetAmount is what the payment still counts for after voids and refunds
+func (p *Payment) NetAmount() int {
+	if p.Status == PaymentVoid {
+		return 0
+	}
+
+	return p.Amount - p.RefundedAmount
+}
+
+// paidAmounts sums the payments per obligation id of one obligation type
+func paidAmounts(db *gorm.DB, obligationType string, ids []uint) (map[uint]int, error) {
+	paid := map[uint]int{}
+	if len(ids) == 0 {
+		return paid, nil
+	}
+
+	rows := []struct {
+		ObligationID uint
+		Total        int
+	}{}
+	err := db.Debug().Model(&Payment{}).Select("obligation_id, SUM(amount - refunded_amount) AS total").
+		Where("obligation_type = ? AND obligation_id IN ? AND status <> ?", obligationType, ids, PaymentVoid).
+		Group("obligation_id").Scan(&rows).Error
+	if err != nil {
+		return paid, err
+	}
+	for _, row := range rows {
+		paid[row.ObligationID] = row.Total
+	}
+
+	// split payments can not be refunded in part, an allocation counts in full until its payment is voided
+	rows = rows[:0]
+	err = db.Debug().Model(&PaymentAllocation{}).Select("payment_allocations.obligation_id, SUM(payment_allocations.amount) AS total").
+		Joins("JOIN payments ON payments.id = payment_allocations.payment_id").
+		Where("payment_allocations.obligation_type = ? AND payment_allocations.obligation_id IN ? AND payments.status <> ?", obligationType, ids, PaymentVoid).
+		Group("payment_allocations.obligation_id").Scan(&rows).Error
+	if err != nil {
+		return paid, err
+	}
+	for _, row := range rows {
+		paid[row.ObligationID] += row.Total
+	}
+
+	return paid, nil
+}
+
+// allocatedObligations selects the ids of the obligations settled by a part of a recorded payment
+func allocatedObligations(db *gorm.DB, obligationType string) *gorm.DB {
+	return db.Model(&PaymentAllocation{}).Select("payment_allocations.obligation_id").
+		Joins("JOIN payments ON payments.id = payment_allocations.payment_id").
+		Where("payment_allocations.obligation_type = ? AND payments.status = ?", obligationType, PaymentRecorded)
+}
+
+// collected sums what the payments of one obligation type brought in net of refunds and voids, rice paid in kind
+// in kg and the rest in rupiah; a zero start or end leaves that side of the period open
+func collected(db *gorm.DB, obligationType string, inKind bool, start, end time.Time) (float64, error) {
+	var total float64
+	query := db.Debug().Model(&Payment{}).Where("obligation_type = ? AND status <> ?", obligationType, PaymentVoid)
+	if !start.IsZero() {
+		query = query.Where("paid_at >= ?", start)
+	}
+	if !end.IsZero() {
+		query = query.Where("paid_at <= ?", end)
+	}
+	if inKind {
+		query = query.Where("method = ?", MethodInKind).
+			Select("COALESCE(SUM(weight * (amount - refunded_amount) / NULLIF(amount, 0)), 0)")
+	} else {
+		query = query.Where("method <> ?", MethodInKind).Select("COALESCE(SUM(amount - refunded_amount), 0)")
+	}
+	err := query.Scan(&total).Error
+
+	return total, err
+}
+
+// hasPayments ignores payments that were voided or refunded, the obligation can be removed once nothing stands against it
+func hasPayments(db *gorm.DB, obligationType string, id uint) (bool, error) {
+	var count int64
+	err := db.Debug().Model(&Payment{}).Where("obligation_type = ? AND obligation_id = ? AND status = ?", obligationType, id, PaymentRecorded).Count(&count).Error
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+	err = db.Debug().Model(&PaymentAllocation{}).Joins("JOIN payments ON payments.id = payment_allocations.payment_id").
+		Where("payment_allocations.obligation_type = ? AND payment_allocations.obligation_id = ? AND payments.status = ?", obligationType, id, PaymentRecorded).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+func (zf *ZakatFitrah) LoadPayment(db *gorm.DB) error {
+	paid, err := paidAmounts(db, FundZakatFitrah, []uint{zf.ID})
+	if err != nil {
+		return err
+	}
+	zf.Paid = paid[zf.ID]
+	zf.PaymentStatus = PaymentStatus(zf.TotalPrice, zf.Paid)
+
+	return nil
+}
+
+func LoadZakatFitrahPayments(db *gorm.DB, zfs []ZakatFitrah) error {
+	ids := make([]uint, 0, len(zfs))
+	for _, zf := range zfs {
+		ids = append(ids, zf.ID)
+	}
+	paid, err := paidAmounts(db, FundZakatFitrah, ids)
+	if err != nil {
+		return err
+	}
+	for i := range zfs {
+		zfs[i].Paid = paid[zfs[i].ID]
+		zfs[i].PaymentStatus = PaymentStatus(zfs[i].TotalPrice, zfs[i].Paid)
+	}
+
+	return nil
+}
+
+func LoadZakatMalPayments(db *gorm.DB, zms []ZakatMal) error {
+	ids := make([]uint, 0, len(zms))
+	for _, zm := range zms {
+		ids = append(ids, zm.ID)
+	}
+	paid, err := paidAmounts(db, FundZakatMal, ids)
+	if err != nil {
+		return err
+	}
+	for i := range zms {
+		zms[i].Paid = paid[zms[i].ID]
+		zms[i].PaymentStatus = PaymentStatus(zms[i].TotalZakat, zms[i].Paid)
+	}
+
+	return nil
+}
@@ -0,0 +1,456 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaymentCorrection is a request to void or refund a recorded payment, large amounts wait for a second admin
+type PaymentCorrection struct {
+	gorm.Model
+	PaymentID   uint       `gorm:"not null;index" json:"payment_id"`
+	Action      string     `gorm:"size:20;not null" json:"action"`
+	ReasonCode  string     `gorm:"size:50;not null" json:"reason_code"`
+	Reason      string     `gorm:"size:255" json:"reason"`
+	Amount      int        `gorm:"not null" json:"amount"`
+	Method      string     `gorm:"size:20" json:"method"`
+	Status      string     `gorm:"size:20;not null;default:pending" json:"status"`
+	RequestedBy string     `gorm:"size:255;not null" json:"requested_by"`
+	ApprovedBy  string     `gorm:"size:255" json:"approved_by"`
+	ApprovedAt  *time.Time `json:"approved_at"`
+	AppliedAt   *time.Time `json:"applied_at"`
+}
+
+const (
+	CorrectionVoid   = "void"
+	CorrectionRefund = "refund"
+
+	CorrectionPending  = "pending"
+	CorrectionApplied  = "applied"
+	CorrectionRejected = "rejected"
+
+	// corrections above this amount need a second admin unless CORRECTION_APPROVAL_THRESHOLD says otherwise
+	Default_correction_threshold = 1000000
+)
+
+var CorrectionReasons = map[string]string{
+	"duplicate":        "Pembayaran tercatat ganda",
+	"wrong_amount":     "Jumlah salah",
+	"wrong_muzakki":    "Muzakki salah",
+	"wrong_obligation": "Kewajiban salah",
+	"overpaid":         "Kelebihan bayar",
+	"cancelled":        "Dibatalkan muzakki",
+	"other":            "Lainnya",
+}
+
+var (
+	ErrPaymentClosed      = errors.New("payment is already void or fully refunded")
+	ErrPaymentRefunded    = errors.New("payment with refunds can not be voided")
+	ErrRefundInKind       = errors.New("rice can not be refunded, void the payment instead")
+	ErrRefundAllocated    = errors.New("a payment split over several obligations can not be refunded, void the payment instead")
+	ErrRefundExceeded     = errors.New("refund exceeds what is left of the payment")
+	ErrCorrectionPending  = errors.New("payment already has a pending correction")
+	ErrCorrectionReviewed = errors.New("correction was already reviewed")
+)
+
+// CorrectionThreshold is the largest amount a single admin may void or refund
+func CorrectionThreshold() int {
+	threshold, err := strconv.Atoi(os.Getenv("CORRECTION_APPROVAL_THRESHOLD"))
+	if err != nil || threshold < 0 {
+		return Default_correction_threshold
+	}
+
+	return threshold
+}
+
+func (pc *PaymentCorrection) Prepare(uid string) {
+	pc.Action = strings.TrimSpace(strings.ToLower(pc.Action))
+	pc.ReasonCode = strings.TrimSpace(strings.ToLower(pc.ReasonCode))
+	pc.Reason = html.EscapeString(strings.TrimSpace(pc.Reason))
+	pc.Method = strings.TrimSpace(strings.ToLower(pc.Method))
+	pc.Status = CorrectionPending
+	pc.RequestedBy = uid
+	pc.ApprovedBy = ""
+	pc.ApprovedAt = nil
+	pc.AppliedAt = nil
+}
+
+func (pc *PaymentCorrection) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if pc.Action != CorrectionVoid && pc.Action != CorrectionRefund {
+		err = errors.New("action must be void or refund")
+		errMsg["Invalid_action"] = err.Error()
+	}
+	if _, ok := CorrectionReasons[pc.ReasonCode]; !ok {
+		err = errors.New("reason code must be duplicate, wrong_amount, wrong_muzakki, wrong_obligation, overpaid, cancelled, or other")
+		errMsg["Invalid_reasonCode"] = err.Error()
+	}
+	if pc.ReasonCode == "other" && pc.Reason == "" {
+		err = errors.New("required reason")
+		errMsg["Required_reason"] = err.Error()
+	}
+	if pc.Amount < 0 {
+		err = errors.New("amount can not be negative")
+		errMsg["Invalid_amount"] = err.Error()
+	}
+	if pc.Action == CorrectionRefund && pc.Method != "" && pc.Method != MethodCash && pc.Method != MethodTransfer {
+		err = errors.New("refund method must be cash or transfer")
+		errMsg["Invalid_method"] = err.Error()
+	}
+
+	return errMsg
+}
+
+// describe is the reason printed on the voided receipt
+func (pc *PaymentCorrection) describe() string {
+	if pc.Reason != "" {
+		return CorrectionReasons[pc.ReasonCode] + ": " + pc.Reason
+	}
+
+	return CorrectionReasons[pc.ReasonCode]
+}
+
+// fill completes the amount and method from the payment and checks the payment can still be corrected
+func (pc *PaymentCorrection) fill(p *Payment) error {
+	if p.Status != PaymentRecorded {
+		return ErrPaymentClosed
+	}
+
+	if pc.Action == CorrectionVoid {
+		if p.RefundedAmount > 0 {
+			return ErrPaymentRefunded
+		}
+		pc.Amount = p.Amount
+		pc.Method = p.Method
+		return nil
+	}
+
+	if p.Method == MethodInKind {
+		return ErrRefundInKind
+	}
+	if len(p.Allocations) > 0 {
+		return ErrRefundAllocated
+	}
+	left := p.Amount - p.RefundedAmount
+	if pc.Amount == 0 {
+		pc.Amount = left
+	}
+	if pc.Amount > left {
+		return ErrRefundExceeded
+	}
+	if pc.Method == "" {
+		pc.Method = MethodTransfer
+		if p.Method == MethodCash {
+			pc.Method = MethodCash
+		}
+	}
+
+	return nil
+}
+
+// RequestCorrection records the request and applies it at once when the amount is within the threshold
+func (pc *PaymentCorrection) RequestCorrection(db *gorm.DB) (*PaymentCorrection, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		p := Payment{}
+		err := tx.Debug().Model(&Payment{}).Preload("Allocations").Where("id = ?", pc.PaymentID).Take(&p).Error
+		if err != nil {
+			return err
+		}
+
+		err = pc.fill(&p)
+		if err != nil {
+			return err
+		}
+
+		var pending int64
+		err = tx.Debug().Model(&PaymentCorrection{}).Where("payment_id = ? AND status = ?", p.ID, CorrectionPending).Count(&pending).Error
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrCorrectionPending
+		}
+
+		err = tx.Debug().Create(&pc).Error
+		if err != nil {
+			return err
+		}
+
+		if pc.Amount > CorrectionThreshold() {
+			return nil
+		}
+
+		return pc.apply(tx, "")
+	})
+	if err != nil {
+		return &PaymentCorrection{}, err
+	}
+
+	return pc, nil
+}
+
+func (pc *PaymentCorrection) GetPaymentCorrections(db *gorm.DB, status string) (*[]PaymentCorrection, error) {
+	corrections := []PaymentCorrection{}
+
+	query := db.Debug().Model(&PaymentCorrection{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at desc").Find(&corrections).Error
+	if err != nil {
+		return &[]PaymentCorrection{}, err
+	}
+
+	return &corrections, nil
+}
+
+func (pc *PaymentCorrection) GetPaymentCorrection(db *gorm.DB, id string) (*PaymentCorrection, error) {
+	err := db.Debug().Model(&PaymentCorrection{}).Where("id = ?", id).Take(&pc).Error
+	if err != nil {
+		return &PaymentCorrection{}, err
+	}
+
+	return pc, nil
+}
+
+// Approve applies a pending correction, the approver must be a different admin than the requester
+func (pc *PaymentCorrection) Approve(db *gorm.DB, uid string) (*PaymentCorrection, error) {
+	if pc.Status != CorrectionPending {
+		return &PaymentCorrection{}, errors.New("correction is already " + pc.Status)
+	}
+	if pc.RequestedBy == uid {
+		return &PaymentCorrection{}, errors.New("correction must be approved by another admin")
+	}
+
+	// the row stays locked until the correction is applied, a second approval waits and then finds it reviewed
+	err := db.Transaction(func(tx *gorm.DB) error {
+		current := PaymentCorrection{}
+		err := tx.Debug().Clauses(clause.Locking{Strength: "UPDATE"}).Model(&PaymentCorrection{}).
+			Where("id = ?", pc.ID).Take(&current).Error
+		if err != nil {
+			return err
+		}
+		if current.Status != CorrectionPending {
+			return ErrCorrectionReviewed
+		}
+
+		return pc.apply(tx, uid)
+	})
+	if err != nil {
+		return &PaymentCorrection{}, err
+	}
+
+	return pc, nil
+}
+
+func (pc *PaymentCorrection) Reject(db *gorm.DB, uid string) (*PaymentCorrection, error) {
+	if pc.Status != CorrectionPending {
+		return &PaymentCorrection{}, errors.New("correction is already " + pc.Status)
+	}
+
+	now := time.Now()
+	result := db.Debug().Model(&PaymentCorrection{}).Where("id = ? AND status = ?", pc.ID, CorrectionPending).Updates(PaymentCorrection{
+		Status:     CorrectionRejected,
+		ApprovedBy: uid,
+		ApprovedAt: &now,
+	})
+	if result.Error != nil {
+		return &PaymentCorrection{}, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return &PaymentCorrection{}, ErrCorrectionReviewed
+	}
+
+	pc.Status = CorrectionRejected
+	pc.ApprovedBy = uid
+	pc.ApprovedAt = &now
+
+	return pc, nil
+}
+
+// apply reverses the ledger, the amil share, and the receipt of the payment inside the caller's transaction
+func (pc *PaymentCorrection) apply(tx *gorm.DB, uid string) error {
+	p := Payment{}
+	err := tx.Debug().Clauses(clause.Locking{Strength: "UPDATE"}).Model(&Payment{}).Preload("AmilShares").Preload("Allocations").
+		Where("id = ?", pc.PaymentID).Take(&p).Error
+	if err != nil {
+		return err
+	}
+
+	// the payment may have changed while the correction waited for approval
+	amount, method := pc.Amount, pc.Method
+	err = pc.fill(&p)
+	if err != nil {
+		return err
+	}
+	pc.Amount, pc.Method = amount, method
+
+	now := time.Now()
+	actor := uid
+	if actor == "" {
+		actor = pc.RequestedBy
+	}
+	memo := fmt.Sprintf("%s pembayaran #%d: %s", pc.Action, p.ID, pc.describe())
+
+	status := PaymentRecorded
+	refunded := p.RefundedAmount
+	if pc.Action == CorrectionVoid {
+		status = PaymentVoid
+		err = ReverseJournals(tx, SourcePayment, p.ID, memo, actor)
+		if err != nil {
+			return err
+		}
+		err = ReverseJournals(tx, SourceAmilShare, p.ID, memo, actor)
+		if err != nil {
+			return err
+		}
+		for _, share := range p.AmilShares {
+			err = counterAmilShare(tx, share, share.Base, share.Amount, now)
+			if err != nil {
+				return err
+			}
+		}
+		if p.Method == MethodInKind {
+			err = ReversePaymentReceipt(tx, &p, actor)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		err = pc.postRefund(tx, &p, memo, actor, now)
+		if err != nil {
+			return err
+		}
+		refunded += pc.Amount
+		if refunded == p.Amount {
+			status = PaymentRefunded
+		}
+	}
+
+	err = tx.Debug().Model(&Payment{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
+		"status":          status,
+		"refunded_amount": refunded,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	if status != PaymentRecorded {
+		err = tx.Debug().Model(&Receipt{}).Where("payment_id = ?", p.ID).Updates(map[string]interface{}{
+			"status":      ReceiptVoid,
+			"voided_at":   now,
+			"void_reason": pc.describe(),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	updates := map[string]interface{}{
+		"status":     CorrectionApplied,
+		"applied_at": now,
+		"amount":     pc.Amount,
+		"method":     pc.Method,
+	}
+	if uid != "" {
+		updates["approved_by"] = uid
+		updates["approved_at"] = now
+		pc.ApprovedBy = uid
+		pc.ApprovedAt = &now
+	}
+	result := tx.Debug().Model(&PaymentCorrection{}).Where("id = ? AND status = ?", pc.ID, CorrectionPending).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return ErrCorrectionReviewed
+	}
+	pc.Status = CorrectionApplied
+	pc.AppliedAt = &now
+
+	return nil
+}
+
+// postRefund pays the money back out of the fund it came into and hands back the matching part of the amil share
+func (pc *PaymentCorrection) postRefund(tx *gorm.DB, p *Payment, memo, uid string, at time.Time) error {
+	receipt, fund := AccZakatReceipt, FundZakat
+	if p.ObligationType == FundInfaqSadaqah {
+		receipt, fund = AccInfaqReceipt, FundInfaqSadaqah
+	}
+
+	je := JournalEntry{
+		Date:      at,
+		Source:    SourceRefund,
+		SourceID:  pc.ID,
+		Memo:      memo,
+		CreatedBy: uid,
+		Lines:     transferLines(receipt, cashAccount(pc.Method), fund, pc.Amount),
+	}
+	_, err := je.Post(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, share := range p.AmilShares {
+		if share.Amount <= 0 {
+			continue
+		}
+		amount := share.Amount * pc.Amount / p.Amount
+		if amount == 0 {
+			continue
+		}
+
+		err = counterAmilShare(tx, share, pc.Amount, amount, at)
+		if err != nil {
+			return err
+		}
+
+		debit := AccZakatAmilShare
+		if share.Fund == FundInfaqSadaqah {
+			debit = AccInfaqAmilShare
+		}
+		je := JournalEntry{
+			Date:      at,
+			Source:    SourceRefund,
+			SourceID:  pc.ID,
+			Memo:      memo,
+			CreatedBy: uid,
+			Lines: []JournalLine{
+				{AccountCode: AccAmilReceipt, Fund: FundAmil, Debit: amount},
+				{AccountCode: debit, Fund: share.Fund, Credit: amount},
+			},
+		}
+		_, err = je.Post(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// counterAmilShare books a negative share so the amil report of the correction period nets out
+func counterAmilShare(tx *gorm.DB, share AmilShare, base, amount int, at time.Time) error {
+	counter := AmilShare{
+		PaymentID:         share.PaymentID,
+		AmilShareRuleID:   share.AmilShareRuleID,
+		Fund:              share.Fund,
+		CollectionPointID: share.CollectionPointID,
+		Base:              -base,
+		Percentage:        share.Percentage,
+		Amount:            -amount,
+		BookedAt:          at,
+	}
+
+	return tx.Debug().Create(&counter).Error
+}
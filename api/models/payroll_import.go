@@ -0,0 +1,506 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PayrollImport is a salary deduction batch sent by the UPZ of a company, the company pays it as one transfer
+type PayrollImport struct {
+	gorm.Model
+	CollectionPointID uint                `gorm:"not null;index" json:"collection_point_id"`
+	Company           string              `gorm:"size:255;not null" json:"company"`
+	Period            string              `gorm:"size:7;not null" json:"period"`
+	FileName          string              `gorm:"size:255;not null" json:"file_name"`
+	Method            string              `gorm:"size:20;not null" json:"method"`
+	Reference         string              `gorm:"size:255" json:"reference"`
+	Status            string              `gorm:"size:20;not null;default:draft" json:"status"`
+	Employees         int                 `gorm:"not null;default:0" json:"employees"`
+	Obligated         int                 `gorm:"not null;default:0" json:"obligated"`
+	Invalid           int                 `gorm:"not null;default:0" json:"invalid"`
+	TotalIncome       int                 `gorm:"not null;default:0" json:"total_income"`
+	TotalZakat        int                 `gorm:"not null;default:0" json:"total_zakat"`
+	ImportedBy        string              `gorm:"size:255;not null" json:"imported_by"`
+	PostedBy          string              `gorm:"size:255" json:"posted_by"`
+	PostedAt          *time.Time          `json:"posted_at"`
+	PaymentID         *uint               `json:"payment_id"`
+	Lines             []PayrollImportLine `gorm:"foreignKey:PayrollImportID" json:"lines,omitempty"`
+}
+
+// PayrollImportLine is one employee of the batch, once posted it points at the obligation, the share of the
+// company payment, and the receipt
+type PayrollImportLine struct {
+	gorm.Model
+	PayrollImportID  uint   `gorm:"not null;index" json:"payroll_import_id"`
+	RowNo            int    `gorm:"not null" json:"row_no"`
+	EmployeeNo       string `gorm:"size:50" json:"employee_no"`
+	Name             string `gorm:"size:255" json:"name"`
+	Mobile           string `gorm:"size:255" json:"mobile"`
+	Address          string `gorm:"size:255" json:"address"`
+	Npwp             string `gorm:"size:20" json:"npwp"`
+	Income           int    `gorm:"not null;default:0" json:"income"`
+	Zakat            int    `gorm:"not null;default:0" json:"zakat"`
+	Status           string `gorm:"size:20;not null" json:"status"`
+	Error            string `gorm:"size:255" json:"error"`
+	IdMuzakki        string `gorm:"column:id_muzakki;size:255" json:"id_muzakki"`
+	NewMuzakki       bool   `gorm:"not null;default:false" json:"new_muzakki"`
+	SuggestedMuzakki string `gorm:"size:255" json:"suggested_muzakki"`
+	ZakatMalID       *uint  `json:"zakat_mal_id"`
+	PaymentID        *uint  `json:"payment_id"`
+	AllocationID     *uint  `json:"allocation_id"`
+	ReceiptNumber    string `gorm:"size:100" json:"receipt_number"`
+}
+
+const (
+	PayrollDraft  = "draft"
+	PayrollPosted = "posted"
+
+	PayrollLineObligated    = "obligated"
+	PayrollLineNotObligated = "not_obligated"
+	PayrollLineInvalid      = "invalid"
+)
+
+// payrollColumns maps the accepted header names, in English or Indonesian, to the line field
+var payrollColumns = map[string]string{
+	"employee_no": "employee_no",
+	"employee_id": "employee_no",
+	"nip":         "employee_no",
+	"nik":         "employee_no",
+	"no_pegawai":  "employee_no",
+	"name":        "name",
+	"nama":        "name",
+	"mobile":      "mobile",
+	"phone":       "mobile",
+	"hp":          "mobile",
+	"no_hp":       "mobile",
+	"address":     "address",
+	"alamat":      "address",
+	"npwp":        "npwp",
+	"income":      "income",
+	"salary":      "income",
+	"gaji":        "income",
+	"penghasilan": "income",
+}
+
+var (
+	ErrPayrollPosted    = errors.New("payroll import is already posted")
+	ErrPayrollDuplicate = errors.New("a payroll import for this collection point and period is already posted")
+	ErrPayrollEmpty     = errors.New("payroll import has no employee obligated to pay zakat")
+)
+
+func (pi *PayrollImport) Prepare(uid string) {
+	pi.Company = html.EscapeString(strings.TrimSpace(pi.Company))
+	pi.Period = strings.TrimSpace(pi.Period)
+	pi.FileName = html.EscapeString(strings.TrimSpace(pi.FileName))
+	pi.Method = strings.TrimSpace(strings.ToLower(pi.Method))
+	if pi.Method == "" {
+		pi.Method = MethodTransfer
+	}
+	pi.Reference = html.EscapeString(strings.TrimSpace(pi.Reference))
+	pi.Status = PayrollDraft
+	pi.ImportedBy = uid
+	pi.PostedBy = ""
+	pi.PostedAt = nil
+}
+
+func (pi *PayrollImport) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if pi.CollectionPointID == 0 {
+		err = errors.New("required collection point")
+		errMsg["Required_collectionPoint"] = err.Error()
+	}
+	if _, perr := time.Parse("2006-01", pi.Period); perr != nil {
+		err = errors.New("period must be YYYY-MM")
+		errMsg["Invalid_period"] = err.Error()
+	}
+	if pi.Method != MethodTransfer && pi.Method != MethodCash {
+		err = errors.New("method must be transfer or cash")
+		errMsg["Invalid_method"] = err.Error()
+	}
+
+	return errMsg
+}
+
+// ParsePayroll turns the rows of the uploaded sheet into lines, the first row is the header
+func ParsePayroll(rows [][]string) ([]PayrollImportLine, error) {
+	if len(rows) < 2 {
+		return nil, errors.New("payroll file has no employee rows")
+	}
+
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		name = strings.NewReplacer(" ", "_", "-", "_", ".", "").Replace(name)
+		if field, ok := payrollColumns[name]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+	for _, field := range []string{"employee_no", "name", "income"} {
+		if _, ok := columns[field]; !ok {
+			return nil, errors.New("payroll file needs a " + field + " column")
+		}
+	}
+
+	cell := func(row []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	lines := []PayrollImportLine{}
+	seen := map[string]int{}
+	for i, row := range rows[1:] {
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+
+		line := PayrollImportLine{
+			RowNo:      i + 2,
+			EmployeeNo: html.EscapeString(cell(row, "employee_no")),
+			Name:       html.EscapeString(cell(row, "name")),
+			Mobile:     html.EscapeString(cell(row, "mobile")),
+			Address:    html.EscapeString(cell(row, "address")),
+			Npwp:       NormalizeNpwp(cell(row, "npwp")),
+		}
+		income, err := parseAmount(cell(row, "income"))
+
+		switch {
+		case line.EmployeeNo == "":
+			line.Error = "required employee number"
+		case line.Name == "":
+			line.Error = "required name"
+		case err != nil || income <= 0:
+			line.Error = "income must be a positive amount"
+		case line.Npwp != "" && len(line.Npwp) != 15 && len(line.Npwp) != 16:
+			line.Error = "npwp must be 15 or 16 digits"
+		case seen[line.EmployeeNo] > 0:
+			line.Error = fmt.Sprintf("employee number repeats row %d", seen[line.EmployeeNo])
+		}
+		line.Income = income
+		if line.Error != "" {
+			line.Status = PayrollLineInvalid
+		}
+		if line.EmployeeNo != "" && seen[line.EmployeeNo] == 0 {
+			seen[line.EmployeeNo] = line.RowNo
+		}
+
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("payroll file has no employee rows")
+	}
+
+	return lines, nil
+}
+
+// parseAmount reads "7500000", "7.500.000,00" and "7,500,000.00" alike, a lone separator before three digits groups thousands
+func parseAmount(value string) (int, error) {
+	value = strings.TrimSpace(strings.TrimPrefix(strings.ToLower(value), "rp"))
+	value = strings.ReplaceAll(value, " ", "")
+
+	dot, comma := strings.Count(value, "."), strings.Count(value, ",")
+	switch {
+	case dot > 0 && comma > 0 && strings.LastIndex(value, ",") > strings.LastIndex(value, "."):
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.ReplaceAll(value, ",", ".")
+	case dot > 0 && comma > 0:
+		value = strings.ReplaceAll(value, ",", "")
+	case dot > 1 || (dot == 1 && len(value)-strings.Index(value, ".") == 4):
+		value = strings.ReplaceAll(value, ".", "")
+	case comma > 1 || (comma == 1 && len(value)-strings.Index(value, ",") == 4):
+		value = strings.ReplaceAll(value, ",", "")
+	case comma == 1:
+		value = strings.ReplaceAll(value, ",", ".")
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(math.Round(amount)), nil
+}
+
+// SavePayrollImport computes the zakat profesi of every employee and keeps the batch as a draft to review
+func (pi *PayrollImport) SavePayrollImport(db *gorm.DB, lines []PayrollImportLine) (*PayrollImport, error) {
+	err := activeCollectionPoint(db, &pi.CollectionPointID)
+	if err != nil {
+		return &PayrollImport{}, err
+	}
+
+	cp := CollectionPoint{}
+	err = db.Debug().Model(&CollectionPoint{}).Where("id = ?", pi.CollectionPointID).Take(&cp).Error
+	if err != nil {
+		return &PayrollImport{}, err
+	}
+	if pi.Company == "" {
+		pi.Company = cp.Name
+	}
+
+	pi.Employees, pi.Obligated, pi.Invalid, pi.TotalIncome, pi.TotalZakat = len(lines), 0, 0, 0, 0
+	for i := range lines {
+		line := &lines[i]
+		if line.Status == PayrollLineInvalid {
+			pi.Invalid++
+			continue
+		}
+
+		zm := ZakatMal{TypeZakat: "profesi", TotalAssest: line.Income}
+		pay, err := zm.Calculate(db)
+		if errors.Is(err, ErrNotObligated) {
+			line.Status = PayrollLineNotObligated
+			continue
+		}
+		if err != nil {
+			return &PayrollImport{}, err
+		}
+
+		line.Zakat = int(pay)
+		line.Status = PayrollLineObligated
+		pi.Obligated++
+		pi.TotalIncome += line.Income
+		pi.TotalZakat += line.Zakat
+	}
+
+	// the muzakki is only matched here to show the reviewer, it is matched again when posting
+	for i := range lines {
+		if lines[i].Status == PayrollLineInvalid {
+			continue
+		}
+		m, err := lines[i].matchMuzakki(db, &cp)
+		if err != nil {
+			return &PayrollImport{}, err
+		}
+		lines[i].IdMuzakki = m.MuzakkiId
+		lines[i].NewMuzakki = m.ID == 0
+		if lines[i].NewMuzakki {
+			lines[i].SuggestedMuzakki, err = lines[i].suggestMuzakki(db)
+			if err != nil {
+				return &PayrollImport{}, err
+			}
+		}
+	}
+	pi.Lines = lines
+
+	err = db.Debug().Create(&pi).Error
+	if err != nil {
+		return &PayrollImport{}, err
+	}
+
+	return pi, nil
+}
+
+// matchMuzakki finds the employee by the id of an earlier import or the exact NPWP of an individual muzakki, a company
+// sharing the NPWP is not the employee; when none matches it returns an unsaved muzakki with the id the import gives it
+func (line *PayrollImportLine) matchMuzakki(db *gorm.DB, cp *CollectionPoint) (*Muzakki, error) {
+	id := fmt.Sprintf("upz-%s-%s", strings.ToLower(cp.Code), strings.ToLower(line.EmployeeNo))
+
+	m := Muzakki{}
+	err := db.Debug().Model(&Muzakki{}).Where("muzakki_id = ?", id).Take(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) && line.Npwp != "" {
+		err = db.Debug().Model(&Muzakki{}).Where("npwp = ? AND entity_type = ?", line.Npwp, EntityIndividual).Order("id").First(&m).Error
+	}
+	if err == nil {
+		return &m, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &Muzakki{}, err
+	}
+
+	address := line.Address
+	if address == "" {
+		address = cp.Address
+	}
+	if address == "" {
+		address = cp.Name
+	}
+
+	return &Muzakki{MuzakkiId: id, Name: line.Name, Mobile: line.Mobile, Address: address, Npwp: line.Npwp}, nil
+}
+
+// suggestMuzakki looks for a muzakki with the same mobile number, a shared or recycled number is no proof so it is
+// only shown to the reviewer and never linked
+func (line *PayrollImportLine) suggestMuzakki(db *gorm.DB) (string, error) {
+	if line.Mobile == "" {
+		return "", nil
+	}
+
+	m := Muzakki{}
+	err := db.Debug().Model(&Muzakki{}).Where("mobile = ? AND entity_type = ?", line.Mobile, EntityIndividual).Order("id").First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+
+	return m.MuzakkiId, err
+}
+
+// PostPayrollImport records the company transfer as one payment in one transaction, every obligated employee gets an
+// obligation, a share of the payment, and a receipt
+func (pi *PayrollImport) PostPayrollImport(db *gorm.DB, uid string) (*PayrollImport, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Debug().Clauses(clause.Locking{Strength: "UPDATE"}).Model(&PayrollImport{}).Where("id = ?", pi.ID).Take(&pi).Error
+		if err != nil {
+			return err
+		}
+		if pi.Status != PayrollDraft {
+			return ErrPayrollPosted
+		}
+
+		err = activeCollectionPoint(tx, &pi.CollectionPointID)
+		if err != nil {
+			return err
+		}
+		// the collection point row is held so two drafts of one month posted at once see each other
+		cp := CollectionPoint{}
+		err = tx.Debug().Clauses(clause.Locking{Strength: "UPDATE"}).Model(&CollectionPoint{}).Where("id = ?", pi.CollectionPointID).Take(&cp).Error
+		if err != nil {
+			return err
+		}
+
+		// a second file for the same month would deduct the employees twice
+		var count int64
+		err = tx.Debug().Model(&PayrollImport{}).Where("collection_point_id = ? AND period = ? AND status = ?", pi.CollectionPointID, pi.Period, PayrollPosted).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrPayrollDuplicate
+		}
+
+		lines := []PayrollImportLine{}
+		err = tx.Debug().Model(&PayrollImportLine{}).Where("payroll_import_id = ? AND status = ?", pi.ID, PayrollLineObligated).Order("row_no").Find(&lines).Error
+		if err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			return ErrPayrollEmpty
+		}
+
+		now := time.Now()
+		obligations := make([]ZakatMal, len(lines))
+		created := make([]bool, len(lines))
+		allocations := make([]PaymentAllocation, len(lines))
+		total := 0
+		for i, line := range lines {
+			m, err := line.matchMuzakki(tx, &cp)
+			if err != nil {
+				return err
+			}
+			created[i] = m.ID == 0
+			if created[i] {
+				err = tx.Debug().Create(&m).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			obligations[i] = ZakatMal{
+				IdMuzakki:   m.MuzakkiId,
+				TypeZakat:   "profesi",
+				TotalAssest: line.Income,
+				TotalZakat:  line.Zakat,
+				Period:      pi.Period,
+			}
+			err = tx.Debug().Create(&obligations[i]).Error
+			if err != nil {
+				return err
+			}
+
+			allocations[i] = PaymentAllocation{
+				ObligationType: FundZakatMal,
+				ObligationID:   obligations[i].ID,
+				IdMuzakki:      m.MuzakkiId,
+				Amount:         line.Zakat,
+			}
+			total += line.Zakat
+		}
+
+		// the company makes one transfer, it is recorded as one payment split over the employees
+		p := Payment{
+			ObligationType:    FundZakatMal,
+			IdMuzakki:         fmt.Sprintf("upz-%s", strings.ToLower(cp.Code)),
+			Method:            pi.Method,
+			Amount:            total,
+			Reference:         pi.Reference,
+			Note:              fmt.Sprintf("Potong gaji %s %s", pi.Company, pi.Period),
+			PaidAt:            now,
+			ReceivedBy:        uid,
+			OrganizationID:    cp.OrganizationID,
+			CollectionPointID: &cp.ID,
+			Allocations:       allocations,
+		}
+		payment, err := p.SavePayment(tx)
+		if err != nil {
+			return err
+		}
+
+		for i, line := range lines {
+			a := payment.Allocations[i]
+			err = tx.Debug().Model(&PayrollImportLine{}).Where("id = ?", line.ID).Updates(map[string]interface{}{
+				"id_muzakki":     a.IdMuzakki,
+				"new_muzakki":    created[i],
+				"zakat_mal_id":   obligations[i].ID,
+				"payment_id":     payment.ID,
+				"allocation_id":  a.ID,
+				"receipt_number": a.Receipt.Number,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Debug().Model(&PayrollImport{}).Where("id = ?", pi.ID).Updates(map[string]interface{}{
+			"status":     PayrollPosted,
+			"posted_by":  uid,
+			"posted_at":  now,
+			"payment_id": payment.ID,
+		}).Error
+	})
+	if err != nil {
+		return &PayrollImport{}, err
+	}
+
+	return pi.GetPayrollImport(db, strconv.Itoa(int(pi.ID)))
+}
+
+func (pi *PayrollImport) GetPayrollImports(db *gorm.DB, collectionPoint, period string) (*[]PayrollImport, error) {
+	imports := []PayrollImport{}
+	query := db.Debug().Model(&PayrollImport{})
+	if collectionPoint != "" {
+		query = query.Where("collection_point_id = ?", collectionPoint)
+	}
+	if period != "" {
+		query = query.Where("period = ?", period)
+	}
+	err := query.Order("period desc, id desc").Find(&imports).Error
+	if err != nil {
+		return &[]PayrollImport{}, err
+	}
+
+	return &imports, nil
+}
+
+func (pi *PayrollImport) GetPayrollImport(db *gorm.DB, id string) (*PayrollImport, error) {
+	err := db.Debug().Model(&PayrollImport{}).Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("row_no")
+	}).Where("id = ?", id).Take(&pi).Error
+	if err != nil {
+		return &PayrollImport{}, err
+	}
+
+	return pi, nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"7500000", 7500000},
+		{" 7500000 ", 7500000},
+		{"7.500.000", 7500000},
+		{"7.500.000,00", 7500000},
+		{"7,500,000", 7500000},
+		{"7,500,000.00", 7500000},
+		{"Rp 7.500.000", 7500000},
+		{"7.500", 7500},
+		{"7,500", 7500},
+		{"7500,5", 7501},
+		{"7500.4", 7500},
+		{"7.500,50", 7501},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAmount(tt.value)
+		if err != nil {
+			t.Errorf("parseAmount(%q) error = %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAmount(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "abc", "Rp", "Rp7.500.000,-"} {
+		if got, err := parseAmount(value); err == nil {
+			t.Errorf("parseAmount(%q) = %d, want an error", value, got)
+		}
+	}
+}
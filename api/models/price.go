@@ -9,21 +9,23 @@ import (
 
 type PriceIdr struct {
 	gorm.Model
-	Date string
-	Type string
-	Idr  float64
+	Date   string
+	Type   string
+	Idr    float64
+	Source string
 }
 
 type Nisab struct {
 	GetNisab float64
 	IdrPrice float64
+	Rate     float64
 }
 
 func (idr *PriceIdr) GetIDR(metal string, db *gorm.DB) (*Nisab, error) {
 	var logam string
 
 	metal = strings.ToLower(metal)
-	if metal == "emas" || metal == "dagang" {
+	if metal == "emas" || metal == "dagang" || metal == "profesi" || metal == "mustaghallat" {
 		logam = "XAU"
 	}
 	if metal == "perak" {
@@ -35,22 +37,18 @@ func (idr *PriceIdr) GetIDR(metal string, db *gorm.DB) (*Nisab, error) {
 		return &Nisab{}, err
 	}
 
-	if idr.Type == "XAU" {
-		getNisab := float64(80) * idr.Idr
-		getNisab = math.Ceil(getNisab*100) / 100
-		result := Nisab{
-			GetNisab: getNisab,
-			IdrPrice: idr.Idr,
-		}
-
-		return &result, nil
+	ruling, err := GetRuling(db, metal)
+	if err != nil {
+		return &Nisab{}, err
 	}
-	if idr.Type == "XAG" {
-		getNisab := float64(543) * idr.Idr
+
+	if idr.Type == "XAU" || idr.Type == "XAG" {
+		getNisab := ruling.Nisab * idr.Idr
 		getNisab = math.Ceil(getNisab*100) / 100
 		result := Nisab{
 			GetNisab: getNisab,
 			IdrPrice: idr.Idr,
+			Rate:     ruling.Rate,
 		}
 
 		return &result, nil
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PriceProposal struct {
+	gorm.Model
+	Type       string     `gorm:"size:10;not null" json:"type"`
+	Idr        float64    `gorm:"not null" json:"idr"`
+	Date       string     `gorm:"size:20;not null" json:"date"`
+	Source     string     `gorm:"size:255;not null" json:"source"`
+	Status     string     `gorm:"size:20;not null;default:pending" json:"status"`
+	ProposedBy string     `gorm:"size:255;not null" json:"proposed_by"`
+	ApprovedBy string     `gorm:"size:255" json:"approved_by"`
+	ApprovedAt *time.Time `json:"approved_at"`
+}
+
+const (
+	ProposalPending  = "pending"
+	ProposalApproved = "approved"
+	ProposalRejected = "rejected"
+)
+
+var ErrProposalReviewed = errors.New("proposal was already reviewed")
+
+func MetalSymbol(metal string) string {
+	metal = strings.TrimSpace(strings.ToLower(metal))
+	if metal == "emas" || metal == "xau" {
+		return "XAU"
+	}
+	if metal == "perak" || metal == "xag" {
+		return "XAG"
+	}
+
+	return strings.ToUpper(metal)
+}
+
+func (pp *PriceProposal) Prepare(uid string) {
+	pp.Type = MetalSymbol(pp.Type)
+	pp.Source = html.EscapeString(strings.TrimSpace(pp.Source))
+	pp.Date = strings.TrimSpace(pp.Date)
+	if pp.Date == "" {
+		pp.Date = time.Now().Format("2006-01-02")
+	}
+	pp.Status = ProposalPending
+	pp.ProposedBy = uid
+	pp.ApprovedBy = ""
+	pp.ApprovedAt = nil
+}
+
+func (pp *PriceProposal) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if pp.Type != "XAU" && pp.Type != "XAG" {
+		err = errors.New("type must be emas or perak")
+		errMsg["Invalid_type"] = err.Error()
+	}
+	if pp.Idr <= 0 {
+		err = errors.New("required idr price per gram")
+		errMsg["Required_idr"] = err.Error()
+	}
+	if pp.Source == "" {
+		err = errors.New("required source reference")
+		errMsg["Required_source"] = err.Error()
+	}
+	if _, err = time.Parse("2006-01-02", pp.Date); err != nil {
+		err = errors.New("date must be formatted as yyyy-mm-dd")
+		errMsg["Invalid_date"] = err.Error()
+	}
+
+	return errMsg
+}
+
+func (pp *PriceProposal) SavePriceProposal(db *gorm.DB) (*PriceProposal, error) {
+	err := db.Debug().Create(&pp).Error
+	if err != nil {
+		return &PriceProposal{}, err
+	}
+
+	return pp, nil
+}
+
+func (pp *PriceProposal) GetPriceProposals(db *gorm.DB, status string) (*[]PriceProposal, error) {
+	proposals := []PriceProposal{}
+
+	query := db.Debug().Model(&PriceProposal{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at desc").Find(&proposals).Error
+	if err != nil {
+		return &[]PriceProposal{}, err
+	}
+
+	return &proposals, nil
+}
+
+func (pp *PriceProposal) GetPriceProposal(db *gorm.DB, id string) (*PriceProposal, error) {
+	err := db.Debug().Model(&PriceProposal{}).Where("id = ?", id).Take(&pp).Error
+	if err != nil {
+		return &PriceProposal{}, err
+	}
+
+	return pp, nil
+}
+
+// Approve activates the proposed price, the approver must be a different admin than the proposer
+func (pp *PriceProposal) Approve(db *gorm.DB, uid string) (*PriceProposal, error) {
+	if pp.Status != ProposalPending {
+		return &PriceProposal{}, errors.New("proposal is already " + pp.Status)
+	}
+	if pp.ProposedBy == uid {
+		return &PriceProposal{}, errors.New("proposal must be approved by another admin")
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// only one reviewer can take the proposal out of pending, a second approval must not upsert the price again
+		result := tx.Debug().Model(&PriceProposal{}).Where("id = ? AND status = ?", pp.ID, ProposalPending).Updates(PriceProposal{
+			Status:     ProposalApproved,
+			ApprovedBy: uid,
+			ApprovedAt: &now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrProposalReviewed
+		}
+
+		price := PriceIdr{
+			Date:   pp.Date,
+			Type:   pp.Type,
+			Idr:    pp.Idr,
+			Source: pp.Source,
+		}
+		res := tx.Debug().Model(&PriceIdr{}).Where("type = ?", pp.Type).Updates(price)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return tx.Debug().Create(&price).Error
+		}
+
+		return nil
+	})
+	if err != nil {
+		return &PriceProposal{}, err
+	}
+
+	pp.Status = ProposalApproved
+	pp.ApprovedBy = uid
+	pp.ApprovedAt = &now
+
+	return pp, nil
+}
+
+func (pp *PriceProposal) Reject(db *gorm.DB, uid string) (*PriceProposal, error) {
+	if pp.Status != ProposalPending {
+		return &PriceProposal{}, errors.New("proposal is already " + pp.Status)
+	}
+
+	now := time.Now()
+	result := db.Debug().Model(&PriceProposal{}).Where("id = ? AND status = ?", pp.ID, ProposalPending).Updates(PriceProposal{
+		Status:     ProposalRejected,
+		ApprovedBy: uid,
+		ApprovedAt: &now,
+	})
+	if result.Error != nil {
+		return &PriceProposal{}, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return &PriceProposal{}, ErrProposalReviewed
+	}
+
+	pp.Status = ProposalRejected
+	pp.ApprovedBy = uid
+	pp.ApprovedAt = &now
+
+	return pp, nil
+}
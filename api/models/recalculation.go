@@ -0,0 +1,236 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Recalculation struct {
+	gorm.Model
+	Reason      string              `gorm:"size:255;not null" json:"reason"`
+	TypeZakat   string              `gorm:"size:50" json:"type_zakat"`
+	MuzakkiIds  []string            `gorm:"-" json:"muzakki_ids"`
+	Status      string              `gorm:"size:20;not null;default:draft" json:"status"`
+	Evaluated   int                 `gorm:"not null;default:0" json:"evaluated"`
+	CreatedBy   string              `gorm:"size:255;not null" json:"created_by"`
+	ConfirmedBy string              `gorm:"size:255" json:"confirmed_by"`
+	ConfirmedAt *time.Time          `json:"confirmed_at"`
+	Items       []RecalculationItem `gorm:"foreignKey:RecalculationID" json:"items"`
+}
+
+type RecalculationItem struct {
+	gorm.Model
+	RecalculationID uint   `gorm:"not null" json:"recalculation_id"`
+	ZakatMalID      uint   `gorm:"not null" json:"zakat_mal_id"`
+	IdMuzakki       string `gorm:"column:id_muzakki;not null" json:"id_muzakki"`
+	TypeZakat       string `gorm:"size:50;not null" json:"type_zakat"`
+	OldZakat        int    `gorm:"not null" json:"old_zakat"`
+	NewZakat        int    `gorm:"not null" json:"new_zakat"`
+	Difference      int    `gorm:"not null" json:"difference"`
+}
+
+type ZakatMalHistory struct {
+	gorm.Model
+	ZakatMalID      uint   `gorm:"not null" json:"zakat_mal_id"`
+	IdMuzakki       string `gorm:"column:id_muzakki;not null" json:"id_muzakki"`
+	OldZakat        int    `gorm:"not null" json:"old_zakat"`
+	NewZakat        int    `gorm:"not null" json:"new_zakat"`
+	Reason          string `gorm:"size:255;not null" json:"reason"`
+	ChangedBy       string `gorm:"size:255;not null" json:"changed_by"`
+	RecalculationID *uint  `json:"recalculation_id"`
+}
+
+const (
+	RecalculationDraft     = "draft"
+	RecalculationApplied   = "applied"
+	RecalculationCancelled = "cancelled"
+)
+
+func (r *Recalculation) Prepare(uid string) {
+	r.Reason = html.EscapeString(strings.TrimSpace(r.Reason))
+	r.TypeZakat = html.EscapeString(strings.TrimSpace(strings.ToLower(r.TypeZakat)))
+	r.Status = RecalculationDraft
+	r.CreatedBy = uid
+	r.ConfirmedBy = ""
+	r.ConfirmedAt = nil
+	r.Items = []RecalculationItem{}
+}
+
+func (r *Recalculation) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if r.Reason == "" {
+		err = errors.New("required reason")
+		errMsg["Required_reason"] = err.Error()
+	}
+	if _, ok := DefaultRulings[r.TypeZakat]; r.TypeZakat != "" && !ok {
+		err = errors.New("type zakat must be emas, perak, dagang, pertanian, profesi, or mustaghallat")
+		errMsg["Invalid_type"] = err.Error()
+	}
+
+	return errMsg
+}
+
+// OpenZakatMals returns the obligations that may still be recalculated, paid ones are left alone
+func OpenZakatMals(db *gorm.DB, typeZakat string, muzakkiIds []string) (*[]ZakatMal, error) {
+	zakatMal := []ZakatMal{}
+
+	paid := db.Model(&Payment{}).Select("obligation_id").Where("obligation_type = ? AND status = ?", FundZakatMal, PaymentRecorded)
+	// obligations of a recurring commitment follow the commitment, not the price
+	query := db.Debug().Model(&ZakatMal{}).Where("id NOT IN (?) AND id NOT IN (?) AND commitment_id IS NULL", paid, allocatedObligations(db, FundZakatMal))
+	if typeZakat != "" {
+		query = query.Where("type_zakat = ?", typeZakat)
+	}
+	if len(muzakkiIds) > 0 {
+		query = query.Where("id_muzakki IN ?", muzakkiIds)
+	}
+	err := query.Find(&zakatMal).Error
+	if err != nil {
+		return &[]ZakatMal{}, err
+	}
+
+	return &zakatMal, nil
+}
+
+// Evaluate builds the diff report against the current price and ruling without touching the obligations
+func (r *Recalculation) Evaluate(db *gorm.DB) (*Recalculation, error) {
+	zakatMal, err := OpenZakatMals(db, r.TypeZakat, r.MuzakkiIds)
+	if err != nil {
+		return &Recalculation{}, err
+	}
+
+	items := []RecalculationItem{}
+	for _, zm := range *zakatMal {
+		pay, err := zm.Calculate(db)
+		if err != nil && !errors.Is(err, ErrNotObligated) {
+			return &Recalculation{}, err
+		}
+
+		newZakat := int(pay)
+		if newZakat == zm.TotalZakat {
+			continue
+		}
+		items = append(items, RecalculationItem{
+			ZakatMalID: zm.ID,
+			IdMuzakki:  zm.IdMuzakki,
+			TypeZakat:  zm.TypeZakat,
+			OldZakat:   zm.TotalZakat,
+			NewZakat:   newZakat,
+			Difference: newZakat - zm.TotalZakat,
+		})
+	}
+
+	r.Evaluated = len(*zakatMal)
+	r.Items = items
+	err = db.Debug().Create(&r).Error
+	if err != nil {
+		return &Recalculation{}, err
+	}
+
+	return r, nil
+}
+
+func (r *Recalculation) GetRecalculation(db *gorm.DB, id string) (*Recalculation, error) {
+	err := db.Debug().Preload("Items").Where("id = ?", id).Take(&r).Error
+	if err != nil {
+		return &Recalculation{}, err
+	}
+
+	return r, nil
+}
+
+func (r *Recalculation) GetRecalculations(db *gorm.DB) (*[]Recalculation, error) {
+	recalculations := []Recalculation{}
+	err := db.Debug().Model(&Recalculation{}).Order("created_at desc").Find(&recalculations).Error
+	if err != nil {
+		return &[]Recalculation{}, err
+	}
+
+	return &recalculations, nil
+}
+
+// Apply writes the new amounts and a history row per obligation, obligations changed since the report abort the job
+func (r *Recalculation) Apply(db *gorm.DB, uid string) (*Recalculation, error) {
+	if r.Status != RecalculationDraft {
+		return &Recalculation{}, errors.New("recalculation is already " + r.Status)
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range r.Items {
+			res := tx.Debug().Model(&ZakatMal{}).Where("id = ? AND total_zakat = ?", item.ZakatMalID, item.OldZakat).Update("total_zakat", item.NewZakat)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errors.New("zakat mal has changed since the report, create a new recalculation")
+			}
+
+			history := ZakatMalHistory{
+				ZakatMalID:      item.ZakatMalID,
+				IdMuzakki:       item.IdMuzakki,
+				OldZakat:        item.OldZakat,
+				NewZakat:        item.NewZakat,
+				Reason:          r.Reason,
+				ChangedBy:       uid,
+				RecalculationID: &r.ID,
+			}
+			err := tx.Debug().Create(&history).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Debug().Model(&Recalculation{}).Where("id = ?", r.ID).Updates(Recalculation{
+			Status:      RecalculationApplied,
+			ConfirmedBy: uid,
+			ConfirmedAt: &now,
+		}).Error
+	})
+	if err != nil {
+		return &Recalculation{}, err
+	}
+
+	r.Status = RecalculationApplied
+	r.ConfirmedBy = uid
+	r.ConfirmedAt = &now
+
+	return r, nil
+}
+
+func (r *Recalculation) Cancel(db *gorm.DB, uid string) (*Recalculation, error) {
+	if r.Status != RecalculationDraft {
+		return &Recalculation{}, errors.New("recalculation is already " + r.Status)
+	}
+
+	now := time.Now()
+	err := db.Debug().Model(&Recalculation{}).Where("id = ?", r.ID).Updates(Recalculation{
+		Status:      RecalculationCancelled,
+		ConfirmedBy: uid,
+		ConfirmedAt: &now,
+	}).Error
+	if err != nil {
+		return &Recalculation{}, err
+	}
+
+	r.Status = RecalculationCancelled
+	r.ConfirmedBy = uid
+	r.ConfirmedAt = &now
+
+	return r, nil
+}
+
+func (h *ZakatMalHistory) GetZakatMalHistory(db *gorm.DB, zmID string) (*[]ZakatMalHistory, error) {
+	histories := []ZakatMalHistory{}
+	err := db.Debug().Model(&ZakatMalHistory{}).Where("zakat_mal_id = ?", zmID).Order("created_at").Find(&histories).Error
+	if err != nil {
+		return &[]ZakatMalHistory{}, err
+	}
+
+	return &histories, nil
+}
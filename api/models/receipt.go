@@ -0,0 +1,182 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"zakat/api/utils/terbilang"
+
+	"gorm.io/gorm"
+)
+
+// Receipt is the bukti setor of one payment, or of each part of a split payment, it keeps a copy of every printed detail so re-downloads match
+type Receipt struct {
+	gorm.Model
+	OrganizationID uint       `gorm:"not null" json:"organization_id"`
+	OrgName        string     `gorm:"size:255;not null" json:"org_name"`
+	OrgAddress     string     `gorm:"size:255" json:"org_address"`
+	OrgContact     string     `gorm:"size:255" json:"org_contact"`
+	PaymentID      uint       `gorm:"not null;index" json:"payment_id"`
+	AllocationID   *uint      `gorm:"uniqueIndex" json:"allocation_id"`
+	Number         string     `gorm:"size:100;not null;unique" json:"number"`
+	Year           int        `gorm:"not null" json:"year"`
+	Sequence       int        `gorm:"not null" json:"sequence"`
+	IdMuzakki      string     `gorm:"column:id_muzakki;size:255;not null" json:"id_muzakki"`
+	MuzakkiName    string     `gorm:"size:255" json:"muzakki_name"`
+	MuzakkiAddress string     `gorm:"size:255" json:"muzakki_address"`
+	MuzakkiMobile  string     `gorm:"size:255" json:"muzakki_mobile"`
+	ZakatType      string     `gorm:"size:255;not null" json:"zakat_type"`
+	Method         string     `gorm:"size:20;not null" json:"method"`
+	Amount         int        `gorm:"not null" json:"amount"`
+	AmountWords    string     `gorm:"size:512;not null" json:"amount_words"`
+	AmilID         string     `gorm:"size:255;not null" json:"amil_id"`
+	AmilName       string     `gorm:"size:255;not null" json:"amil_name"`
+	IssuedAt       time.Time  `gorm:"not null" json:"issued_at"`
+	Status         string     `gorm:"size:20;not null;default:issued" json:"status"`
+	Signature      string     `gorm:"size:100" json:"signature"`
+	VoidedAt       *time.Time `json:"voided_at"`
+	VoidReason     string     `gorm:"size:255" json:"void_reason"`
+}
+
+const (
+	ReceiptIssued = "issued"
+	ReceiptVoid   = "void"
+)
+
+// zakatLabel describes the obligation the way it is printed on the receipt
+func zakatLabel(db *gorm.DB, p *Payment) (string, error) {
+	if p.ObligationType == FundInfaqSadaqah {
+		return "Infaq/Sedekah", nil
+	}
+	if p.ObligationType == FundZakatFitrah {
+		zf := ZakatFitrah{}
+		err := db.Debug().Model(&ZakatFitrah{}).Where("id = ?", p.ObligationID).Take(&zf).Error
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Zakat Fitrah (%d jiwa)", zf.TotalPerson), nil
+	}
+
+	zm := ZakatMal{}
+	err := db.Debug().Model(&ZakatMal{}).Where("id = ?", p.ObligationID).Take(&zm).Error
+	if err != nil {
+		return "", err
+	}
+
+	return "Zakat Mal (" + zm.TypeZakat + ")", nil
+}
+
+// IssueReceipt numbers and stores the receipt of a payment inside the payment transaction
+func IssueReceipt(tx *gorm.DB, p *Payment) (*Receipt, error) {
+	return issueReceipt(tx, p, nil)
+}
+
+// IssueAllocationReceipt gives the muzakki of one allocation a receipt for their part of a split payment
+func IssueAllocationReceipt(tx *gorm.DB, p *Payment, a *PaymentAllocation) (*Receipt, error) {
+	part := *p
+	part.ObligationType = a.ObligationType
+	part.ObligationID = a.ObligationID
+	part.IdMuzakki = a.IdMuzakki
+	part.Amount = a.Amount
+
+	return issueReceipt(tx, &part, &a.ID)
+}
+
+func issueReceipt(tx *gorm.DB, p *Payment, allocationID *uint) (*Receipt, error) {
+	org, err := GetReceiptOrganization(tx, p.OrganizationID)
+	if err != nil {
+		return &Receipt{}, err
+	}
+
+	label, err := zakatLabel(tx, p)
+	if err != nil {
+		return &Receipt{}, err
+	}
+
+	muzakki := Muzakki{}
+	tx.Debug().Model(&Muzakki{}).Where("muzakki_id = ?", p.IdMuzakki).Take(&muzakki)
+
+	amil := User{}
+	amilName := ""
+	if err := tx.Debug().Model(&User{}).Where("user_id = ?", p.ReceivedBy).Take(&amil).Error; err == nil {
+		amilName = amil.Username
+	} else {
+		amilName = "Pembayaran online (" + p.ReceivedBy + ")"
+	}
+
+	year := p.PaidAt.Year()
+	seq, number, err := NextReceiptNumber(tx, org, year)
+	if err != nil {
+		return &Receipt{}, err
+	}
+
+	contact := []string{}
+	for _, value := range []string{org.Phone, org.Email} {
+		if value != "" {
+			contact = append(contact, value)
+		}
+	}
+
+	r := Receipt{
+		OrganizationID: org.ID,
+		OrgName:        org.Name,
+		OrgAddress:     org.Address,
+		OrgContact:     strings.Join(contact, " | "),
+		PaymentID:      p.ID,
+		AllocationID:   allocationID,
+		Number:         number,
+		Year:           year,
+		Sequence:       seq,
+		IdMuzakki:      p.IdMuzakki,
+		MuzakkiName:    muzakki.DisplayName(),
+		MuzakkiAddress: muzakki.Address,
+		MuzakkiMobile:  muzakki.Mobile,
+		ZakatType:      label,
+		Method:         p.Method,
+		Amount:         p.Amount,
+		AmountWords:    terbilang.Rupiah(p.Amount),
+		AmilID:         p.ReceivedBy,
+		AmilName:       amilName,
+		IssuedAt:       p.PaidAt,
+		Status:         ReceiptIssued,
+	}
+	err = org.EnsureSigningKey(tx)
+	if err != nil {
+		return &Receipt{}, err
+	}
+	err = r.sign(org)
+	if err != nil {
+		return &Receipt{}, err
+	}
+
+	err = tx.Debug().Create(&r).Error
+	if err != nil {
+		return &Receipt{}, err
+	}
+
+	return &r, nil
+}
+
+func (r *Receipt) GetReceipts(db *gorm.DB, muzakki string) (*[]Receipt, error) {
+	receipts := []Receipt{}
+
+	query := db.Debug().Model(&Receipt{})
+	if muzakki != "" {
+		query = query.Where("id_muzakki = ?", muzakki)
+	}
+	err := query.Order("issued_at DESC").Find(&receipts).Error
+	if err != nil {
+		return &[]Receipt{}, err
+	}
+
+	return &receipts, nil
+}
+
+func (r *Receipt) GetReceipt(db *gorm.DB, id string) (*Receipt, error) {
+	err := db.Debug().Model(&Receipt{}).Where("id = ?", id).Take(&r).Error
+	if err != nil {
+		return &Receipt{}, err
+	}
+
+	return r, nil
+}
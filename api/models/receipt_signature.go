@@ -0,0 +1,204 @@
+package models
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReceiptVerification is what the public verification endpoint may reveal about a receipt
+type ReceiptVerification struct {
+	Valid        bool      `json:"valid"`
+	Reason       string    `json:"reason,omitempty"`
+	Number       string    `json:"number"`
+	Organization string    `json:"organization,omitempty"`
+	MuzakkiName  string    `json:"muzakki_name,omitempty"`
+	ZakatType    string    `json:"zakat_type,omitempty"`
+	Amount       int       `json:"amount,omitempty"`
+	IssuedAt     time.Time `json:"issued_at,omitempty"`
+	Status       string    `json:"status,omitempty"`
+}
+
+const Default_verify_url = "/api/verify/receipts"
+
+var ErrNoSigningKey = errors.New("organization has no signing key")
+
+// GenerateSigningKey gives the organization a fresh ed25519 key pair, only the seed is stored
+func (o *Organization) GenerateSigningKey() error {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	o.PublicKey = base64.StdEncoding.EncodeToString(public)
+	o.SigningSeed = base64.StdEncoding.EncodeToString(private.Seed())
+
+	return nil
+}
+
+// EnsureSigningKey creates and stores a key pair for organizations made before receipts were signed
+func (o *Organization) EnsureSigningKey(db *gorm.DB) error {
+	if o.SigningSeed != "" {
+		return nil
+	}
+	err := o.GenerateSigningKey()
+	if err != nil {
+		return err
+	}
+
+	// a concurrent first signing may have stored its key already, that key wins so every receipt verifies
+	result := db.Debug().Model(&Organization{}).Where("id = ? AND (signing_seed = '' OR signing_seed IS NULL)", o.ID).Updates(map[string]interface{}{
+		"public_key":   o.PublicKey,
+		"signing_seed": o.SigningSeed,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return db.Debug().Model(&Organization{}).Select("public_key", "signing_seed").Where("id = ?", o.ID).Take(&o).Error
+	}
+
+	return nil
+}
+
+func (o *Organization) privateKey() (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(o.SigningSeed)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, ErrNoSigningKey
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func (o *Organization) publicKey() (ed25519.PublicKey, error) {
+	public, err := base64.StdEncoding.DecodeString(o.PublicKey)
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return nil, ErrNoSigningKey
+	}
+
+	return ed25519.PublicKey(public), nil
+}
+
+// CanonicalContent is the exact byte string that gets signed, changing it invalidates every issued receipt
+func (r *Receipt) CanonicalContent() []byte {
+	return []byte(strings.Join([]string{
+		"BSZ1",
+		r.Number,
+		fmt.Sprint(r.OrganizationID),
+		r.IdMuzakki,
+		r.ZakatType,
+		fmt.Sprint(r.Amount),
+		r.IssuedAt.UTC().Format(time.RFC3339),
+	}, "|"))
+}
+
+func (r *Receipt) sign(org *Organization) error {
+	private, err := org.privateKey()
+	if err != nil {
+		return err
+	}
+	// the database keeps microseconds only, sign what will be read back
+	r.IssuedAt = r.IssuedAt.Truncate(time.Second)
+	r.Signature = base64.RawURLEncoding.EncodeToString(ed25519.Sign(private, r.CanonicalContent()))
+
+	return nil
+}
+
+// EnsureReceiptSignature signs receipts issued before signing existed
+func EnsureReceiptSignature(db *gorm.DB, r *Receipt) error {
+	if r.Signature != "" {
+		return nil
+	}
+
+	org := Organization{}
+	err := db.Debug().Model(&Organization{}).Where("id = ?", r.OrganizationID).Take(&org).Error
+	if err != nil {
+		return err
+	}
+	err = org.EnsureSigningKey(db)
+	if err != nil {
+		return err
+	}
+	err = r.sign(&org)
+	if err != nil {
+		return err
+	}
+
+	return db.Debug().Model(&Receipt{}).Where("id = ?", r.ID).Updates(map[string]interface{}{
+		"issued_at": r.IssuedAt,
+		"signature": r.Signature,
+	}).Error
+}
+
+// QrPayload is the verification link encoded in the receipt QR code
+func (r *Receipt) QrPayload() string {
+	base := os.Getenv("RECEIPT_VERIFY_URL")
+	if base == "" {
+		base = Default_verify_url
+	}
+
+	return base + "?number=" + url.QueryEscape(r.Number) + "&sig=" + r.Signature
+}
+
+// VerifyReceipt checks the signature against the stored receipt and the organization public key
+func VerifyReceipt(db *gorm.DB, number, signature string) (*ReceiptVerification, error) {
+	rv := ReceiptVerification{Number: number}
+
+	r := Receipt{}
+	err := db.Debug().Model(&Receipt{}).Where("number = ?", number).Take(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		rv.Reason = "receipt not found"
+		return &rv, nil
+	}
+	if err != nil {
+		return &rv, err
+	}
+
+	org := Organization{}
+	err = db.Debug().Model(&Organization{}).Where("id = ?", r.OrganizationID).Take(&org).Error
+	if err != nil {
+		return &rv, err
+	}
+
+	public, err := org.publicKey()
+	if err != nil {
+		rv.Reason = "organization has no signing key"
+		return &rv, nil
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || signature != r.Signature || !ed25519.Verify(public, r.CanonicalContent(), sig) {
+		rv.Reason = "signature does not match"
+		return &rv, nil
+	}
+
+	rv.Organization = org.Name
+	rv.MuzakkiName = maskName(r.MuzakkiName)
+	rv.ZakatType = r.ZakatType
+	rv.Amount = r.Amount
+	rv.IssuedAt = r.IssuedAt
+	rv.Status = r.Status
+	rv.Valid = r.Status == ReceiptIssued
+	if !rv.Valid {
+		rv.Reason = "receipt is " + r.Status
+	}
+
+	return &rv, nil
+}
+
+// maskName keeps the first letter of each word, e.g. "Ahmad Fauzi" becomes "A**** F****"
+func maskName(name string) string {
+	parts := strings.Fields(name)
+	for i, part := range parts {
+		runes := []rune(part)
+		parts[i] = string(runes[0]) + strings.Repeat("*", len(runes)-1)
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type NisabRuling struct {
+	gorm.Model
+	TypeZakat string    `gorm:"size:50;not null" json:"type_zakat"`
+	Nisab     float64   `gorm:"not null" json:"nisab"`
+	Rate      float64   `gorm:"not null" json:"rate"`
+	ValidFrom time.Time `gorm:"not null" json:"valid_from"`
+	Reference string    `gorm:"size:255" json:"reference"`
+	CreatedBy string    `gorm:"size:255" json:"created_by"`
+}
+
+// nisab is in grams of gold or silver, except pertanian which is in kg of harvest, profesi and mustaghallat are a yearly nisab in grams of gold
+var DefaultRulings = map[string]NisabRuling{
+	"emas":         {TypeZakat: "emas", Nisab: 80, Rate: Zakat_rate},
+	"perak":        {TypeZakat: "perak", Nisab: 543, Rate: Zakat_rate},
+	"dagang":       {TypeZakat: "dagang", Nisab: 80, Rate: Zakat_rate},
+	"pertanian":    {TypeZakat: "pertanian", Nisab: Pertanian_nisab, Rate: Pertanian_rate},
+	"profesi":      {TypeZakat: "profesi", Nisab: Profesi_nisab, Rate: Zakat_rate},
+	"mustaghallat": {TypeZakat: "mustaghallat", Nisab: Mustaghallat_nisab, Rate: Zakat_rate},
+}
+
+func (nr *NisabRuling) Prepare(uid string) {
+	nr.TypeZakat = html.EscapeString(strings.TrimSpace(strings.ToLower(nr.TypeZakat)))
+	nr.Reference = html.EscapeString(strings.TrimSpace(nr.Reference))
+	nr.CreatedBy = uid
+	if nr.ValidFrom.IsZero() {
+		nr.ValidFrom = time.Now()
+	}
+}
+
+func (nr *NisabRuling) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if _, ok := DefaultRulings[nr.TypeZakat]; !ok {
+		err = errors.New("type zakat must be emas, perak, dagang, pertanian, profesi, or mustaghallat")
+		errMsg["Invalid_type"] = err.Error()
+	}
+	if nr.Nisab <= 0 {
+		err = errors.New("required nisab")
+		errMsg["Required_nisab"] = err.Error()
+	}
+	if nr.Rate <= 0 || nr.Rate > 100 {
+		err = errors.New("rate must be between 0 and 100 percent")
+		errMsg["Invalid_rate"] = err.Error()
+	}
+	if nr.Reference == "" {
+		err = errors.New("required ruling reference")
+		errMsg["Required_reference"] = err.Error()
+	}
+
+	return errMsg
+}
+
+func (nr *NisabRuling) SaveRuling(db *gorm.DB) (*NisabRuling, error) {
+	err := db.Debug().Create(&nr).Error
+	if err != nil {
+		return &NisabRuling{}, err
+	}
+
+	return nr, nil
+}
+
+func (nr *NisabRuling) GetRulings(db *gorm.DB) (*[]NisabRuling, error) {
+	rulings := []NisabRuling{}
+	err := db.Debug().Model(&NisabRuling{}).Order("type_zakat, valid_from desc").Find(&rulings).Error
+	if err != nil {
+		return &[]NisabRuling{}, err
+	}
+
+	return &rulings, nil
+}
+
+// GetRuling returns the latest ruling in effect, or the default one when none was recorded
+func GetRuling(db *gorm.DB, typeZakat string) (*NisabRuling, error) {
+	typeZakat = strings.TrimSpace(strings.ToLower(typeZakat))
+
+	nr := NisabRuling{}
+	err := db.Debug().Model(&NisabRuling{}).Where("type_zakat = ? AND valid_from <= ?", typeZakat, time.Now()).Order("valid_from desc").First(&nr).Error
+	if err == nil {
+		return &nr, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &NisabRuling{}, err
+	}
+
+	def, ok := DefaultRulings[typeZakat]
+	if !ok {
+		return &NisabRuling{}, errors.New("no ruling for zakat " + typeZakat)
+	}
+
+	return &def, nil
+}
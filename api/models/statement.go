@@ -0,0 +1,215 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type StatementLine struct {
+	Code   string `json:"code"`
+	Name   string `json:"name"`
+	Amount int    `json:"amount"`
+}
+
+// FinancialPosition is the laporan posisi keuangan at the end of a period
+type FinancialPosition struct {
+	AsOf             time.Time       `json:"as_of"`
+	Assets           []StatementLine `json:"assets"`
+	TotalAssets      int             `json:"total_assets"`
+	Liabilities      []StatementLine `json:"liabilities"`
+	TotalLiabilities int             `json:"total_liabilities"`
+	Funds            []StatementLine `json:"funds"`
+	TotalFunds       int             `json:"total_funds"`
+	Balanced         bool            `json:"balanced"`
+}
+
+type FundChange struct {
+	Fund               string          `json:"fund"`
+	Opening            int             `json:"opening"`
+	Receipts           []StatementLine `json:"receipts"`
+	TotalReceipts      int             `json:"total_receipts"`
+	Distributions      []StatementLine `json:"distributions"`
+	TotalDistributions int             `json:"total_distributions"`
+	Surplus            int             `json:"surplus"`
+	Closing            int             `json:"closing"`
+}
+
+// FundChanges is the laporan perubahan dana for each of the four funds
+type FundChanges struct {
+	PeriodStart time.Time    `json:"period_start"`
+	PeriodEnd   time.Time    `json:"period_end"`
+	Funds       []FundChange `json:"funds"`
+}
+
+type ManagedAssetChange struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Opening     int    `json:"opening"`
+	Additions   int    `json:"additions"`
+	Collections int    `json:"collections"`
+	WrittenOff  int    `json:"written_off"`
+	Closing     int    `json:"closing"`
+}
+
+// ManagedAssetChanges is the laporan perubahan aset kelolaan, assets handed to mustahik that amil still manages
+type ManagedAssetChanges struct {
+	PeriodStart time.Time            `json:"period_start"`
+	PeriodEnd   time.Time            `json:"period_end"`
+	Assets      []ManagedAssetChange `json:"assets"`
+	Total       ManagedAssetChange   `json:"total"`
+}
+
+var LedgerFunds = []string{FundZakat, FundInfaqSadaqah, FundAmil, FundNonHalal}
+
+var FundNames = map[string]string{
+	FundZakat:        "Dana zakat",
+	FundInfaqSadaqah: "Dana infaq/sedekah",
+	FundAmil:         "Dana amil",
+	FundNonHalal:     "Dana non-halal",
+}
+
+// managed asset accounts, receivables from productive zakat and qardhul hasan
+var managedAssetAccounts = []string{AccQardhReceivable}
+
+// fundBalance nets the fund, receipt and distribution accounts of one fund
+func fundBalance(balances []AccountBalance, opening bool) int {
+	total := 0
+	for _, ab := range balances {
+		amount := ab.Closing
+		if opening {
+			amount = ab.Opening
+		}
+		switch ab.Type {
+		case AccountFund, AccountRevenue:
+			total += amount
+		case AccountExpense:
+			total -= amount
+		}
+	}
+
+	return total
+}
+
+func GetFinancialPosition(db *gorm.DB, start, end time.Time) (*FinancialPosition, error) {
+	balances, err := GetAccountBalances(db, start, end, "")
+	if err != nil {
+		return &FinancialPosition{}, err
+	}
+
+	fp := FinancialPosition{AsOf: end, Assets: []StatementLine{}, Liabilities: []StatementLine{}, Funds: []StatementLine{}}
+	for _, ab := range balances {
+		if ab.Type != AccountAsset {
+			continue
+		}
+		fp.Assets = append(fp.Assets, StatementLine{Code: ab.Code, Name: ab.Name, Amount: ab.Closing})
+		fp.TotalAssets += ab.Closing
+	}
+
+	for _, fund := range LedgerFunds {
+		balances, err := GetAccountBalances(db, start, end, fund)
+		if err != nil {
+			return &FinancialPosition{}, err
+		}
+		amount := fundBalance(balances, false)
+		fp.Funds = append(fp.Funds, StatementLine{Code: fund, Name: FundNames[fund], Amount: amount})
+		fp.TotalFunds += amount
+	}
+	fp.Balanced = fp.TotalAssets == fp.TotalLiabilities+fp.TotalFunds
+
+	return &fp, nil
+}
+
+func GetFundChanges(db *gorm.DB, start, end time.Time) (*FundChanges, error) {
+	fc := FundChanges{PeriodStart: start, PeriodEnd: end, Funds: []FundChange{}}
+
+	for _, fund := range LedgerFunds {
+		balances, err := GetAccountBalances(db, start, end, fund)
+		if err != nil {
+			return &FundChanges{}, err
+		}
+
+		change := FundChange{
+			Fund:          fund,
+			Opening:       fundBalance(balances, true),
+			Receipts:      []StatementLine{},
+			Distributions: []StatementLine{},
+		}
+		for _, ab := range balances {
+			switch ab.Type {
+			case AccountRevenue:
+				amount := ab.Credit - ab.Debit
+				if ab.Fund != fund || amount == 0 {
+					continue
+				}
+				change.Receipts = append(change.Receipts, StatementLine{Code: ab.Code, Name: ab.Name, Amount: amount})
+				change.TotalReceipts += amount
+			case AccountExpense:
+				amount := ab.Debit - ab.Credit
+				if ab.Fund != fund || amount == 0 {
+					continue
+				}
+				change.Distributions = append(change.Distributions, StatementLine{Code: ab.Code, Name: ab.Name, Amount: amount})
+				change.TotalDistributions += amount
+			}
+		}
+		change.Surplus = change.TotalReceipts - change.TotalDistributions
+		change.Closing = change.Opening + change.Surplus
+		fc.Funds = append(fc.Funds, change)
+	}
+
+	return &fc, nil
+}
+
+func GetManagedAssetChanges(db *gorm.DB, start, end time.Time) (*ManagedAssetChanges, error) {
+	mac := ManagedAssetChanges{PeriodStart: start, PeriodEnd: end, Assets: []ManagedAssetChange{}, Total: ManagedAssetChange{Name: "Jumlah"}}
+
+	for _, code := range managedAssetAccounts {
+		account := Account{}
+		err := db.Debug().Model(&Account{}).Where("code = ?", code).Take(&account).Error
+		if err != nil {
+			return &ManagedAssetChanges{}, err
+		}
+
+		opening, err := sumLines(db, "", "journal_entries.date < ? AND journal_lines.account_code = ?", start, code)
+		if err != nil {
+			return &ManagedAssetChanges{}, err
+		}
+		change := ManagedAssetChange{Code: code, Name: account.Name, Opening: opening[code].Debit - opening[code].Credit}
+
+		rows := []struct {
+			Source string
+			Debit  int
+			Credit int
+		}{}
+		err = db.Debug().Model(&JournalLine{}).
+			Select("journal_entries.source, COALESCE(SUM(journal_lines.debit), 0) AS debit, COALESCE(SUM(journal_lines.credit), 0) AS credit").
+			Joins("JOIN journal_entries ON journal_entries.id = journal_lines.journal_entry_id AND journal_entries.deleted_at IS NULL").
+			Where("journal_entries.date >= ? AND journal_entries.date <= ? AND journal_lines.account_code = ?", start, end, code).
+			Group("journal_entries.source").Scan(&rows).Error
+		if err != nil {
+			return &ManagedAssetChanges{}, err
+		}
+		for _, row := range rows {
+			net := row.Debit - row.Credit
+			switch row.Source {
+			case SourceWriteOff:
+				change.WrittenOff -= net
+			case SourceRepayment:
+				change.Collections -= net
+			default:
+				change.Additions += net
+			}
+		}
+		change.Closing = change.Opening + change.Additions - change.Collections - change.WrittenOff
+
+		mac.Assets = append(mac.Assets, change)
+		mac.Total.Opening += change.Opening
+		mac.Total.Additions += change.Additions
+		mac.Total.Collections += change.Collections
+		mac.Total.WrittenOff += change.WrittenOff
+		mac.Total.Closing += change.Closing
+	}
+
+	return &mac, nil
+}
@@ -0,0 +1,450 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Warehouse struct {
+	gorm.Model
+	Code    string `gorm:"size:50;not null;unique" json:"code"`
+	Name    string `gorm:"size:255;not null" json:"name"`
+	Address string `gorm:"size:255" json:"address"`
+}
+
+type StockMovement struct {
+	gorm.Model
+	WarehouseID uint      `gorm:"not null" json:"warehouse_id"`
+	Type        string    `gorm:"size:20;not null" json:"type"`
+	Quantity    float64   `gorm:"not null" json:"quantity"`
+	Amount      int       `gorm:"not null;default:0" json:"amount"`
+	Reference   string    `gorm:"size:100" json:"reference"`
+	Note        string    `gorm:"size:255" json:"note"`
+	MovedAt     time.Time `gorm:"not null" json:"moved_at"`
+	CreatedBy   string    `gorm:"size:255;not null" json:"created_by"`
+}
+
+type StockBalance struct {
+	WarehouseID uint    `json:"warehouse_id"`
+	Code        string  `json:"code"`
+	Name        string  `json:"name"`
+	Quantity    float64 `json:"quantity"`
+}
+
+// quantity is in kg, incoming movements are positive and outgoing ones negative
+const (
+	StockReceipt     = "receipt"
+	StockPurchase    = "purchase"
+	StockTransferIn  = "transfer_in"
+	StockTransferOut = "transfer_out"
+	StockIssue       = "issue"
+	StockAdjustment  = "adjustment"
+)
+
+var ErrInsufficientStock = errors.New("quantity exceeds the warehouse stock")
+
+func FitrahReference(id uint) string {
+	return fmt.Sprintf("zakat_fitrah:%d", id)
+}
+
+func PaymentReference(id uint) string {
+	return fmt.Sprintf("payment:%d", id)
+}
+
+func DistributionReference(id uint) string {
+	return fmt.Sprintf("distribution:%d", id)
+}
+
+func (w *Warehouse) Prepare() {
+	w.Code = html.EscapeString(strings.TrimSpace(strings.ToUpper(w.Code)))
+	w.Name = html.EscapeString(strings.TrimSpace(w.Name))
+	w.Address = html.EscapeString(strings.TrimSpace(w.Address))
+}
+
+func (w *Warehouse) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if w.Code == "" {
+		err = errors.New("required code")
+		errMsg["Required_code"] = err.Error()
+	}
+	if w.Name == "" {
+		err = errors.New("required name")
+		errMsg["Required_name"] = err.Error()
+	}
+
+	return errMsg
+}
+
+func (w *Warehouse) SaveWarehouse(db *gorm.DB) (*Warehouse, error) {
+	err := db.Debug().Create(&w).Error
+	if err != nil {
+		return &Warehouse{}, err
+	}
+
+	return w, nil
+}
+
+func (w *Warehouse) GetWarehouses(db *gorm.DB) (*[]Warehouse, error) {
+	warehouses := []Warehouse{}
+	err := db.Debug().Model(&Warehouse{}).Order("code").Find(&warehouses).Error
+	if err != nil {
+		return &[]Warehouse{}, err
+	}
+
+	return &warehouses, nil
+}
+
+func GetStock(db *gorm.DB, warehouseID uint) (float64, error) {
+	var stock float64
+	err := db.Debug().Model(&StockMovement{}).Where("warehouse_id = ?", warehouseID).Select("COALESCE(SUM(quantity), 0)").Scan(&stock).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return stock, nil
+}
+
+func GetStockBalances(db *gorm.DB) ([]StockBalance, error) {
+	balances := []StockBalance{}
+	err := db.Debug().Model(&Warehouse{}).
+		Select("warehouses.id AS warehouse_id, warehouses.code, warehouses.name, COALESCE(SUM(stock_movements.quantity), 0) AS quantity").
+		Joins("LEFT JOIN stock_movements ON stock_movements.warehouse_id = warehouses.id AND stock_movements.deleted_at IS NULL").
+		Group("warehouses.id, warehouses.code, warehouses.name").
+		Order("warehouses.code").
+		Scan(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+func (sm *StockMovement) GetStockMovements(db *gorm.DB, warehouseID, movementType string) (*[]StockMovement, error) {
+	movements := []StockMovement{}
+
+	query := db.Debug().Model(&StockMovement{})
+	if warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+	if movementType != "" {
+		query = query.Where("type = ?", movementType)
+	}
+	err := query.Order("moved_at desc").Find(&movements).Error
+	if err != nil {
+		return &[]StockMovement{}, err
+	}
+
+	return &movements, nil
+}
+
+// Move records one movement, outgoing quantities are checked against the running stock
+// Move books the movement while holding the warehouse row, so two movements out of one warehouse can not both
+// pass the stock check
+func (sm *StockMovement) Move(db *gorm.DB) (*StockMovement, error) {
+	if sm.MovedAt.IsZero() {
+		sm.MovedAt = time.Now()
+	}
+	sm.Note = html.EscapeString(strings.TrimSpace(sm.Note))
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		warehouse := Warehouse{}
+		err := tx.Debug().Clauses(clause.Locking{Strength: "UPDATE"}).Model(&Warehouse{}).Where("id = ?", sm.WarehouseID).Take(&warehouse).Error
+		if err != nil {
+			return err
+		}
+
+		if sm.Quantity < 0 {
+			stock, err := GetStock(tx, sm.WarehouseID)
+			if err != nil {
+				return err
+			}
+			if stock+sm.Quantity < 0 {
+				return ErrInsufficientStock
+			}
+		}
+
+		return tx.Debug().Create(&sm).Error
+	})
+	if err != nil {
+		return &StockMovement{}, err
+	}
+
+	return sm, nil
+}
+
+func Transfer(db *gorm.DB, fromID, toID uint, quantity float64, note, uid string) ([]StockMovement, error) {
+	if fromID == toID {
+		return nil, errors.New("transfer needs two different warehouses")
+	}
+	if quantity <= 0 {
+		return nil, errors.New("required quantity")
+	}
+
+	reference := fmt.Sprintf("transfer:%d", time.Now().UnixNano())
+	movements := []StockMovement{
+		{WarehouseID: fromID, Type: StockTransferOut, Quantity: -quantity, Reference: reference, Note: note, CreatedBy: uid},
+		{WarehouseID: toID, Type: StockTransferIn, Quantity: quantity, Reference: reference, Note: note, CreatedBy: uid},
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range movements {
+			_, err := movements[i].Move(tx)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return movements, nil
+}
+
+// Purchase buys rice with cash zakat fitrah, the amount leaves the fitrah cash fund
+func Purchase(db *gorm.DB, warehouseID uint, quantity float64, amount int, note, uid string) (*StockMovement, error) {
+	if quantity <= 0 || amount <= 0 {
+		return &StockMovement{}, errors.New("required quantity and amount")
+	}
+
+	sm := StockMovement{
+		WarehouseID: warehouseID,
+		Type:        StockPurchase,
+		Quantity:    quantity,
+		Amount:      amount,
+		Note:        note,
+		CreatedBy:   uid,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := lockFund(tx, FundZakatFitrah)
+		if err != nil {
+			return err
+		}
+		balance, err := GetFundBalance(tx, FundZakatFitrah, KindCash)
+		if err != nil {
+			return err
+		}
+		if float64(amount) > balance.Remaining {
+			return ErrInsufficientFund
+		}
+
+		_, err = sm.Move(tx)
+		if err != nil {
+			return err
+		}
+
+		return postSimpleJournal(tx, SourcePurchase, sm.ID, sm.MovedAt, sm.Note, uid, AccRiceInventory, AccCash, amount)
+	})
+	if err != nil {
+		return &StockMovement{}, err
+	}
+
+	return &sm, nil
+}
+
+// Adjust books a stock count difference at the average cost, a loss is spent from the zakat fund and a gain
+// comes back into it
+func Adjust(db *gorm.DB, warehouseID uint, quantity float64, note, uid string) (*StockMovement, error) {
+	if quantity == 0 {
+		return &StockMovement{}, errors.New("required quantity")
+	}
+	if strings.TrimSpace(note) == "" {
+		return &StockMovement{}, errors.New("required adjustment reason")
+	}
+
+	sm := StockMovement{
+		WarehouseID: warehouseID,
+		Type:        StockAdjustment,
+		Quantity:    quantity,
+		Note:        note,
+		CreatedBy:   uid,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		cost, err := IssueCost(tx, math.Abs(quantity))
+		if err != nil {
+			return err
+		}
+		debit, credit := AccRiceInventory, AccZakatReceipt
+		sm.Amount = cost
+		if quantity < 0 {
+			debit, credit = AccZakatSpending, AccRiceInventory
+			sm.Amount = -cost
+		}
+
+		_, err = sm.Move(tx)
+		if err != nil {
+			return err
+		}
+		if cost == 0 {
+			return nil
+		}
+
+		return postSimpleJournal(tx, SourceAdjustment, sm.ID, sm.MovedAt, sm.Note, uid, debit, credit, cost)
+	})
+	if err != nil {
+		return &StockMovement{}, err
+	}
+
+	return &sm, nil
+}
+
+// IssueCost values rice at the weighted average cost of the stock, every movement carries its value so the stock
+// stays in line with the rice inventory account
+func IssueCost(db *gorm.DB, quantity float64) (int, error) {
+	total := struct {
+		Quantity float64
+		Amount   float64
+	}{}
+	err := db.Debug().Model(&StockMovement{}).Select("COALESCE(SUM(quantity), 0) AS quantity, COALESCE(SUM(amount), 0) AS amount").Scan(&total).Error
+	if err != nil {
+		return 0, err
+	}
+	if total.Quantity <= 0 || total.Amount <= 0 {
+		return 0, nil
+	}
+
+	return int(math.Round(quantity * total.Amount / total.Quantity)), nil
+}
+
+// ReceivePayment puts the rice of an in-kind payment into the warehouse at the value the payment was booked at
+func ReceivePayment(db *gorm.DB, p *Payment) error {
+	receipt := StockMovement{
+		WarehouseID: *p.WarehouseID,
+		Type:        StockReceipt,
+		Quantity:    p.Weight,
+		Amount:      p.Amount,
+		Reference:   PaymentReference(p.ID),
+		MovedAt:     p.PaidAt,
+		CreatedBy:   p.ReceivedBy,
+	}
+	_, err := receipt.Move(db)
+
+	return err
+}
+
+// ReversePaymentReceipt takes the rice of a voided in-kind payment back out, refused when it already left the warehouse
+func ReversePaymentReceipt(db *gorm.DB, p *Payment, uid string) error {
+	receipts := []StockMovement{}
+	err := db.Debug().Where("type = ? AND reference = ? AND quantity > 0", StockReceipt, PaymentReference(p.ID)).Find(&receipts).Error
+	if err != nil {
+		return err
+	}
+
+	for _, receipt := range receipts {
+		reversal := StockMovement{
+			WarehouseID: receipt.WarehouseID,
+			Type:        StockReceipt,
+			Quantity:    -receipt.Quantity,
+			Amount:      -receipt.Amount,
+			Reference:   receipt.Reference,
+			Note:        "void",
+			CreatedBy:   uid,
+		}
+		_, err = reversal.Move(db)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReverseDistributionIssue books the rice of a deleted distribution back into the warehouse at the value it was issued at
+func ReverseDistributionIssue(db *gorm.DB, id uint, uid string) error {
+	issues := []StockMovement{}
+	err := db.Debug().Where("type = ? AND reference = ? AND quantity < 0", StockIssue, DistributionReference(id)).Find(&issues).Error
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		reversal := StockMovement{
+			WarehouseID: issue.WarehouseID,
+			Type:        StockIssue,
+			Quantity:    -issue.Quantity,
+			Amount:      -issue.Amount,
+			Reference:   issue.Reference,
+			Note:        "distribution deleted",
+			CreatedBy:   uid,
+		}
+		_, err = reversal.Move(db)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeFitrahReceipt drops a warehouse receipt booked on a zakat fitrah before rice went through payments, refused when the rice already left the warehouse
+func removeFitrahReceipt(db *gorm.DB, id uint) error {
+	receipts, err := fitrahReceipts(db, id)
+	if err != nil {
+		return err
+	}
+	err = db.Debug().Where("type = ? AND reference = ?", StockReceipt, FitrahReference(id)).Delete(&StockMovement{}).Error
+	if err != nil {
+		return err
+	}
+
+	return checkStocks(db, receipts)
+}
+
+func fitrahReceipts(db *gorm.DB, id uint) ([]StockMovement, error) {
+	receipts := []StockMovement{}
+	err := db.Debug().Where("type = ? AND reference = ?", StockReceipt, FitrahReference(id)).Find(&receipts).Error
+
+	return receipts, err
+}
+
+// checkStocks makes sure the warehouses of the movements did not end up below zero
+func checkStocks(db *gorm.DB, movements []StockMovement) error {
+	for _, sm := range movements {
+		// the warehouse row is held like in Move so a concurrent issue sees the removed receipt
+		warehouse := Warehouse{}
+		err := db.Debug().Clauses(clause.Locking{Strength: "UPDATE"}).Model(&Warehouse{}).Where("id = ?", sm.WarehouseID).Take(&warehouse).Error
+		if err != nil {
+			return err
+		}
+		stock, err := GetStock(db, sm.WarehouseID)
+		if err != nil {
+			return err
+		}
+		if stock < 0 {
+			return ErrInsufficientStock
+		}
+	}
+
+	return nil
+}
+
+// stockFlow sums purchases and adjustments that change the rice fund outside of fitrah receipts
+func stockFlow(db *gorm.DB) (float64, float64, float64, error) {
+	var purchasedKg, purchasedIdr, adjusted float64
+
+	err := db.Debug().Model(&StockMovement{}).Where("type = ?", StockPurchase).Select("COALESCE(SUM(quantity), 0)").Scan(&purchasedKg).Error
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	err = db.Debug().Model(&StockMovement{}).Where("type = ?", StockPurchase).Select("COALESCE(SUM(amount), 0)").Scan(&purchasedIdr).Error
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	err = db.Debug().Model(&StockMovement{}).Where("type = ?", StockAdjustment).Select("COALESCE(SUM(quantity), 0)").Scan(&adjusted).Error
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return purchasedKg, purchasedIdr, adjusted, nil
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type WeightUnit struct {
+	gorm.Model
+	Unit   string  `gorm:"size:50;not null" json:"unit"`
+	Region string  `gorm:"size:100;not null;default:''" json:"region"`
+	Gram   float64 `gorm:"not null" json:"gram"`
+}
+
+const (
+	UnitGram        = "gram"
+	UnitTroyOunce   = "troy_ounce"
+	UnitMayam       = "mayam"
+	UnitTahil       = "tahil"
+	UnitSuku        = "suku"
+	Troy_ounce_gram = 31.1034768
+)
+
+// default factors used when a region has no own conversion row
+var DefaultWeightUnits = map[string]float64{
+	UnitGram:      1,
+	UnitTroyOunce: Troy_ounce_gram,
+	UnitMayam:     3.33,
+	UnitTahil:     37.8,
+	UnitSuku:      9.45,
+}
+
+func (wu *WeightUnit) Prepare() {
+	wu.Unit = html.EscapeString(strings.TrimSpace(strings.ToLower(wu.Unit)))
+	wu.Region = html.EscapeString(strings.TrimSpace(strings.ToLower(wu.Region)))
+}
+
+func (wu *WeightUnit) Validate() map[string]string {
+	var errMsg = make(map[string]string)
+	var err error
+
+	if _, ok := DefaultWeightUnits[wu.Unit]; !ok {
+		err = errors.New("unit must be gram, troy_ounce, mayam, tahil, or suku")
+		errMsg["Invalid_unit"] = err.Error()
+	}
+	if wu.Region == "" {
+		err = errors.New("required region")
+		errMsg["Required_region"] = err.Error()
+	}
+	if wu.Unit == UnitGram && wu.Gram != 1 {
+		err = errors.New("gram conversion can not be changed")
+		errMsg["Invalid_gram"] = err.Error()
+	}
+	if wu.Gram <= 0 {
+		err = errors.New("required gram value")
+		errMsg["Required_gram"] = err.Error()
+	}
+
+	return errMsg
+}
+
+func (wu *WeightUnit) SaveWeightUnit(db *gorm.DB) (*WeightUnit, error) {
+	old := WeightUnit{}
+	err := db.Debug().Model(&WeightUnit{}).Where("unit = ? AND region = ?", wu.Unit, wu.Region).Take(&old).Error
+	if err == nil {
+		err = db.Debug().Model(&WeightUnit{}).Where("id = ?", old.ID).Update("gram", wu.Gram).Error
+		if err != nil {
+			return &WeightUnit{}, err
+		}
+		wu.ID = old.ID
+
+		return wu, nil
+	}
+
+	err = db.Debug().Create(&wu).Error
+	if err != nil {
+		return &WeightUnit{}, err
+	}
+
+	return wu, nil
+}
+
+func (wu *WeightUnit) GetWeightUnits(db *gorm.DB) (*[]WeightUnit, error) {
+	units := []WeightUnit{}
+	err := db.Debug().Model(&WeightUnit{}).Order("region, unit").Find(&units).Error
+	if err != nil {
+		return &[]WeightUnit{}, err
+	}
+
+	return &units, nil
+}
+
+func (wu *WeightUnit) DeleteWeightUnit(db *gorm.DB, id string) (int, error) {
+	db = db.Debug().Model(&WeightUnit{}).Where("id = ?", id).Take(&WeightUnit{}).Delete(&WeightUnit{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return int(db.RowsAffected), nil
+}
+
+// ToGram converts weight in the given unit to grams, regional factors take priority over the defaults
+func ToGram(db *gorm.DB, weight float64, unit, region string) (float64, error) {
+	unit = strings.TrimSpace(strings.ToLower(unit))
+	region = strings.TrimSpace(strings.ToLower(region))
+	if unit == "" {
+		unit = UnitGram
+	}
+
+	factor, ok := DefaultWeightUnits[unit]
+	if !ok {
+		return 0, errors.New("unknown weight unit " + unit)
+	}
+
+	if region != "" && unit != UnitGram {
+		wu := WeightUnit{}
+		err := db.Debug().Model(&WeightUnit{}).Where("unit = ? AND region = ?", unit, region).Take(&wu).Error
+		if err == nil {
+			factor = wu.Gram
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+	}
+
+	gram := weight * factor
+	return math.Round(gram*1000) / 1000, nil
+}
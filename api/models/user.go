@@ -32,6 +32,11 @@ func RandomUID() (s string) {
 	return
 }
 
+// StaffRole reports whether the role reviews mustahik, these roles are handed out by an admin and never self-registered
+func StaffRole(role string) bool {
+	return role == "surveyor" || role == "committee"
+}
+
 func (u *User) BeforeSave() error {
 	hashpassword, err := security.Hash(u.Password)
 	if err != nil {
@@ -110,7 +115,7 @@ func (u *User) Validate(action string) map[string]string {
 			err = errors.New("required role")
 			errMsg["Required_role"] = err.Error()
 		}
-		if u.Role != "admin" && u.Role != "muzakki" {
+		if u.Role != "admin" && u.Role != "muzakki" && u.Role != "surveyor" && u.Role != "committee" {
 			err = errors.New("invalid role")
 			errMsg["Invalid_role"] = err.Error()
 		}
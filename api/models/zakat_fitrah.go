@@ -3,31 +3,54 @@ package models
 import (
 	"errors"
 	"math"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type ZakatFitrah struct {
 	gorm.Model
-	IdMuzakki   string  `gorm:"column:id_muzakki;unique;not null"`
-	TotalPerson int     `gorm:"not null" json:"totalPerson"`
-	TotalWeight float64 `gorm:"not null"`
-	TotalPrice  int     `gorm:"not null"`
+	IdMuzakki     string  `gorm:"column:id_muzakki;unique;not null"`
+	TotalPerson   int     `gorm:"not null" json:"totalPerson"`
+	TotalWeight   float64 `gorm:"not null"`
+	TotalPrice    int     `gorm:"not null"`
+	Region        string  `gorm:"size:100" json:"region"`
+	RicePrice     float64 `gorm:"not null;default:0"`
+	PaymentForm   string  `gorm:"size:20;not null;default:cash" json:"payment_form"`
+	WarehouseID   *uint   `json:"warehouse_id"`
+	Paid          int     `gorm:"-" json:"paid"`
+	PaymentStatus string  `gorm:"-" json:"payment_status"`
 }
 
 const (
 	Rice_weight = 2.8
-	Rice_price  = 33000
 )
 
-func (zf *ZakatFitrah) Prepare(mID string) {
+func GetRicePrice(db *gorm.DB, region string) (float64, error) {
+	cp := CommodityPrice{}
+	price, err := cp.GetActivePrice(db, CommodityRice, UnitKg, region, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return price.Price, nil
+}
+
+func (zf *ZakatFitrah) Prepare(mID string, ricePrice float64) {
 	//get data
 	person := int(zf.TotalPerson)
 	weight := (float64(person) * Rice_weight)
 	total_weight := math.Ceil(weight*100) / 100
-	total_price := person * Rice_price
+	total_price := int(math.Ceil(total_weight * ricePrice))
 
 	zf.IdMuzakki = mID
+	zf.Region = strings.TrimSpace(strings.ToLower(zf.Region))
+	zf.RicePrice = ricePrice
+	zf.PaymentForm = strings.TrimSpace(strings.ToLower(zf.PaymentForm))
+	if zf.PaymentForm == "" {
+		zf.PaymentForm = KindCash
+	}
 	zf.TotalPerson = person
 	zf.TotalWeight = total_weight
 	zf.TotalPrice = total_price
@@ -41,6 +64,14 @@ func (zf *ZakatFitrah) Validate() map[string]string {
 		err = errors.New("required total person")
 		errMsg["Required_totalPerson"] = err.Error()
 	}
+	if zf.PaymentForm != KindCash && zf.PaymentForm != KindRice {
+		err = errors.New("payment form must be cash or rice")
+		errMsg["Invalid_paymentForm"] = err.Error()
+	}
+	if zf.PaymentForm == KindRice && zf.WarehouseID == nil {
+		err = errors.New("required warehouse for zakat fitrah paid in rice")
+		errMsg["Required_warehouse"] = err.Error()
+	}
 
 	return errMsg
 }
@@ -61,6 +92,11 @@ func (zf *ZakatFitrah) GetZakatFitrahs(db *gorm.DB) (*[]ZakatFitrah, error) {
 		return &[]ZakatFitrah{}, err
 	}
 
+	err = LoadZakatFitrahPayments(db, zfs)
+	if err != nil {
+		return &[]ZakatFitrah{}, err
+	}
+
 	return &zfs, nil
 }
 
@@ -70,20 +106,31 @@ func (zf *ZakatFitrah) GetZakatFitrah(mID string, db *gorm.DB) (*ZakatFitrah, er
 		return &ZakatFitrah{}, err
 	}
 
-	return zf, nil
-}
-
-func (zf *ZakatFitrah) UpdateZakatFitrah(db *gorm.DB) (*ZakatFitrah, error) {
-	err := db.Debug().Model(&ZakatFitrah{}).Where("id = ?", zf.ID).Updates(ZakatFitrah{
-		TotalPerson: zf.TotalPerson,
-		TotalWeight: zf.TotalWeight,
-		TotalPrice:  zf.TotalPrice,
-	}).Error
+	err = zf.LoadPayment(db)
 	if err != nil {
 		return &ZakatFitrah{}, err
 	}
 
-	err = db.Debug().Model(&ZakatFitrah{}).Where("id = ?", zf.ID).Take(&zf).Error
+	return zf, nil
+}
+
+func (zf *ZakatFitrah) UpdateZakatFitrah(db *gorm.DB) (*ZakatFitrah, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Debug().Model(&ZakatFitrah{}).Where("id = ?", zf.ID).Updates(ZakatFitrah{
+			TotalPerson: zf.TotalPerson,
+			TotalWeight: zf.TotalWeight,
+			TotalPrice:  zf.TotalPrice,
+			Region:      zf.Region,
+			RicePrice:   zf.RicePrice,
+			PaymentForm: zf.PaymentForm,
+			WarehouseID: zf.WarehouseID,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Debug().Model(&ZakatFitrah{}).Where("id = ?", zf.ID).Take(&zf).Error
+	})
 	if err != nil {
 		return &ZakatFitrah{}, err
 	}
@@ -92,9 +139,34 @@ func (zf *ZakatFitrah) UpdateZakatFitrah(db *gorm.DB) (*ZakatFitrah, error) {
 }
 
 func (zf *ZakatFitrah) DeleteZakatFitrah(db *gorm.DB, uid string) (int, error) {
-	db = db.Debug().Model(&ZakatFitrah{}).Where("id_muzakki = ?", uid).Take(&ZakatFitrah{}).Delete(&ZakatFitrah{})
-	if db.Error != nil {
-		return 0, db.Error
+	var rows int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		old := ZakatFitrah{}
+		err := tx.Debug().Model(&ZakatFitrah{}).Where("id_muzakki = ?", uid).Take(&old).Error
+		if err != nil {
+			return err
+		}
+
+		paid, err := hasPayments(tx, FundZakatFitrah, old.ID)
+		if err != nil {
+			return err
+		}
+		if paid {
+			return ErrObligationHasPayments
+		}
+		err = removeFitrahReceipt(tx, old.ID)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Debug().Model(&ZakatFitrah{}).Where("id = ?", old.ID).Delete(&ZakatFitrah{})
+		rows = result.RowsAffected
+
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
 	}
-	return int(db.RowsAffected), nil
+
+	return int(rows), nil
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"html"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -13,30 +14,237 @@ type ZakatMal struct {
 	IdMuzakki   string  `gorm:"column:id_muzakki;not null"`
 	TypeZakat   string  `gorm:"size:255;not null" json:"type_zakat"`
 	TotalWeight float64 `gorm:"not null;default:0" json:"total_weight"`
+	Unit        string  `gorm:"size:50;not null;default:gram" json:"unit"`
+	Region      string  `gorm:"size:100" json:"region"`
+	UnitWeight  float64 `gorm:"not null;default:0" json:"unit_weight"`
 	TotalAssest int     `gorm:"not null;default:0" json:"total_price"`
-	TotalZakat  int     `gorm:"not null"`
+	Commodity   string  `gorm:"size:100" json:"commodity"`
+	Irrigated   bool    `gorm:"not null;default:false" json:"irrigated"`
+	// balance sheet of zakat dagang, the zakatable wealth is derived from it into TotalAssest
+	CurrentAssets      int    `gorm:"not null;default:0" json:"current_assets"`
+	CurrentLiabilities int    `gorm:"not null;default:0" json:"current_liabilities"`
+	Adjustment         int    `gorm:"not null;default:0" json:"adjustment"`
+	AdjustmentNote     string `gorm:"size:255" json:"adjustment_note"`
+	// productive asset of zakat mustaghallat, only the net income of the period is zakatable
+	AssetKind        string     `gorm:"size:50" json:"asset_kind"`
+	AssetDescription string     `gorm:"size:255" json:"asset_description"`
+	IncomeFrom       *time.Time `json:"income_from"`
+	IncomeTo         *time.Time `json:"income_to"`
+	GrossIncome      int        `gorm:"not null;default:0" json:"gross_income"`
+	Expenses         int        `gorm:"not null;default:0" json:"expenses"`
+	TotalZakat       int        `gorm:"not null"`
+	Period           string     `gorm:"size:7;uniqueIndex:idx_zakat_mal_commitment_period" json:"period"`
+	CommitmentID     *uint      `gorm:"uniqueIndex:idx_zakat_mal_commitment_period" json:"commitment_id"`
+	DueDate          *time.Time `json:"due_date"`
+	Paid             int        `gorm:"-" json:"paid"`
+	PaymentStatus    string     `gorm:"-" json:"payment_status"`
 }
 
+const (
+	Zakat_rate      = 2.5
+	Pertanian_nisab = 653
+	Pertanian_rate  = 10
+	// 85 grams of gold a year, income is checked against a twelfth of it every month
+	Profesi_nisab = 85
+	// 85 grams of gold a year, prorated over the months of the income period
+	Mustaghallat_nisab = 85
+)
+
+// AssetKinds are the productive assets accepted for zakat mustaghallat
+var AssetKinds = map[string]string{
+	"house":   "Rumah sewa",
+	"kost":    "Kost",
+	"vehicle": "Kendaraan sewa",
+	"other":   "Lainnya",
+}
+
+var ErrNotObligated = errors.New("tidak wajib membayar zakat")
+
 func (zm *ZakatMal) Prepare(mID string, totalZakat float64) {
 	zm.IdMuzakki = mID
 	zm.TypeZakat = html.EscapeString(strings.TrimSpace(strings.ToLower(zm.TypeZakat)))
+	zm.Commodity = html.EscapeString(strings.TrimSpace(strings.ToLower(zm.Commodity)))
+	zm.AdjustmentNote = html.EscapeString(strings.TrimSpace(zm.AdjustmentNote))
+	if zm.TypeZakat != "dagang" {
+		zm.CurrentAssets, zm.CurrentLiabilities, zm.Adjustment, zm.AdjustmentNote = 0, 0, 0, ""
+	}
+	zm.AssetKind = strings.TrimSpace(strings.ToLower(zm.AssetKind))
+	zm.AssetDescription = html.EscapeString(strings.TrimSpace(zm.AssetDescription))
+	if zm.TypeZakat != "mustaghallat" {
+		zm.AssetKind, zm.AssetDescription, zm.IncomeFrom, zm.IncomeTo, zm.GrossIncome, zm.Expenses = "", "", nil, nil, 0, 0
+	}
 	zm.TotalZakat = int(totalZakat)
 }
 
+// HasBalanceSheet tells a dagang filing made from a balance sheet from one with a single total
+func (zm *ZakatMal) HasBalanceSheet() bool {
+	return zm.CurrentAssets != 0 || zm.CurrentLiabilities != 0
+}
+
+// IncomeMonths counts the months of the income period rounded up, a filing without a period is a full year
+func (zm *ZakatMal) IncomeMonths() int {
+	if zm.IncomeFrom == nil || zm.IncomeTo == nil {
+		return 12
+	}
+
+	// a period ending the day before its start day closes a whole month, 15 March to 14 March is twelve
+	months := (zm.IncomeTo.Year()-zm.IncomeFrom.Year())*12 + int(zm.IncomeTo.Month()-zm.IncomeFrom.Month())
+	if zm.IncomeTo.Day() >= zm.IncomeFrom.Day() {
+		months++
+	}
+	if months < 1 {
+		return 1
+	}
+
+	return months
+}
+
+// BusinessBase is current assets less current liabilities plus the adjustment, a negative adjustment
+// takes out what is not zakatable such as doubtful receivables or prepaid expenses
+func (zm *ZakatMal) BusinessBase() int {
+	return zm.CurrentAssets - zm.CurrentLiabilities + zm.Adjustment
+}
+
+// NormalizeWeight keeps the weight as entered in UnitWeight and stores TotalWeight in grams
+func (zm *ZakatMal) NormalizeWeight(db *gorm.DB) error {
+	if strings.ToLower(zm.TypeZakat) == "pertanian" {
+		zm.Unit = UnitKg
+		zm.UnitWeight = zm.TotalWeight
+		return nil
+	}
+
+	zm.Unit = strings.TrimSpace(strings.ToLower(zm.Unit))
+	if zm.Unit == "" {
+		zm.Unit = UnitGram
+	}
+	zm.Region = html.EscapeString(strings.TrimSpace(strings.ToLower(zm.Region)))
+
+	gram, err := ToGram(db, zm.TotalWeight, zm.Unit, zm.Region)
+	if err != nil {
+		return err
+	}
+	zm.UnitWeight = zm.TotalWeight
+	zm.TotalWeight = gram
+
+	return nil
+}
+
+// Calculate returns the zakat to pay, weights must already be normalized
+func (zm *ZakatMal) Calculate(db *gorm.DB) (float64, error) {
+	typeZakat := strings.ToLower(zm.TypeZakat)
+
+	if typeZakat == "pertanian" {
+		ruling, err := GetRuling(db, typeZakat)
+		if err != nil {
+			return 0, err
+		}
+
+		cp := CommodityPrice{}
+		price, err := cp.GetActivePrice(db, zm.Commodity, UnitKg, zm.Region, time.Now())
+		if err != nil {
+			return 0, err
+		}
+		if zm.TotalWeight < ruling.Nisab {
+			return 0, ErrNotObligated
+		}
+
+		// irrigated land pays half of the rain-fed rate
+		rate := ruling.Rate
+		if zm.Irrigated {
+			rate = ruling.Rate / 2
+		}
+
+		return (zm.TotalWeight * price.Price * rate) / 100, nil
+	}
+
+	idr := PriceIdr{}
+	getIdr, err := idr.GetIDR(typeZakat, db)
+	if err != nil {
+		return 0, err
+	}
+
+	// filings made before balance sheets keep their single total
+	if typeZakat == "dagang" && zm.HasBalanceSheet() {
+		zm.TotalAssest = zm.BusinessBase()
+	}
+
+	var pw float64
+	if typeZakat == "emas" || typeZakat == "perak" {
+		pw = zm.TotalWeight * getIdr.IdrPrice
+	}
+
+	// the asset itself is not zakatable, the net income is checked against the nisab of the period
+	if typeZakat == "mustaghallat" {
+		zm.TotalAssest = zm.GrossIncome - zm.Expenses
+		if float64(zm.TotalAssest) >= getIdr.GetNisab*float64(zm.IncomeMonths())/12 {
+			return (float64(zm.TotalAssest) * getIdr.Rate) / 100, nil
+		}
+		return 0, ErrNotObligated
+	}
+
+	if typeZakat == "profesi" {
+		if float64(zm.TotalAssest) >= getIdr.GetNisab/12 {
+			return (float64(zm.TotalAssest) * getIdr.Rate) / 100, nil
+		}
+		return 0, ErrNotObligated
+	}
+
+	if float64(zm.TotalAssest) > getIdr.GetNisab && typeZakat == "dagang" {
+		return (float64(zm.TotalAssest) * getIdr.Rate) / 100, nil
+	} else if pw > getIdr.GetNisab && (typeZakat == "emas" || typeZakat == "perak") {
+		return (pw * getIdr.Rate) / 100, nil
+	}
+
+	return 0, ErrNotObligated
+}
+
 func (zm *ZakatMal) Validate() map[string]string {
 	var errMsg = make(map[string]string)
 	var err error
 
 	if zm.TypeZakat == "" && zm.TypeZakat != "emas" {
-		err = errors.New("required type zakat and fill in this columns with emas, perak, or dagang")
+		err = errors.New("required type zakat and fill in this columns with emas, perak, dagang, pertanian, profesi, or mustaghallat")
 		errMsg["Required_type"] = err.Error()
 	} else if zm.TypeZakat == "" && zm.TypeZakat != "perak" {
-		err = errors.New("required type zakat and fill in this columns with emas, perak, or dagang")
+		err = errors.New("required type zakat and fill in this columns with emas, perak, dagang, pertanian, profesi, or mustaghallat")
 		errMsg["Required_type"] = err.Error()
 	} else if zm.TypeZakat == "" && zm.TypeZakat != "dagang" {
-		err = errors.New("required type zakat and fill in this columns with emas, perak, or dagang")
+		err = errors.New("required type zakat and fill in this columns with emas, perak, dagang, pertanian, profesi, or mustaghallat")
 		errMsg["Required_type"] = err.Error()
 	}
+	if zm.TypeZakat == "pertanian" && zm.Commodity == "" {
+		err = errors.New("required commodity for zakat pertanian")
+		errMsg["Required_commodity"] = err.Error()
+	}
+	if zm.CurrentAssets < 0 || zm.CurrentLiabilities < 0 {
+		err = errors.New("current assets and current liabilities can not be negative")
+		errMsg["Invalid_balanceSheet"] = err.Error()
+	}
+	if zm.Adjustment != 0 && zm.AdjustmentNote == "" {
+		err = errors.New("required adjustment note to explain the adjustment")
+		errMsg["Required_adjustmentNote"] = err.Error()
+	}
+	if zm.TypeZakat == "mustaghallat" {
+		if _, ok := AssetKinds[zm.AssetKind]; !ok {
+			err = errors.New("asset kind must be house, kost, vehicle, or other")
+			errMsg["Invalid_assetKind"] = err.Error()
+		}
+		if zm.AssetDescription == "" {
+			err = errors.New("required asset description for zakat mustaghallat")
+			errMsg["Required_assetDescription"] = err.Error()
+		}
+		if zm.IncomeFrom == nil || zm.IncomeTo == nil {
+			err = errors.New("required income period for zakat mustaghallat")
+			errMsg["Required_incomePeriod"] = err.Error()
+		} else if zm.IncomeTo.Before(*zm.IncomeFrom) || zm.IncomeMonths() > 12 {
+			err = errors.New("income period must end after it starts and span at most a year")
+			errMsg["Invalid_incomePeriod"] = err.Error()
+		}
+		if zm.GrossIncome <= 0 || zm.Expenses < 0 {
+			err = errors.New("required gross income, expenses can not be negative")
+			errMsg["Invalid_income"] = err.Error()
+		}
+	}
 	if zm.TotalWeight == 0 && zm.TotalAssest == 0 {
 		err = errors.New("required total weight or total assest")
 		errMsg["Required_value"] = err.Error()
@@ -62,6 +270,11 @@ func (zm *ZakatMal) GetZakatMals(db *gorm.DB) (*[]ZakatMal, error) {
 		return &[]ZakatMal{}, err
 	}
 
+	err = LoadZakatMalPayments(db, zakatMal)
+	if err != nil {
+		return &[]ZakatMal{}, err
+	}
+
 	return &zakatMal, nil
 }
 
@@ -72,6 +285,11 @@ func (zm *ZakatMal) GetZakatMalByID(db *gorm.DB, mID string) (*[]ZakatMal, error
 		return &[]ZakatMal{}, err
 	}
 
+	err = LoadZakatMalPayments(db, zakatMal)
+	if err != nil {
+		return &[]ZakatMal{}, err
+	}
+
 	return &zakatMal, nil
 }
 
@@ -82,16 +300,48 @@ func (zm *ZakatMal) GetZakatMalByType(db *gorm.DB, mID, tz string) (*ZakatMal, e
 	return zm, nil
 }
 
+// UpdateZakatMal recalculates a filing, one with recorded payments is left as it was paid
 func (zm *ZakatMal) UpdateZakatMal(db *gorm.DB, tz string) (*ZakatMal, error) {
-	err := db.Debug().Model(&ZakatMal{}).Where("id = ? AND type_zakat = ?", zm.ID, tz).Updates(ZakatMal{
+	paid, err := hasPayments(db, FundZakatMal, zm.ID)
+	if err != nil {
+		return &ZakatMal{}, err
+	}
+	if paid {
+		return &ZakatMal{}, ErrObligationHasPayments
+	}
+
+	err = db.Debug().Model(&ZakatMal{}).Where("id = ? AND type_zakat = ?", zm.ID, tz).Updates(ZakatMal{
 		TotalWeight: zm.TotalWeight,
+		Unit:        zm.Unit,
+		Region:      zm.Region,
+		UnitWeight:  zm.UnitWeight,
 		TotalAssest: zm.TotalAssest,
+		Commodity:   zm.Commodity,
+		Irrigated:   zm.Irrigated,
 		TotalZakat:  zm.TotalZakat,
 	}).Error
 	if err != nil {
 		return &ZakatMal{}, err
 	}
 
+	// zero is a valid value for these, Updates with a struct would skip them
+	err = db.Debug().Model(&ZakatMal{}).Where("id = ?", zm.ID).Updates(map[string]interface{}{
+		"irrigated":           zm.Irrigated,
+		"current_assets":      zm.CurrentAssets,
+		"current_liabilities": zm.CurrentLiabilities,
+		"adjustment":          zm.Adjustment,
+		"adjustment_note":     zm.AdjustmentNote,
+		"asset_kind":          zm.AssetKind,
+		"asset_description":   zm.AssetDescription,
+		"income_from":         zm.IncomeFrom,
+		"income_to":           zm.IncomeTo,
+		"gross_income":        zm.GrossIncome,
+		"expenses":            zm.Expenses,
+	}).Error
+	if err != nil {
+		return &ZakatMal{}, err
+	}
+
 	err = db.Debug().Model(&ZakatMal{}).Where("id = ?", zm.ID).Take(&zm).Error
 	if err != nil {
 		return &ZakatMal{}, err
@@ -100,7 +350,60 @@ func (zm *ZakatMal) UpdateZakatMal(db *gorm.DB, tz string) (*ZakatMal, error) {
 	return zm, nil
 }
 
+// GetMustaghallatPrefill drafts this year's filings from the latest one of every asset, the period moves a year on
+// and the income and expenses are copied for the muzakki to correct
+func (zm *ZakatMal) GetMustaghallatPrefill(db *gorm.DB, mID string) (*[]ZakatMal, error) {
+	filed := []ZakatMal{}
+	err := db.Debug().Model(&ZakatMal{}).Where("id_muzakki = ? AND type_zakat = ?", mID, "mustaghallat").
+		Order("income_to desc, id desc").Find(&filed).Error
+	if err != nil {
+		return &[]ZakatMal{}, err
+	}
+
+	drafts := []ZakatMal{}
+	seen := map[string]bool{}
+	for _, last := range filed {
+		key := last.AssetKind + "|" + strings.ToLower(last.AssetDescription)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		draft := ZakatMal{
+			IdMuzakki:        mID,
+			TypeZakat:        last.TypeZakat,
+			AssetKind:        last.AssetKind,
+			AssetDescription: last.AssetDescription,
+			GrossIncome:      last.GrossIncome,
+			Expenses:         last.Expenses,
+		}
+		if last.IncomeFrom != nil && last.IncomeTo != nil {
+			from, to := last.IncomeFrom.AddDate(1, 0, 0), last.IncomeTo.AddDate(1, 0, 0)
+			draft.IncomeFrom, draft.IncomeTo = &from, &to
+		}
+		drafts = append(drafts, draft)
+	}
+
+	return &drafts, nil
+}
+
 func (zm *ZakatMal) DeleteZakatMalByID(mID string, db *gorm.DB) (int, error) {
+	var count int64
+	err := db.Debug().Model(&Payment{}).Where("obligation_type = ? AND id_muzakki = ? AND status = ?", FundZakatMal, mID, PaymentRecorded).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, ErrObligationHasPayments
+	}
+	err = db.Debug().Model(&PaymentAllocation{}).Where("id_muzakki = ? AND obligation_id IN (?)", mID, allocatedObligations(db, FundZakatMal)).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, ErrObligationHasPayments
+	}
+
 	db = db.Debug().Model(&ZakatMal{}).Where("id_muzakki = ?", mID).Take(&ZakatMal{}).Delete(&ZakatMal{})
 	if db.Error != nil {
 		return 0, db.Error
@@ -109,6 +412,17 @@ func (zm *ZakatMal) DeleteZakatMalByID(mID string, db *gorm.DB) (int, error) {
 }
 
 func (zm *ZakatMal) DeleteZakatMalByType(mID, tz string, db *gorm.DB) (int, error) {
+	old := ZakatMal{}
+	if err := db.Debug().Model(&ZakatMal{}).Where("id_muzakki = ? AND type_zakat = ?", mID, tz).Take(&old).Error; err == nil {
+		paid, err := hasPayments(db, FundZakatMal, old.ID)
+		if err != nil {
+			return 0, err
+		}
+		if paid {
+			return 0, ErrObligationHasPayments
+		}
+	}
+
 	db = db.Debug().Model(&ZakatMal{}).Where("id_muzakki = ? AND type_zakat = ?", mID, tz).Take(&ZakatMal{}).Delete(&ZakatMal{})
 	if db.Error != nil {
 		return 0, db.Error
@@ -0,0 +1,161 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"zakat/api/models"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+var obligationNames = map[string]string{
+	models.FundZakatFitrah:  "Zakat fitrah",
+	models.FundZakatMal:     "Zakat mal",
+	models.FundInfaqSadaqah: "Infaq/sedekah",
+}
+
+// AnnualStatementPDF renders the yearly summary a muzakki attaches to the income tax return
+func AnnualStatementPDF(as *models.AnnualStatement) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(10, 12, 10)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(pageWidth, 7, tr(as.OrgName), "", 1, "C", false, 0, "")
+	if as.OrgAddress != "" {
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(pageWidth, 5, tr(as.OrgAddress), "", 1, "C", false, 0, "")
+	}
+	pdf.Line(10, pdf.GetY()+1, 10+pageWidth, pdf.GetY()+1)
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(pageWidth, 7, fmt.Sprintf("REKAPITULASI PEMBAYARAN ZAKAT DAN INFAQ TAHUN %d", as.Year), "", 1, "C", false, 0, "")
+	pdf.Ln(3)
+
+	pdf.SetFont("Helvetica", "", 10)
+	fields := [][2]string{
+		{"Nama muzakki", as.MuzakkiName},
+		{"ID muzakki", as.IdMuzakki},
+		{"NPWZ", as.Npwz},
+		{"NPWP", formatNpwp(as.Npwp)},
+		{"Alamat", as.MuzakkiAddress},
+	}
+	for _, field := range fields {
+		pdf.CellFormat(35, 6, field[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(4, 6, ":", "", 0, "L", false, 0, "")
+		pdf.CellFormat(pageWidth-39, 6, tr(field[1]), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(3)
+
+	statementLines(pdf, as.Lines, true)
+	pdf.Ln(3)
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, total := range as.Totals {
+		if total.Deductible {
+			row(pdf, "Jumlah "+strings.ToLower(obligationName(total.ObligationType)), total.Amount)
+		}
+	}
+	total(pdf, "Jumlah zakat dibayar", as.Total)
+	pdf.SetFont("Helvetica", "I", 10)
+	pdf.MultiCell(pageWidth, 6, tr("Terbilang: "+Title(as.TotalWords)), "", "L", false)
+
+	// infaq is listed so the muzakki sees every payment, but it is kept out of the deductible total
+	if as.OtherTotal != 0 {
+		pdf.Ln(3)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(pageWidth, 6, "Infaq dan pembayaran lain (tidak dapat dikurangkan dari penghasilan bruto)", "", 1, "L", false, 0, "")
+		statementLines(pdf, as.Lines, false)
+		pdf.Ln(3)
+		pdf.SetFont("Helvetica", "", 10)
+		for _, total := range as.Totals {
+			if !total.Deductible {
+				row(pdf, "Jumlah "+strings.ToLower(obligationName(total.ObligationType)), total.Amount)
+			}
+		}
+		total(pdf, "Jumlah infaq dan lainnya", as.OtherTotal)
+	}
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.MultiCell(pageWidth, 4, "Zakat yang dibayarkan melalui badan amil zakat resmi dapat dikurangkan dari penghasilan bruto "+
+		"sesuai Undang-Undang Pajak Penghasilan dan PP Nomor 60 Tahun 2010. Setiap bukti setor dapat diverifikasi melalui kode QR yang tercetak di dalamnya.", "", "L", false)
+	pdf.Ln(2)
+	pdf.CellFormat(pageWidth, 4, "Dicetak "+Date(as.GeneratedAt), "", 1, "R", false, 0, "")
+
+	return output(pdf)
+}
+
+// statementLines prints the receipts that are, or are not, deductible as one table
+func statementLines(pdf *gofpdf.Fpdf, lines []models.AnnualStatementLine, deductible bool) {
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	widths := []float64{10, 50, 28, 62, 40}
+	headers := []string{"No", "Nomor bukti setor", "Tanggal", "Jenis", "Jumlah"}
+	pdf.SetFont("Helvetica", "B", 9)
+	for i, header := range headers {
+		pdf.CellFormat(widths[i], lineHeight, header, "1", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 9)
+	no := 0
+	for _, line := range lines {
+		if line.Deductible != deductible {
+			continue
+		}
+		no++
+		cells := []string{fmt.Sprint(no), line.ReceiptNumber, line.PaidAt.Format("02-01-2006"), tr(line.ZakatType), Rupiah(line.Amount)}
+		aligns := []string{"C", "L", "C", "L", "R"}
+		for j, cell := range cells {
+			pdf.CellFormat(widths[j], lineHeight, cell, "1", 0, aligns[j], false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+}
+
+func obligationName(obligationType string) string {
+	if name, ok := obligationNames[obligationType]; ok {
+		return name
+	}
+	return obligationType
+}
+
+// AnnualStatementsZip packs one PDF per muzakki for the batch download
+func AnnualStatementsZip(statements []models.AnnualStatement) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	for i := range statements {
+		pdf, err := AnnualStatementPDF(&statements[i])
+		if err != nil {
+			return nil, err
+		}
+		w, err := archive.Create(fmt.Sprintf("rekap-zakat-%d-%s.pdf", statements[i].Year, statements[i].IdMuzakki))
+		if err != nil {
+			return nil, err
+		}
+		_, err = w.Write(pdf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := archive.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// formatNpwp prints the 15 digit NPWP as 01.234.567.8-901.000, the 16 digit NIK form is left as is
+func formatNpwp(npwp string) string {
+	if len(npwp) != 15 {
+		return npwp
+	}
+
+	return npwp[0:2] + "." + npwp[2:5] + "." + npwp[5:8] + "." + npwp[8:9] + "-" + npwp[9:12] + "." + npwp[12:15]
+}
@@ -0,0 +1,46 @@
+package report
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+var months = []string{"Januari", "Februari", "Maret", "April", "Mei", "Juni", "Juli", "Agustus", "September", "Oktober", "November", "Desember"}
+
+// Rupiah formats an amount as "Rp 1.250.000", negatives are shown in brackets
+func Rupiah(amount int) string {
+	if amount < 0 {
+		return "(" + Rupiah(-amount) + ")"
+	}
+
+	digits := strconv.Itoa(amount)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+
+	return "Rp " + b.String()
+}
+
+// Date formats a date the Indonesian way, e.g. "17 Agustus 2026"
+func Date(t time.Time) string {
+	return strconv.Itoa(t.Day()) + " " + months[t.Month()-1] + " " + strconv.Itoa(t.Year())
+}
+
+// Title capitalizes the first letter of every word, amounts in words are printed that way
+func Title(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+
+	return strings.Join(words, " ")
+}
+
+func Period(start, end time.Time) string {
+	return Date(start) + " s.d. " + Date(end)
+}
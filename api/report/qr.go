@@ -0,0 +1,39 @@
+package report
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/png"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// drawQR encodes content as a QR code and places it as a square image of the given size
+func drawQR(pdf *gofpdf.Fpdf, name, content string, x, y, size float64) error {
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return err
+	}
+	code, err = barcode.Scale(code, 400, 400)
+	if err != nil {
+		return err
+	}
+
+	// gofpdf only reads 8-bit PNGs while the encoder produces 16-bit gray
+	gray := image.NewGray(code.Bounds())
+	draw.Draw(gray, gray.Bounds(), code, code.Bounds().Min, draw.Src)
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, gray)
+	if err != nil {
+		return err
+	}
+
+	pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "PNG"}, &buf)
+	pdf.ImageOptions(name, x, y, size, size, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+
+	return pdf.Error()
+}
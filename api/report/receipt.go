@@ -0,0 +1,86 @@
+package report
+
+import (
+	"zakat/api/models"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+var methodNames = map[string]string{
+	models.MethodCash:     "Tunai",
+	models.MethodTransfer: "Transfer bank",
+	models.MethodQris:     "QRIS",
+	models.MethodInKind:   "Natura (beras)",
+}
+
+// ReceiptPDF renders a bukti setor zakat on an A5 landscape page
+func ReceiptPDF(r *models.Receipt) ([]byte, error) {
+	pdf := gofpdf.New("L", "mm", "A5", "")
+	pdf.SetMargins(12, 10, 12)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	width := 186.0
+
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(width, 7, tr(r.OrgName), "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range []string{r.OrgAddress, r.OrgContact} {
+		if line != "" {
+			pdf.CellFormat(width, 5, tr(line), "", 1, "C", false, 0, "")
+		}
+	}
+	pdf.Line(12, pdf.GetY()+1, 12+width, pdf.GetY()+1)
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(width, 7, "BUKTI SETOR ZAKAT", "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(width, 5, "No. "+r.Number, "", 1, "C", false, 0, "")
+	if r.Status == models.ReceiptVoid {
+		pdf.SetTextColor(200, 0, 0)
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.CellFormat(width, 6, tr("DIBATALKAN - "+r.VoidReason), "", 1, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+		pdf.SetFont("Helvetica", "", 10)
+	}
+	pdf.Ln(3)
+
+	fields := [][2]string{
+		{"Nama muzakki", r.MuzakkiName},
+		{"ID muzakki", r.IdMuzakki},
+		{"Alamat", r.MuzakkiAddress},
+		{"Jenis zakat", r.ZakatType},
+		{"Cara pembayaran", methodNames[r.Method]},
+		{"Jumlah", Rupiah(r.Amount)},
+	}
+	for _, field := range fields {
+		pdf.CellFormat(40, 6, field[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(4, 6, ":", "", 0, "L", false, 0, "")
+		pdf.CellFormat(width-44, 6, tr(field[1]), "", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(40, 6, "Terbilang", "", 0, "L", false, 0, "")
+	pdf.CellFormat(4, 6, ":", "", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "I", 10)
+	pdf.MultiCell(width-44, 6, tr(Title(r.AmountWords)), "", "L", false)
+	pdf.SetFont("Helvetica", "", 10)
+
+	top := pdf.GetY() + 4
+	err := drawQR(pdf, "qr-"+r.Number, r.QrPayload(), 12, top, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf.SetXY(120, top)
+	pdf.CellFormat(78, 5, Date(r.IssuedAt), "", 2, "C", false, 0, "")
+	pdf.CellFormat(78, 5, "Diterima oleh amil,", "", 2, "C", false, 0, "")
+	pdf.SetXY(120, top+24)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(78, 5, tr(r.AmilName), "", 2, "C", false, 0, "")
+
+	pdf.SetXY(12, top+34)
+	pdf.SetFont("Helvetica", "I", 7)
+	pdf.CellFormat(width, 4, "Semoga Allah memberikan pahala atas apa yang engkau berikan, menjadikannya pembersih bagimu, dan memberkahi harta yang tersisa.", "", 1, "L", false, 0, "")
+
+	return output(pdf)
+}
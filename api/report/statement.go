@@ -0,0 +1,142 @@
+package report
+
+import (
+	"bytes"
+	"zakat/api/models"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+const (
+	pageWidth  = 190.0
+	labelWidth = 130.0
+	lineHeight = 7.0
+)
+
+func newStatement(title, subtitle string) *gofpdf.Fpdf {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(10, 12, 10)
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(pageWidth, 8, title, "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(pageWidth, 6, subtitle, "", 1, "C", false, 0, "")
+	pdf.Ln(4)
+
+	return pdf
+}
+
+func heading(pdf *gofpdf.Fpdf, text string) {
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(pageWidth, lineHeight, text, "B", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+}
+
+func row(pdf *gofpdf.Fpdf, label string, amount int) {
+	pdf.CellFormat(labelWidth, lineHeight, "    "+label, "", 0, "L", false, 0, "")
+	pdf.CellFormat(pageWidth-labelWidth, lineHeight, Rupiah(amount), "", 1, "R", false, 0, "")
+}
+
+func total(pdf *gofpdf.Fpdf, label string, amount int) {
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(labelWidth, lineHeight, label, "T", 0, "L", false, 0, "")
+	pdf.CellFormat(pageWidth-labelWidth, lineHeight, Rupiah(amount), "T", 1, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.Ln(2)
+}
+
+func output(pdf *gofpdf.Fpdf) ([]byte, error) {
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func FinancialPositionPDF(fp *models.FinancialPosition) ([]byte, error) {
+	pdf := newStatement("Laporan Posisi Keuangan", "Per "+Date(fp.AsOf))
+
+	heading(pdf, "Aset")
+	for _, line := range fp.Assets {
+		row(pdf, line.Name, line.Amount)
+	}
+	total(pdf, "Jumlah aset", fp.TotalAssets)
+
+	heading(pdf, "Liabilitas")
+	for _, line := range fp.Liabilities {
+		row(pdf, line.Name, line.Amount)
+	}
+	total(pdf, "Jumlah liabilitas", fp.TotalLiabilities)
+
+	heading(pdf, "Saldo dana")
+	for _, line := range fp.Funds {
+		row(pdf, line.Name, line.Amount)
+	}
+	total(pdf, "Jumlah saldo dana", fp.TotalFunds)
+	total(pdf, "Jumlah liabilitas dan saldo dana", fp.TotalLiabilities+fp.TotalFunds)
+
+	return output(pdf)
+}
+
+func FundChangesPDF(fc *models.FundChanges) ([]byte, error) {
+	pdf := newStatement("Laporan Perubahan Dana", Period(fc.PeriodStart, fc.PeriodEnd))
+
+	for _, fund := range fc.Funds {
+		heading(pdf, models.FundNames[fund.Fund])
+		pdf.SetFont("Helvetica", "I", 10)
+		pdf.CellFormat(pageWidth, lineHeight, "  Penerimaan", "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		for _, line := range fund.Receipts {
+			row(pdf, line.Name, line.Amount)
+		}
+		total(pdf, "Jumlah penerimaan", fund.TotalReceipts)
+
+		pdf.SetFont("Helvetica", "I", 10)
+		pdf.CellFormat(pageWidth, lineHeight, "  Penyaluran", "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		for _, line := range fund.Distributions {
+			row(pdf, line.Name, line.Amount)
+		}
+		total(pdf, "Jumlah penyaluran", fund.TotalDistributions)
+
+		row(pdf, "Surplus (defisit)", fund.Surplus)
+		row(pdf, "Saldo awal", fund.Opening)
+		total(pdf, "Saldo akhir", fund.Closing)
+		pdf.Ln(3)
+	}
+
+	return output(pdf)
+}
+
+func ManagedAssetChangesPDF(mac *models.ManagedAssetChanges) ([]byte, error) {
+	pdf := newStatement("Laporan Perubahan Aset Kelolaan", Period(mac.PeriodStart, mac.PeriodEnd))
+
+	widths := []float64{50, 28, 28, 28, 28, 28}
+	headers := []string{"Aset kelolaan", "Saldo awal", "Penambahan", "Pengembalian", "Penghapusan", "Saldo akhir"}
+	pdf.SetFont("Helvetica", "B", 9)
+	for i, header := range headers {
+		pdf.CellFormat(widths[i], lineHeight, header, "1", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	assets := append(mac.Assets, mac.Total)
+	for i, asset := range assets {
+		if i == len(assets)-1 {
+			pdf.SetFont("Helvetica", "B", 9)
+		}
+		cells := []string{asset.Name, Rupiah(asset.Opening), Rupiah(asset.Additions), Rupiah(asset.Collections), Rupiah(asset.WrittenOff), Rupiah(asset.Closing)}
+		for j, cell := range cells {
+			align := "R"
+			if j == 0 {
+				align = "L"
+			}
+			pdf.CellFormat(widths[j], lineHeight, cell, "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	return output(pdf)
+}
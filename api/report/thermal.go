@@ -0,0 +1,45 @@
+package report
+
+import (
+	"html"
+	"zakat/api/escpos"
+	"zakat/api/models"
+)
+
+// ReceiptEscPos renders a receipt for 58mm thermal printers, everything printed comes from the stored receipt
+func ReceiptEscPos(r *models.Receipt) []byte {
+	labelWidth := 8
+	b := escpos.New(escpos.Width58)
+
+	b.Align(escpos.AlignCenter).Bold(true).Line(html.UnescapeString(r.OrgName)).Bold(false)
+	for _, line := range []string{r.OrgAddress, r.OrgContact} {
+		if line != "" {
+			b.Line(html.UnescapeString(line))
+		}
+	}
+	b.Align(escpos.AlignLeft).Rule()
+
+	b.Align(escpos.AlignCenter).Bold(true).Line("BUKTI SETOR ZAKAT").Bold(false).Line(r.Number)
+	if r.Status == models.ReceiptVoid {
+		b.Bold(true).Line("*** DIBATALKAN ***").Bold(false).Line(html.UnescapeString(r.VoidReason))
+	}
+	b.Align(escpos.AlignLeft).Rule()
+
+	b.Field("Tanggal", labelWidth, r.IssuedAt.Format("02-01-2006 15:04"))
+	b.Field("Muzakki", labelWidth, html.UnescapeString(r.MuzakkiName))
+	b.Field("ID", labelWidth, r.IdMuzakki)
+	b.Field("Jenis", labelWidth, r.ZakatType)
+	b.Field("Metode", labelWidth, methodNames[r.Method])
+	b.Rule()
+
+	b.Bold(true).Pair("TOTAL", Rupiah(r.Amount)).Bold(false)
+	b.Line(Title(r.AmountWords))
+	b.Rule()
+
+	b.Field("Amil", labelWidth, r.AmilName)
+	b.Feed(1)
+	b.Align(escpos.AlignCenter).QR(r.QrPayload(), 5).Line("Pindai untuk verifikasi")
+	b.Line("Jazakumullahu khairan")
+
+	return b.Feed(3).Cut().Bytes()
+}
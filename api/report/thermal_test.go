@@ -0,0 +1,64 @@
+package report
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+	"zakat/api/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestReceiptEscPos(t *testing.T) {
+	t.Setenv("RECEIPT_VERIFY_URL", "https://zakat.example/verify")
+
+	r := models.Receipt{
+		OrgName:     "BAZNAS Kota Contoh",
+		OrgAddress:  "Jl. Masjid Raya No. 1, Kota Contoh",
+		OrgContact:  "0812-0000-0000",
+		Number:      "BSZ/2026/000042",
+		IdMuzakki:   "M-0001",
+		MuzakkiName: "Abdullah bin Umar Al-Faruq",
+		ZakatType:   "Zakat fitrah",
+		Method:      models.MethodCash,
+		Amount:      47500,
+		AmountWords: "empat puluh tujuh ribu lima ratus rupiah",
+		AmilName:    "Amil Satu",
+		IssuedAt:    time.Date(2026, 3, 28, 9, 30, 0, 0, time.UTC),
+		Status:      models.ReceiptIssued,
+		Signature:   "c2lnbmF0dXJl",
+	}
+	got := ReceiptEscPos(&r)
+
+	golden := filepath.Join("testdata", "receipt.escpos")
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("ReceiptEscPos() differs from %s, run go test -update after checking the printout\ngot:  %q\nwant: %q", golden, got, want)
+	}
+}
+
+func TestReceiptEscPosVoid(t *testing.T) {
+	r := models.Receipt{Number: "BSZ/2026/000043", Status: models.ReceiptVoid, VoidReason: "salah input"}
+	got := ReceiptEscPos(&r)
+
+	if !bytes.Contains(got, []byte("*** DIBATALKAN ***\n\x1bE\x00salah input\n")) {
+		t.Errorf("ReceiptEscPos() of a voided receipt does not say so: %q", got)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	if got, want := Title("empat puluh  ribu rupiah"), "Empat Puluh Ribu Rupiah"; got != want {
+		t.Errorf("Title() = %q, want %q", got, want)
+	}
+}
@@ -15,6 +15,12 @@ func FormatError(errString string) map[string]string {
 	if strings.Contains(errString, "title") {
 		errMsg["Taken_title"] = "title already taken"
 	}
+	if strings.Contains(errString, "nik") {
+		errMsg["Taken_nik"] = "nik already registered"
+	}
+	if strings.Contains(errString, "agreement_no") {
+		errMsg["Taken_agreement"] = "agreement number already used"
+	}
 	if strings.Contains(errString, "hashedPassword") {
 		errMsg["Incorrect_password"] = "incorrect password"
 	}
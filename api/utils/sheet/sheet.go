@@ -0,0 +1,63 @@
+package sheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var ErrUnsupported = errors.New("file must be csv or xlsx")
+
+// Rows reads a csv file or the first sheet of an xlsx file, the format is chosen by the file extension
+func Rows(name string, r io.Reader) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return csvRows(r)
+	case ".xlsx":
+		return xlsxRows(r)
+	}
+
+	return nil, ErrUnsupported
+}
+
+// csvRows accepts both comma and semicolon, spreadsheets with an Indonesian locale export the latter
+func csvRows(r io.Reader) ([][]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	header := string(data)
+	if i := strings.IndexAny(header, "\r\n"); i >= 0 {
+		header = header[:i]
+	}
+	if strings.Count(header, ";") > strings.Count(header, ",") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	return reader.ReadAll()
+}
+
+func xlsxRows(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("workbook has no sheet")
+	}
+
+	return f.GetRows(sheets[0])
+}
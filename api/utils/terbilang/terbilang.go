@@ -0,0 +1,76 @@
+package terbilang
+
+import "strings"
+
+var digits = []string{"", "satu", "dua", "tiga", "empat", "lima", "enam", "tujuh", "delapan", "sembilan", "sepuluh", "sebelas"}
+
+var scales = []struct {
+	value int
+	name  string
+}{
+	{1000000000000, "triliun"},
+	{1000000000, "miliar"},
+	{1000000, "juta"},
+	{1000, "ribu"},
+}
+
+// Terbilang spells a number in Indonesian words, e.g. 1250000 is "satu juta dua ratus lima puluh ribu"
+func Terbilang(n int) string {
+	if n == 0 {
+		return "nol"
+	}
+	if n < 0 {
+		return "minus " + Terbilang(-n)
+	}
+
+	return strings.Join(words(n), " ")
+}
+
+// Rupiah spells an amount followed by "rupiah"
+func Rupiah(n int) string {
+	return Terbilang(n) + " rupiah"
+}
+
+func words(n int) []string {
+	result := []string{}
+	for _, scale := range scales {
+		if n < scale.value {
+			continue
+		}
+		count := n / scale.value
+		n %= scale.value
+		if scale.value == 1000 && count == 1 {
+			result = append(result, "seribu")
+			continue
+		}
+		result = append(result, words(count)...)
+		result = append(result, scale.name)
+	}
+
+	return append(result, hundreds(n)...)
+}
+
+func hundreds(n int) []string {
+	result := []string{}
+	switch {
+	case n >= 200:
+		result = append(result, digits[n/100], "ratus")
+	case n >= 100:
+		result = append(result, "seratus")
+	}
+	n %= 100
+
+	switch {
+	case n >= 20:
+		result = append(result, digits[n/10], "puluh")
+		if n%10 > 0 {
+			result = append(result, digits[n%10])
+		}
+	case n >= 12:
+		result = append(result, digits[n-10], "belas")
+	case n > 0:
+		result = append(result, digits[n])
+	}
+
+	return result
+}
@@ -0,0 +1,46 @@
+package terbilang
+
+import "testing"
+
+func TestTerbilang(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "nol"},
+		{1, "satu"},
+		{10, "sepuluh"},
+		{11, "sebelas"},
+		{12, "dua belas"},
+		{19, "sembilan belas"},
+		{20, "dua puluh"},
+		{21, "dua puluh satu"},
+		{100, "seratus"},
+		{101, "seratus satu"},
+		{115, "seratus lima belas"},
+		{200, "dua ratus"},
+		{999, "sembilan ratus sembilan puluh sembilan"},
+		{1000, "seribu"},
+		{1001, "seribu satu"},
+		{2500, "dua ribu lima ratus"},
+		{11000, "sebelas ribu"},
+		{100000, "seratus ribu"},
+		{1250000, "satu juta dua ratus lima puluh ribu"},
+		{1000000000, "satu miliar"},
+		{2000001000, "dua miliar seribu"},
+		{1000000000000, "satu triliun"},
+		{-45000, "minus empat puluh lima ribu"},
+	}
+
+	for _, tt := range tests {
+		if got := Terbilang(tt.n); got != tt.want {
+			t.Errorf("Terbilang(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRupiah(t *testing.T) {
+	if got, want := Rupiah(47500), "empat puluh tujuh ribu lima ratus rupiah"; got != want {
+		t.Errorf("Rupiah() = %q, want %q", got, want)
+	}
+}
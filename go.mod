@@ -4,12 +4,15 @@ go 1.16
 
 require (
 	github.com/badoux/checkmail v1.2.1 // indirect
+	github.com/boombuler/barcode v1.0.1
 	github.com/casbin/casbin/v2 v2.35.0 // indirect
 	github.com/casbin/gorm-adapter/v3 v3.3.3 // indirect
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
 	github.com/gin-gonic/gin v1.7.3 // indirect
 	github.com/joho/godotenv v1.3.0 // indirect
+	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/twinj/uuid v1.0.0 // indirect
+	github.com/xuri/excelize/v2 v2.4.1
 	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97 // indirect
 	gorm.io/driver/mysql v1.1.1 // indirect
 	gorm.io/driver/postgres v1.1.0 // indirect